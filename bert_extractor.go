@@ -28,7 +28,7 @@ func NewBERTFeatureExtractor(modelPath string, tokenizerPath string) (*BERTFeatu
 
 	tok, err := pretrained.FromFile(tokenizerPath)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("加载BERT词元化器失败: %w", err)
 	}
 
 
@@ -42,7 +42,7 @@ func NewBERTFeatureExtractor(modelPath string, tokenizerPath string) (*BERTFeatu
 	// 创建会话
 	options, err := ort.NewSessionOptions()
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("创建BERT会话配置失败: %w", err)
 	}
 	defer options.Destroy()
 
@@ -100,7 +100,7 @@ func (b *BERTFeatureExtractor) ExtractFeatures(text string) (ort.Value, error) {
 	// 2. 编码
 	enc, err := b.tok.EncodeSingle(text, true)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("BERT编码失败: %w", err)
 	}
 
 	// 3. 直接取 3 个 []int64
@@ -211,6 +211,12 @@ func (b *BERTFeatureExtractor) ExtractFeaturesForTTS(text string, word2ph []int6
 
 
 
+	// word2ph 与 token 一一对应，超出token数说明g2p分组与BERT分词没有对齐
+	seqLen := len(flatData) / int(hidden)
+	if len(word2ph) > seqLen {
+		return nil, fmt.Errorf("word2ph长度(%d)与BERT token数(%d)不一致", len(word2ph), seqLen)
+	}
+
 	// repeat
 	sumWord2ph := int64(0)
 	for _, r := range word2ph {
@@ -258,6 +264,94 @@ func (b *BERTFeatureExtractor) ExtractFeaturesForTTS(text string, word2ph []int6
 
 
 }
+// ExtractFeaturesForTTSBatch 批量提取TTS用的BERT特征，一次推理多条文本
+// texts 按最长token数补齐（attention_mask 为0），word2phs[i] 展开后补齐到 phoneLen，
+// 返回形状 [batch, 768, phoneLen] 的张量，调用者负责销毁
+func (b *BERTFeatureExtractor) ExtractFeaturesForTTSBatch(texts []string, word2phs [][]int64, phoneLen int64) (*ort.Tensor[float32], error) {
+	batchSize := int64(len(texts))
+	encodings := make([][]int, len(texts))
+	seqLens := make([]int, len(texts))
+	maxSeqLen := 0
+	for i, text := range texts {
+		enc, err := b.tok.EncodeSingle(text, true)
+		if err != nil {
+			return nil, fmt.Errorf("BERT编码失败: %w", err)
+		}
+		encodings[i] = enc.Ids
+		seqLens[i] = len(enc.Ids)
+		maxSeqLen = max(maxSeqLen, len(enc.Ids))
+	}
+
+	// 补齐部分 input_ids 为0（[PAD]），attention_mask 为0
+	inputIDs := make([]int64, int(batchSize)*maxSeqLen)
+	attentionMask := make([]int64, int(batchSize)*maxSeqLen)
+	tokenTypeIDs := make([]int64, int(batchSize)*maxSeqLen)
+	for i, ids := range encodings {
+		for j, id := range ids {
+			inputIDs[i*maxSeqLen+j] = int64(id)
+			attentionMask[i*maxSeqLen+j] = 1
+		}
+	}
+
+	shape := ort.NewShape(batchSize, int64(maxSeqLen))
+	inputIDsTensor, err := ort.NewTensor(shape, inputIDs)
+	if err != nil {
+		return nil, fmt.Errorf("创建input_ids张量失败: %w", err)
+	}
+	defer inputIDsTensor.Destroy()
+	attentionMaskTensor, err := ort.NewTensor(shape, attentionMask)
+	if err != nil {
+		return nil, fmt.Errorf("创建attention_mask张量失败: %w", err)
+	}
+	defer attentionMaskTensor.Destroy()
+	tokenTypeIDsTensor, err := ort.NewTensor(shape, tokenTypeIDs)
+	if err != nil {
+		return nil, fmt.Errorf("创建token_type_ids张量失败: %w", err)
+	}
+	defer tokenTypeIDsTensor.Destroy()
+
+	inputs := []ort.Value{inputIDsTensor, tokenTypeIDsTensor, attentionMaskTensor}
+	outputs := []ort.Value{nil}
+	if err := b.session.Run(inputs, outputs); err != nil {
+		return nil, fmt.Errorf("运行BERT模型失败: %w", err)
+	}
+	defer outputs[0].Destroy()
+
+	featureTensor, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, fmt.Errorf("BERT特征数据类型不是float32")
+	}
+	flatData := featureTensor.GetData()
+	hidden := 768
+	if len(flatData) != int(batchSize)*maxSeqLen*hidden {
+		return nil, fmt.Errorf("BERT输出形状 %v 与批量输入不一致", featureTensor.GetShape())
+	}
+
+	// 按 word2ph 把token特征重复到音素级，并直接写成 [batch, hidden, phoneLen] 布局
+	dst := make([]float32, int(batchSize)*hidden*int(phoneLen))
+	for i, word2ph := range word2phs {
+		if len(word2ph) > seqLens[i] {
+			return nil, fmt.Errorf("第%d条 word2ph长度(%d)与BERT token数(%d)不一致", i, len(word2ph), seqLens[i])
+		}
+		src := flatData[i*maxSeqLen*hidden:]
+		out := dst[i*hidden*int(phoneLen):]
+		phoneIndex := 0
+		for token, repeat := range word2ph {
+			for r := 0; r < int(repeat); r++ {
+				if phoneIndex >= int(phoneLen) {
+					return nil, fmt.Errorf("第%d条 word2ph之和超过音素数 %d", i, phoneLen)
+				}
+				for h := 0; h < hidden; h++ {
+					out[h*int(phoneLen)+phoneIndex] = src[token*hidden+h]
+				}
+				phoneIndex++
+			}
+		}
+	}
+
+	return ort.NewTensor(ort.NewShape(batchSize, int64(hidden), phoneLen), dst)
+}
+
 // transpose2D 将二维 float32 矩阵转置，输入输出均为 ONNX Tensor
 func transpose2DAndAddBatchDim(srcTensor *ort.Tensor[float32]) (*ort.Tensor[float32], error) {
 	shape := srcTensor.GetShape()
@@ -1,4 +1,4 @@
 set GOOS=windows
 set GOARCH=amd64
-go build -o tts-win.exe main.go tts-http-service.go bert_extractor.go cantonese_g2p.go english_g2p.go mandaren_g2p.go mandaren_pinyin.go text_parse.go melo-onnx-tts.go
+go build -o tts-win.exe main.go tts-http-service.go bert_extractor.go cantonese_g2p.go cantonese_jyutping.go english_g2p.go english_cmudict.go english_acronym.go english_number.go english_pos.go english_heteronym.go mandaren_g2p.go mandaren_pinyin.go mandaren_segment.go mandaren_tone_sandhi.go text_normalize.go text_parse.go ssml_parse.go user_lexicon.go melo-onnx-tts.go tts-stream-service.go tts-websocket-service.go tts-openai-service.go tts-errors.go tts-model-registry.go tts-inference-params.go tts-seed.go tts-engine-pool.go tts-audio-encoder.go tts-flac-encoder.go tts-resampler.go tts-loudness.go tts-text-chunker.go english_lts.go tts-audio-cache.go tts-batch-scheduler.go tts-alignment.go tts-lexicon-service.go
 
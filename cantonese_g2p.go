@@ -17,15 +17,35 @@ import (
 
 var s2hk *gocc.OpenCC
 
-func CantoneseResourcePreload()(){
+func CantoneseResourcePreload() error {
 	if s2hk == nil {
-		s2t, _ := gocc.New("s2hk")
+		s2t, err := gocc.New("s2hk")
+		if err != nil {
+			return newTTSError(ErrCodeModelLoad, "加载简繁转换词典失败", err)
+		}
 		s2hk = s2t
 	}
-
+	if cantoneseLexicon == nil {
+		lexicon, err := loadCantoneseLexicon(cantoneseLexiconPaths)
+		if err != nil {
+			return err
+		}
+		if lexicon == nil {
+			fmt.Println("未找到粤拼词典，粤语拼音将请求外部 pycantonese 服务")
+		}
+		cantoneseLexicon = lexicon
+	}
+	return nil
 }
 
-func CantoneseMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string, []int, []int, string) {
+// lexicon 为用户发音词典，可为 nil
+func CantoneseMix_g2p(text string, bertExtractor *BERTFeatureExtractor, lexicon *UserLexicon) ([]string, []int, []int, string, error) {
+	if s2hk == nil {
+		return nil, nil, nil, "", newTTSError(ErrCodeModelLoad, "简繁转换词典未加载", nil)
+	}
+
+	// 数字、日期、单位等先改写为汉字读法
+	text = NormalizeChineseText(text, YUE_EN)
 
 
 	mix_phones := []string{"_"}
@@ -46,15 +66,25 @@ func CantoneseMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]strin
 		indexStr := strconv.Itoa(index)
 		if segment.Type == TypeChinese{
 			// 统一转换为香港繁体
-	        sentence, _ := s2hk.Convert(segment.Content)
+	        sentence, err := s2hk.Convert(segment.Content)
+			if err != nil {
+				return nil, nil, nil, "", newTTSError(ErrCodeG2P, "简繁转换失败", err)
+			}
 			chineseSentences[indexStr] = sentence
 			filteredText += sentence
 		}
 		if segment.Type == TypeNumber{
 			// 数字简单转换为中文模式，具体取决于业务模式，如钱币 日期 时间，可在前端进行处理
-			num, _ := strconv.ParseInt(segment.Content, 10, 64)
+			num, err := strconv.ParseInt(segment.Content, 10, 64)
+			if err != nil {
+				return nil, nil, nil, "", newTTSError(ErrCodeG2P, "无法解析数字: "+segment.Content, err)
+			}
 			zhstr := chinese_number.Number2Simplified(num)
-			fmt.Printf("number:%d to simplified chinese:%q\n",num,zhstr)
+			// 粤拼词典为繁体，数字读法同样转为香港繁体（万->萬）
+			if hkstr, err := s2hk.Convert(zhstr); err == nil {
+				zhstr = hkstr
+			}
+			fmt.Printf("number:%d to chinese:%q\n",num,zhstr)
 			chineseSentences[indexStr] = zhstr
 			filteredText += zhstr
 		} 
@@ -69,34 +99,47 @@ func CantoneseMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]strin
 	var jyupinyinMap map[string]interface{}
 	if len(chineseSentences) > 0 {
 		start := time.Now()
-		jyupinyinMap = request_jyuping(chineseSentences)
-		elapsed := time.Since(start)
-		fmt.Printf("请求粤语拼音接口 (耗时: %v)\n", elapsed)
+		if cantoneseLexicon != nil {
+			jyupinyinMap = local_jyutping(chineseSentences)
+			fmt.Printf("本地粤语拼音转换 (耗时: %v)\n", time.Since(start))
+		} else {
+			var err error
+			jyupinyinMap, err = request_jyuping(chineseSentences)
+			if err != nil {
+				return nil, nil, nil, "", err
+			}
+			elapsed := time.Since(start)
+			fmt.Printf("请求粤语拼音接口 (耗时: %v)\n", elapsed)
+		}
 	}
 
 	//fmt.Println("jyupinyinList:",jyupinyinList)
 	//真正处理文本
 	for index, segment := range segments {
 		indexStr := strconv.Itoa(index)
-		if segment.Type == TypeChinese{
-			
-			jyupinyinList := jyupinyinMap[indexStr].([]interface{})
-			phones, tones, word2ph := Cantonese_g2p(jyupinyinList)
-			mix_phones = append(mix_phones, phones...)
-			mix_tones = append(mix_tones, tones...)
-			mix_word2ph = append(mix_word2ph, word2ph...)
-		}
-		if segment.Type == TypeNumber{
-			// 数字简单转换为中文模式，具体取决于业务模式，如钱币 日期 时间，可在前端进行处理
-			jyupinyinList := jyupinyinMap[indexStr].([]interface{})
-			phones, tones, word2ph := Cantonese_g2p(jyupinyinList)
+		if segment.Type == TypeChinese || segment.Type == TypeNumber{
+			// 数字已在上面转换为中文，与中文一样取粤语拼音
+			jyupinyinList, ok := jyupinyinMap[indexStr].([]interface{})
+			if !ok {
+				return nil, nil, nil, "", newTTSError(ErrCodeCantoneseService, "粤语拼音服务返回缺少片段: "+segment.Content, nil)
+			}
+			if segment.Type == TypeChinese {
+				// 用户词典中的词覆盖默认粤拼，原文与香港繁体都参与匹配
+				jyupinyinList = lexicon.ApplyCantonese([]string{segment.Content, chineseSentences[indexStr]}, jyupinyinList)
+			}
+			phones, tones, word2ph, err := Cantonese_g2p(jyupinyinList)
+			if err != nil {
+				return nil, nil, nil, "", err
+			}
 			mix_phones = append(mix_phones, phones...)
 			mix_tones = append(mix_tones, tones...)
 			mix_word2ph = append(mix_word2ph, word2ph...)
-
 		}
 		if segment.Type == TypeEnglish{
-			en_phones ,en_tones, en_word2ph := English_g2p(segment.Content, bertExtractor)
+			en_phones ,en_tones, en_word2ph, err := English_g2p(segment.Content, bertExtractor, lexicon)
+			if err != nil {
+				return nil, nil, nil, "", err
+			}
 			mix_phones = append(mix_phones, en_phones...)
 			mix_tones = append(mix_tones, en_tones...)
 			mix_word2ph = append(mix_word2ph, en_word2ph...)
@@ -118,12 +161,12 @@ func CantoneseMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]strin
 	mix_tones = append(mix_tones, 0)
 	mix_word2ph = append(mix_word2ph, 1)
 
-	return 	mix_phones ,mix_tones, mix_word2ph, filteredText
+	return 	mix_phones ,mix_tones, mix_word2ph, filteredText, nil
 }
 
 
 
-func Cantonese_g2p(jyupinyinList []interface{}) ([]string, []int, []int) {
+func Cantonese_g2p(jyupinyinList []interface{}) ([]string, []int, []int, error) {
 	//此函数
 
 	phones := []string{}
@@ -131,19 +174,32 @@ func Cantonese_g2p(jyupinyinList []interface{}) ([]string, []int, []int) {
 	word2ph := []int{}
 
 	for _, item := range jyupinyinList {
-		jyupingMap := item.(map[string]interface{})
+		jyupingMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, nil, nil, newTTSError(ErrCodeG2P, "粤语拼音格式错误", nil)
+		}
 		//zhchar := jyupingMap["char"].(string)
-		initial_list := jyupingMap["initial_list"].([]interface{})
+		initial_list, ok := jyupingMap["initial_list"].([]interface{})
+		if !ok {
+			return nil, nil, nil, newTTSError(ErrCodeG2P, fmt.Sprintf("粤语拼音缺少 initial_list: %v", jyupingMap["char"]), nil)
+		}
 		//fmt.Println("zhchar:",zhchar)
 		for _, initialItem := range initial_list {
 			//fmt.Println("initial:", initial["initial"])
-			initialItemDict := initialItem.(map[string]interface{})
-			initial := initialItemDict["initial"].(string)
-			nucleus := initialItemDict["nucleus"].(string)
-			coda := initialItemDict["coda"].(string)
-			tone := initialItemDict["tone"].(string)
-
-			toneInt, _ := strconv.Atoi(tone)
+			initialItemDict, ok := initialItem.(map[string]interface{})
+			if !ok {
+				return nil, nil, nil, newTTSError(ErrCodeG2P, "粤语音节格式错误", nil)
+			}
+			// 服务端缺失字段时按空字符串处理
+			initial, _ := initialItemDict["initial"].(string)
+			nucleus, _ := initialItemDict["nucleus"].(string)
+			coda, _ := initialItemDict["coda"].(string)
+			tone, _ := initialItemDict["tone"].(string)
+
+			toneInt, err := strconv.Atoi(tone)
+			if err != nil {
+				return nil, nil, nil, newTTSError(ErrCodeG2P, fmt.Sprintf("粤语声调格式错误: %q", tone), err)
+			}
 
 			//fmt.Printf("initial: %v, nucleus: %v, coda: %v, tone: %v\n", initial, nucleus, coda, tone)
 			phoneCountPerword := 0
@@ -170,7 +226,7 @@ func Cantonese_g2p(jyupinyinList []interface{}) ([]string, []int, []int) {
 	}
 
 
-	return phones, tones, word2ph
+	return phones, tones, word2ph, nil
 }
 
 func IsEmptyOrWhitespace(s string) bool {
@@ -187,7 +243,7 @@ func IsEmptyOrWhitespace(s string) bool {
     // return true
 }
 
-func request_jyuping(sentences map[string]string) map[string]interface{} {
+func request_jyuping(sentences map[string]string) (map[string]interface{}, error) {
 
 
 	
@@ -199,12 +255,8 @@ func request_jyuping(sentences map[string]string) map[string]interface{} {
 	// 将 sentences 转为 JSON 字符串
 	jsonBytes, err := json.Marshal(sentences)
 	if err != nil {
-		fmt.Printf("sentences 转 JSON 失败: %v\n", err)
-		return nil
+		return nil, newTTSError(ErrCodeG2P, "sentences 转 JSON 失败", err)
 	}
-	// hkout := string(jsonBytes)
-    
-	// reqbodystr := fmt.Sprintf(`{"content": "%s"}`, hkout)
 
     // 4. 创建请求，使用 jsonBytes 作为请求体
     req, err := http.NewRequest(
@@ -212,10 +264,8 @@ func request_jyuping(sentences map[string]string) map[string]interface{} {
         "http://127.0.0.1:48000/cantonese_split",
         bytes.NewReader(jsonBytes),
     )
-    // 4. 创建请求
     if err != nil {
-        fmt.Printf("创建请求失败: %v\n", err)
-        
+        return nil, newTTSError(ErrCodeCantoneseService, "创建粤语拼音请求失败", err)
     }
     
     // 5. 设置请求头
@@ -226,35 +276,25 @@ func request_jyuping(sentences map[string]string) map[string]interface{} {
     // 6. 发送请求
     resp, err := client.Do(req)
     if err != nil {
-        fmt.Printf("请求失败: %v\n", err)
-        
+        return nil, newTTSError(ErrCodeCantoneseService, "请求粤语拼音服务失败", err)
     }
     defer resp.Body.Close() // 确保关闭响应体
     
     // 7. 读取响应体
     body, err := io.ReadAll(resp.Body)
     if err != nil {
-        fmt.Printf("读取响应失败: %v\n", err)
-        
+        return nil, newTTSError(ErrCodeCantoneseService, "读取粤语拼音响应失败", err)
     }
-    
-    // 8. 输出响应信息
-    //fmt.Printf("状态码: %d\n", resp.StatusCode)
-    //fmt.Printf("响应头: %v\n", resp.Header)
-   // fmt.Printf("响应体: %s\n", string(body))
 
-    // // 9. 解析JSON响应（可选）
-	var response map[string]interface{}
-    if resp.StatusCode == http.StatusOK {
-        
-        if err := json.Unmarshal(body, &response); err == nil {
-            //fmt.Printf("解析后的响应: %+v\n", response)
-        }else{
-			fmt.Printf("解析响应失败: %v\n", err)
-		}
-		//fmt.Printf("response : %v\n", response)
+    if resp.StatusCode != http.StatusOK {
+        return nil, newTTSError(ErrCodeCantoneseService, fmt.Sprintf("粤语拼音服务返回状态码 %d", resp.StatusCode), nil)
+    }
 
+    // 8. 解析JSON响应
+	var response map[string]interface{}
+    if err := json.Unmarshal(body, &response); err != nil {
+        return nil, newTTSError(ErrCodeCantoneseService, "解析粤语拼音响应失败", err)
     }
 
-	return response
-}
\ No newline at end of file
+	return response, nil
+}
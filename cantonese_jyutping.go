@@ -0,0 +1,306 @@
+package main
+
+// 纯Go粤语拼音转换，替代 pycantonese_service.py
+// 词典使用开放的粤拼词表，支持两种格式：
+//   1. TSV：每行 "词<TAB>粤拼"，粤拼音节以空格分隔，如 "我哋	ngo5 dei6"，# 开头为注释
+//   2. rime-cantonese 的 jyut6ping3.*.dict.yaml：跳过 "---" 到 "..." 之间的头部，第三列为权重
+// 同一个词有多个读音时取权重最高的，权重相同取先出现的
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 粤拼词典候选文件，存在的都会加载，后加载的词条不覆盖先加载的高权重词条
+var cantoneseLexiconPaths = []string{
+	"./cantonese_lexicon.tsv",
+	"./jyut6ping3.chars.dict.yaml",
+	"./jyut6ping3.words.dict.yaml",
+}
+
+// 粤拼声母，按长度降序排列便于最长匹配
+var jyutpingOnsets = []string{"ng", "gw", "kw", "b", "p", "m", "f", "d", "t", "n", "l", "g", "k", "h", "w", "z", "c", "s", "j"}
+
+// 粤拼韵腹，按长度降序排列
+var jyutpingNuclei = []string{"aa", "oe", "eo", "yu", "ng", "a", "e", "i", "o", "u", "m"}
+
+// 粤拼韵尾
+var jyutpingCodas = map[string]struct{}{
+	"": {}, "p": {}, "t": {}, "k": {}, "m": {}, "n": {}, "ng": {}, "i": {}, "u": {},
+}
+
+// 粤拼音节拆分结果，与 pycantonese.parse_jyutping 的 onset/nucleus/coda/tone 对应
+type JyutpingSyllable struct {
+	Initial string
+	Nucleus string
+	Coda    string
+	Tone    string
+}
+
+// 词典条目
+type cantoneseLexiconEntry struct {
+	syllables []JyutpingSyllable
+	jyutping  string
+	weight    float64
+}
+
+// 粤拼词典，key为香港繁体词
+type CantoneseLexicon struct {
+	entries    map[string]cantoneseLexiconEntry
+	maxWordLen int // 最长词的字数，用于最长匹配
+}
+
+var cantoneseLexicon *CantoneseLexicon
+
+// 加载粤拼词典，没有任何词典文件时返回 nil，调用方回退到外部服务
+func loadCantoneseLexicon(paths []string) (*CantoneseLexicon, error) {
+	lexicon := &CantoneseLexicon{entries: make(map[string]cantoneseLexiconEntry)}
+	loaded := 0
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		start := time.Now()
+		count, err := lexicon.loadFile(path)
+		if err != nil {
+			return nil, newTTSError(ErrCodeModelLoad, "加载粤拼词典失败: "+path, err)
+		}
+		loaded++
+		fmt.Printf("加载粤拼词典 %s 完成条数: %d (耗时: %v)\n", path, count, time.Since(start))
+	}
+	if loaded == 0 {
+		return nil, nil
+	}
+	return lexicon, nil
+}
+
+func (l *CantoneseLexicon) loadFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	count := 0
+	lineNo := 0
+	inHeader := false
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// rime 词典的 YAML 头部
+		if lineNo == 1 && strings.HasPrefix(line, "---") {
+			inHeader = true
+			continue
+		}
+		if inHeader {
+			if strings.HasPrefix(line, "...") {
+				inHeader = false
+			}
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		word := strings.TrimSpace(fields[0])
+		jyutping := strings.TrimSpace(fields[1])
+		if word == "" || jyutping == "" {
+			continue
+		}
+
+		syllables, err := ParseJyutpingSyllables(jyutping)
+		if err != nil {
+			// 个别词条粤拼不规范时跳过，不影响整个词典
+			continue
+		}
+		// 音节数必须与字数一致，否则无法与BERT token对齐
+		if len(syllables) != utf8.RuneCountInString(word) {
+			continue
+		}
+
+		weight := 100.0
+		if len(fields) >= 3 {
+			weight = parseLexiconWeight(fields[2])
+		}
+		if old, ok := l.entries[word]; ok && old.weight >= weight {
+			continue
+		}
+		l.entries[word] = cantoneseLexiconEntry{
+			syllables: syllables,
+			jyutping:  strings.Join(strings.Fields(jyutping), ""),
+			weight:    weight,
+		}
+		if n := utf8.RuneCountInString(word); n > l.maxWordLen {
+			l.maxWordLen = n
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// 解析词条权重，支持 "5%" 与纯数字，无法解析时按默认读音处理
+func parseLexiconWeight(s string) float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 100
+	}
+	s = strings.TrimSuffix(s, "%")
+	weight, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 100
+	}
+	return weight
+}
+
+// ParseJyutpingSyllables 解析以空格分隔或直接连写的粤拼，如 "ngo5 dei6" / "ngo5dei6"
+func ParseJyutpingSyllables(jyutping string) ([]JyutpingSyllable, error) {
+	syllables := []JyutpingSyllable{}
+	for _, part := range strings.Fields(strings.ToLower(jyutping)) {
+		// 连写的粤拼按声调数字切开
+		start := 0
+		for i := 0; i < len(part); i++ {
+			if part[i] < '1' || part[i] > '6' {
+				continue
+			}
+			syllable, err := ParseJyutping(part[start : i+1])
+			if err != nil {
+				return nil, err
+			}
+			syllables = append(syllables, syllable)
+			start = i + 1
+		}
+		if start != len(part) {
+			return nil, fmt.Errorf("粤拼缺少声调: %q", part)
+		}
+	}
+	return syllables, nil
+}
+
+// ParseJyutping 把单个粤拼音节拆为声母、韵腹、韵尾、声调
+func ParseJyutping(syllable string) (JyutpingSyllable, error) {
+	if len(syllable) < 2 {
+		return JyutpingSyllable{}, fmt.Errorf("粤拼音节过短: %q", syllable)
+	}
+	tone := syllable[len(syllable)-1:]
+	if tone[0] < '1' || tone[0] > '6' {
+		return JyutpingSyllable{}, fmt.Errorf("粤拼声调错误: %q", syllable)
+	}
+	body := syllable[:len(syllable)-1]
+
+	// 成音节鼻音 m / ng 单独成韵
+	if body == "m" || body == "ng" {
+		return JyutpingSyllable{Nucleus: body, Tone: tone}, nil
+	}
+
+	// 先尝试各个声母，再尝试零声母，剩余部分必须能拆成 韵腹+韵尾
+	candidates := []string{}
+	for _, onset := range jyutpingOnsets {
+		if strings.HasPrefix(body, onset) {
+			candidates = append(candidates, onset)
+		}
+	}
+	candidates = append(candidates, "")
+
+	for _, onset := range candidates {
+		rest := body[len(onset):]
+		for _, nucleus := range jyutpingNuclei {
+			if !strings.HasPrefix(rest, nucleus) {
+				continue
+			}
+			if _, ok := jyutpingCodas[rest[len(nucleus):]]; ok {
+				return JyutpingSyllable{
+					Initial: onset,
+					Nucleus: nucleus,
+					Coda:    rest[len(nucleus):],
+					Tone:    tone,
+				}, nil
+			}
+		}
+	}
+	return JyutpingSyllable{}, fmt.Errorf("无法解析粤拼: %q", syllable)
+}
+
+// Segment 正向最长匹配分词，返回词和对应词条，未收录的字单独成词且条目为空
+func (l *CantoneseLexicon) Segment(text string) ([]string, []*cantoneseLexiconEntry) {
+	runes := []rune(text)
+	words := []string{}
+	entries := []*cantoneseLexiconEntry{}
+	for i := 0; i < len(runes); {
+		maxLen := l.maxWordLen
+		if maxLen > len(runes)-i {
+			maxLen = len(runes) - i
+		}
+		matched := false
+		for n := maxLen; n >= 1; n-- {
+			word := string(runes[i : i+n])
+			if entry, ok := l.entries[word]; ok {
+				words = append(words, word)
+				entries = append(entries, &entry)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			words = append(words, string(runes[i]))
+			entries = append(entries, nil)
+			i++
+		}
+	}
+	return words, entries
+}
+
+// ToJyutpingList 输出与 pycantonese_service 相同的结构，Cantonese_g2p 可直接使用
+// [{"char": 词, "pinyin": 粤拼, "initial_list": [{"initial","nucleus","coda","tone"}...]}...]
+func (l *CantoneseLexicon) ToJyutpingList(text string) []interface{} {
+	words, entries := l.Segment(text)
+	result := make([]interface{}, 0, len(words))
+	for i, word := range words {
+		initialList := []interface{}{}
+		pinyin := ""
+		if entries[i] == nil {
+			fmt.Printf("粤拼词典没有: %v\n", word)
+		} else {
+			pinyin = entries[i].jyutping
+			for _, syllable := range entries[i].syllables {
+				initialList = append(initialList, map[string]interface{}{
+					"initial": syllable.Initial,
+					"nucleus": syllable.Nucleus,
+					"coda":    syllable.Coda,
+					"tone":    syllable.Tone,
+				})
+			}
+		}
+		result = append(result, map[string]interface{}{
+			"char":         word,
+			"pinyin":       pinyin,
+			"initial_list": initialList,
+		})
+	}
+	return result
+}
+
+// 本地转换多个片段，输入输出与 request_jyuping 一致
+func local_jyutping(sentences map[string]string) map[string]interface{} {
+	response := make(map[string]interface{}, len(sentences))
+	for key, sentence := range sentences {
+		response[key] = cantoneseLexicon.ToJyutpingList(sentence)
+	}
+	return response
+}
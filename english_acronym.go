@@ -0,0 +1,327 @@
+package main
+
+// 英文单词分类：查 cmudict 之前按原文大小写判断单词的读法
+//   1. 用户词典、cmudict 中的整词（DON'T、E-MAIL、McDonald）按词典读
+//   2. 常见按单词读的缩写（NASA、JPEG）按内置读音读
+//   3. 全大写的首字母缩写（GPU、API、AI）逐字母读；两个字母的全大写词即使在词典中（AI、IT、US）也逐字母读，
+//      整段都是大写单词（如强调的整句）时按普通单词读，只有词典中没有的短词或没有元音的词逐字母读
+//   4. 驼峰词（iPhone、YouTube、HTMLParser）按大小写切分，连字符复合词（state-of-the-art、COVID-19）按连字符切分，
+//      字母数字混合词（MP3、4K、x86）切分为字母与数字，各部分分别按以上规则读
+//   5. 其余单词查 cmudict，没有时由字母-音素模型生成
+// 单词按 BERT 的基本分词（字母数字串、单个标点）切分，每部分的音素分配到对应的 token 上，保持 word2ph 与 BERT token 对齐
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// 字母名称的读音
+var englishLetterNames = map[byte]string{
+	'A': "EY1", 'B': "B IY1", 'C': "S IY1", 'D': "D IY1", 'E': "IY1", 'F': "EH1 F", 'G': "JH IY1",
+	'H': "EY1 CH", 'I': "AY1", 'J': "JH EY1", 'K': "K EY1", 'L': "EH1 L", 'M': "EH1 M", 'N': "EH1 N",
+	'O': "OW1", 'P': "P IY1", 'Q': "K Y UW1", 'R': "AA1 R", 'S': "EH1 S", 'T': "T IY1", 'U': "Y UW1",
+	'V': "V IY1", 'W': "D AH1 B AH0 L Y UW0", 'X': "EH1 K S", 'Y': "W AY1", 'Z': "Z IY1",
+}
+
+// 按单词读的缩写，不区分大小写
+var englishPronouncedAcronyms = map[string]string{
+	"NASA":    "N AE1 S AH0",
+	"NATO":    "N EY1 T OW0",
+	"JPEG":    "JH EY1 P EH2 G",
+	"GIF":     "G IH1 F",
+	"COVID":   "K OW1 V IH0 D",
+	"UNESCO":  "Y UW0 N EH1 S K OW0",
+	"UNICEF":  "Y UW1 N IH0 S EH2 F",
+	"FIFA":    "F IY1 F AH0",
+	"IKEA":    "AY0 K IY1 AH0",
+	"AIDS":    "EY1 D Z",
+	"SARS":    "S AA1 R Z",
+	"OPEC":    "OW1 P EH2 K",
+	"NASDAQ":  "N AE1 Z D AE2 K",
+	"ASCII":   "AE1 S K IY0",
+	"CAPTCHA": "K AE1 P CH AH0",
+	"WIFI":    "W AY1 F AY2",
+	"GUI":     "G UW1 IY0",
+	"RAM":     "R AE1 M",
+	"ROM":     "R AA1 M",
+	"SIM":     "S IH1 M",
+	"PIN":     "P IH1 N",
+	"LAN":     "L AE1 N",
+	"WAN":     "W AE1 N",
+	"DOS":     "D AO1 S",
+	"JSON":    "JH EY1 S AH0 N",
+	"YAML":    "Y AE1 M AH0 L",
+	"LASER":   "L EY1 Z ER0",
+	"RADAR":   "R EY1 D AA2 R",
+	"SCUBA":   "S K UW1 B AH0",
+}
+
+// 撇号后的附着词，如 GPU's、NASA'll
+var englishClitics = map[string]string{
+	"S": "Z", "D": "D", "LL": "L", "RE": "ER0", "VE": "V", "M": "M", "T": "T",
+}
+
+// 单词的一部分及其读音，tokens 为对应的 BERT 基本 token 数
+type englishWordPart struct {
+	tokens int
+	phones []string // ARPAbet
+}
+
+// 按 BERT 基本分词切分：连续的字母数字为一个 token，其余字符各为一个 token
+func englishBasicTokens(word string) []string {
+	var tokens []string
+	start := -1
+	for i, r := range word {
+		if r < utf8.RuneSelf && isASCIIAlnum(byte(r)) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, word[start:i])
+			start = -1
+		}
+		tokens = append(tokens, string(r))
+	}
+	if start >= 0 {
+		tokens = append(tokens, word[start:])
+	}
+	return tokens
+}
+
+// 整段是否为大写强调：至少两个 4 个字母以上、在词典中的全大写单词
+func isEnglishShouting(words []string) bool {
+	count := 0
+	for _, word := range words {
+		if len(word) >= 4 && isAllUpperLetters(word) {
+			if _, ok := cmudictCache[word]; ok {
+				count++
+			}
+		}
+	}
+	return count >= 2
+}
+
+// 单词的读音，按基本 token 分段
+func pronounceEnglishWord(word string, lexicon *UserLexicon, shouting bool) ([]englishWordPart, error) {
+	tokens := englishBasicTokens(word)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	// 含撇号、连字符的整词先查词典，如 DON'T、E-MAIL
+	if len(tokens) > 1 {
+		if phones, ok := lookupEnglishDict(word, lexicon); ok {
+			return []englishWordPart{{tokens: len(tokens), phones: phones}}, nil
+		}
+	}
+
+	parts := make([]englishWordPart, 0, len(tokens))
+	for i, token := range tokens {
+		var phones []string
+		switch {
+		case !isASCIIAlnum(token[0]):
+			// 连字符、撇号本身不发音
+		case i > 0 && tokens[i-1] == "'" && englishClitics[strings.ToUpper(token)] != "":
+			phones = strings.Fields(englishClitics[strings.ToUpper(token)])
+		default:
+			var err error
+			if phones, err = pronounceEnglishAlnum(token, lexicon, shouting); err != nil {
+				return nil, err
+			}
+		}
+		parts = append(parts, englishWordPart{tokens: 1, phones: phones})
+	}
+	return parts, nil
+}
+
+// 字母数字串：字母与数字混合时分开读，数字按英文读
+func pronounceEnglishAlnum(token string, lexicon *UserLexicon, shouting bool) ([]string, error) {
+	runs := splitLetterDigitRuns(token)
+	if len(runs) == 1 && !isASCIIDigits(runs[0]) {
+		return pronounceEnglishLetters(token, lexicon, shouting, false)
+	}
+	var phones []string
+	for _, run := range runs {
+		var runPhones []string
+		var err error
+		if isASCIIDigits(run) {
+			runPhones, err = pronounceEnglishWords(englishDigitRunWords(run), lexicon)
+		} else {
+			runPhones, err = pronounceEnglishLetters(run, lexicon, shouting, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		phones = append(phones, runPhones...)
+	}
+	return phones, nil
+}
+
+// 纯字母串，inMix 为字母数字混合词中的字母部分，单个字母读字母名
+func pronounceEnglishLetters(letters string, lexicon *UserLexicon, shouting bool, inMix bool) ([]string, error) {
+	upper := strings.ToUpper(letters)
+	if entry, ok := lexicon.Lookup(LexiconArpabet, upper); ok {
+		return strings.Fields(entry.Phonemes), nil
+	}
+	if acronym, ok := englishPronouncedAcronyms[upper]; ok {
+		return strings.Fields(acronym), nil
+	}
+	if len(letters) == 1 && (inMix || letters == upper && letters != "A" && letters != "I") {
+		return spellEnglishLetters(upper), nil
+	}
+
+	switch {
+	case isAllUpperLetters(letters) && len(letters) > 1:
+		if shouting {
+			if phones, ok := lookupEnglishDict(letters, lexicon); ok {
+				return phones, nil
+			}
+			if len(letters) <= 3 || !strings.ContainsAny(upper, "AEIOUY") {
+				return spellEnglishLetters(upper), nil
+			}
+			return englishWordPhones(letters, lexicon)
+		}
+		if len(letters) > 2 {
+			if phones, ok := lookupEnglishDict(letters, lexicon); ok {
+				return phones, nil
+			}
+		}
+		return spellEnglishLetters(upper), nil
+
+	case isCamelCase(letters):
+		// 整词在词典中时按词典读，如 McDonald
+		if phones, ok := lookupEnglishDict(letters, lexicon); ok {
+			return phones, nil
+		}
+		pieces := splitCamelCase(letters)
+		var phones []string
+		for i, piece := range pieces {
+			var piecePhones []string
+			var err error
+			if piece == "s" && i > 0 && isAllUpperLetters(pieces[i-1]) {
+				// 缩写的复数，如 GPUs
+				piecePhones = []string{"Z"}
+			} else if len(piece) == 1 {
+				piecePhones = spellEnglishLetters(strings.ToUpper(piece))
+			} else if piecePhones, err = pronounceEnglishLetters(piece, lexicon, shouting, false); err != nil {
+				return nil, err
+			}
+			phones = append(phones, piecePhones...)
+		}
+		return phones, nil
+	}
+	return englishWordPhones(letters, lexicon)
+}
+
+// 逐字母读，主重音在最后一个字母上，如 GPU → JH IY2 P IY2 Y UW1
+func spellEnglishLetters(upper string) []string {
+	var phones []string
+	for i := 0; i < len(upper); i++ {
+		for _, phone := range strings.Fields(englishLetterNames[upper[i]]) {
+			if i < len(upper)-1 && strings.HasSuffix(phone, "1") {
+				phone = strings.TrimSuffix(phone, "1") + "2"
+			}
+			phones = append(phones, phone)
+		}
+	}
+	return phones
+}
+
+// 字母数字混合词中的数字：两位以内按数值读（COVID-19 → nineteen），更长或以0开头的逐位读（H264 → two six four）
+func englishDigitRunWords(digits string) []string {
+	if len(digits) > 2 || (len(digits) == 2 && digits[0] == '0') {
+		return strings.Fields(englishDigits(digits))
+	}
+	return strings.Fields(englishInteger(digits))
+}
+
+func pronounceEnglishWords(words []string, lexicon *UserLexicon) ([]string, error) {
+	var phones []string
+	for _, word := range words {
+		wordPhones, err := englishWordPhones(word, lexicon)
+		if err != nil {
+			return nil, err
+		}
+		phones = append(phones, wordPhones...)
+	}
+	return phones, nil
+}
+
+// 查用户词典与 cmudict
+func lookupEnglishDict(word string, lexicon *UserLexicon) ([]string, bool) {
+	upper := strings.ToUpper(word)
+	if entry, ok := lexicon.Lookup(LexiconArpabet, upper); ok {
+		return strings.Fields(entry.Phonemes), true
+	}
+	if pronunciations, ok := cmudictCache[upper]; ok {
+		return pronunciations[0], true
+	}
+	return nil, false
+}
+
+// 按字母与数字切分，如 MP3 → MP 3，x86 → x 86
+func splitLetterDigitRuns(token string) []string {
+	var runs []string
+	start := 0
+	for i := 1; i < len(token); i++ {
+		if isASCIIDigit(token[i]) != isASCIIDigit(token[i-1]) {
+			runs = append(runs, token[start:i])
+			start = i
+		}
+	}
+	return append(runs, token[start:])
+}
+
+// 按大小写切分：iPhone → i Phone，HTMLParser → HTML Parser，GPUs → GPU s
+func splitCamelCase(letters string) []string {
+	var pieces []string
+	start := 0
+	for i := 1; i < len(letters); i++ {
+		lowerToUpper := isASCIILower(letters[i-1]) && isASCIIUpper(letters[i])
+		// 连续大写后接小写时，最后一个大写字母属于下一个词；只有一个 s 时为复数
+		upperToWord := isASCIIUpper(letters[i-1]) && isASCIIUpper(letters[i]) && i+1 < len(letters) &&
+			isASCIILower(letters[i+1]) && letters[i+1:] != "s"
+		if lowerToUpper || upperToWord {
+			pieces = append(pieces, letters[start:i])
+			start = i
+		}
+		if isASCIIUpper(letters[i-1]) && letters[i:] == "s" && i >= 2 && isASCIIUpper(letters[i-2]) {
+			pieces = append(pieces, letters[start:i])
+			start = i
+		}
+	}
+	return append(pieces, letters[start:])
+}
+
+// 大小写混合且不是首字母大写的普通词
+func isCamelCase(letters string) bool {
+	return letters != strings.ToLower(letters) && letters != strings.ToUpper(letters) &&
+		letters[1:] != strings.ToLower(letters[1:])
+}
+
+func isAllUpperLetters(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isASCIIUpper(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIIDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isASCIIDigit(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isASCIIDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isASCIIUpper(c byte) bool { return c >= 'A' && c <= 'Z' }
+func isASCIILower(c byte) bool { return c >= 'a' && c <= 'z' }
@@ -0,0 +1,193 @@
+package main
+
+// 英文发音词典，CMUdict 文本格式：
+//   每行 "WORD  P1 P2 ..."，多音词的其余读音写作 WORD(2)（0.7b 版为 WORD(1)），大小写均可
+//   ;;; 开头为注释，音素后的 "# ..." 为行内注释
+// 主词典依次查找 TTS_CMUDICT、./cmudict.dict、./cmudict-0.7b、编译时嵌入的词典，取第一个；
+// 都没有时兼容旧的 cmudict_cache.pickle
+// 追加词典由 TTS_CMUDICT_EXTRA 指定，多个文件按系统路径列表分隔符分隔（Linux 为 ":"，Windows 为 ";"），
+// 按顺序叠加在主词典之上，出现的单词整体替换之前的全部读音
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nlpodyssey/gopickle/pickle"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+var cmudictPaths = []string{
+	"./cmudict.dict",
+	"./cmudict-0.7b",
+}
+
+const cmudictPicklePath = "cmudict_cache.pickle"
+
+// 编译时嵌入的 CMUdict 文本，见 english_cmudict_embed.go
+var embeddedCMUDict []byte
+
+// 加载主词典，返回词典与来源
+func loadCMUDictBase() (map[string][][]string, string, error) {
+	if path := os.Getenv("TTS_CMUDICT"); path != "" {
+		dict, err := loadCMUDictFile(path)
+		if err != nil {
+			return nil, path, newTTSError(ErrCodeModelNotFound, "加载英文词典失败: "+path, err)
+		}
+		return dict, path, nil
+	}
+
+	for _, path := range cmudictPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		dict, err := loadCMUDictFile(path)
+		if err != nil {
+			return nil, path, newTTSError(ErrCodeModelLoad, "加载英文词典失败: "+path, err)
+		}
+		return dict, path, nil
+	}
+
+	if len(embeddedCMUDict) > 0 {
+		dict, err := parseCMUDict(bytes.NewReader(embeddedCMUDict))
+		if err != nil {
+			return nil, "内置词典", newTTSError(ErrCodeModelLoad, "加载内置英文词典失败", err)
+		}
+		return dict, "内置词典", nil
+	}
+
+	if _, err := os.Stat(cmudictPicklePath); err == nil {
+		dict, err := loadCMUDictPickle(cmudictPicklePath)
+		return dict, cmudictPicklePath, err
+	}
+	return nil, "", newTTSError(ErrCodeModelNotFound, fmt.Sprintf("找不到英文词典，请放置 %s 或设置 TTS_CMUDICT", strings.Join(cmudictPaths, " / ")), nil)
+}
+
+// 追加词典路径
+func cmudictExtraPaths() []string {
+	var paths []string
+	for _, path := range filepath.SplitList(os.Getenv("TTS_CMUDICT_EXTRA")) {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func loadCMUDictFile(path string) (map[string][][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseCMUDict(file)
+}
+
+// 解析 CMUdict 文本，同一单词的多个读音按出现顺序保存，第一个为默认读音
+func parseCMUDict(r io.Reader) (map[string][][]string, error) {
+	dict := make(map[string][][]string)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";;;") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 1; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("第 %d 行缺少音素: %q", lineNo, line)
+		}
+
+		word := strings.ToUpper(fields[0])
+		if open := strings.LastIndex(word, "("); open > 0 && strings.HasSuffix(word, ")") {
+			word = word[:open]
+		}
+		phones := make([]string, 0, len(fields)-1)
+		for _, phone := range fields[1:] {
+			phone = strings.ToUpper(phone)
+			if !isARPAbetPhone(phone) {
+				return nil, fmt.Errorf("第 %d 行音素格式错误: %q", lineNo, phone)
+			}
+			phones = append(phones, phone)
+		}
+		dict[word] = append(dict[word], phones)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// ARPAbet 音素：大写字母，元音后带 0~2 的重音数字
+func isARPAbetPhone(phone string) bool {
+	letters := strings.TrimRight(phone, "012")
+	if letters == "" || len(phone)-len(letters) > 1 {
+		return false
+	}
+	for _, r := range letters {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// 旧格式：MeloTTS 导出的 pickle，单词 → 按音节分组的音素列表
+func loadCMUDictPickle(path string) (map[string][][]string, error) {
+	foo, err := pickle.Load(path)
+	if err != nil {
+		return nil, newTTSError(ErrCodeModelLoad, "加载"+path+"失败", err)
+	}
+	cmudictFoo, ok := foo.(*types.Dict)
+	if !ok {
+		return nil, newTTSError(ErrCodeModelLoad, fmt.Sprintf("%s 格式错误: %T", path, foo), nil)
+	}
+
+	dict := make(map[string][][]string, len(*cmudictFoo))
+	for _, entry := range *cmudictFoo {
+		word, ok := entry.Key.(string)
+		if !ok {
+			continue
+		}
+		syllables, ok := entry.Value.(*types.List)
+		if !ok {
+			return nil, newTTSError(ErrCodeModelLoad, "cmudict 发音格式错误: "+word, nil)
+		}
+		phones, err := cmudictPhones(word, syllables)
+		if err != nil {
+			return nil, err
+		}
+		dict[word] = [][]string{phones}
+	}
+	return dict, nil
+}
+
+// 将 pickle 条目（按音节分组的音素列表）展开为 ARPAbet 音素
+func cmudictPhones(word string, entry *types.List) ([]string, error) {
+	phones := []string{}
+	for i := 0; i < entry.Len(); i++ {
+		syllable, ok := entry.Get(i).(*types.List)
+		if !ok {
+			return nil, newTTSError(ErrCodeModelLoad, "cmudict 发音格式错误: "+word, nil)
+		}
+		for j := 0; j < syllable.Len(); j++ {
+			phone, ok := syllable.Get(j).(string)
+			if !ok {
+				return nil, newTTSError(ErrCodeModelLoad, "cmudict 音素格式错误: "+word, nil)
+			}
+			phones = append(phones, phone)
+		}
+	}
+	return phones, nil
+}
@@ -0,0 +1,13 @@
+package main
+
+// 把 CMUdict 编译进程序：将 cmudict.dict 放在源码目录，并在 build-linux.sh / build-windows.bat 的
+// 文件列表中加入本文件。默认不编译本文件，词典从运行目录加载
+
+import _ "embed"
+
+//go:embed cmudict.dict
+var embeddedCMUDictData []byte
+
+func init() {
+	embeddedCMUDict = embeddedCMUDictData
+}
@@ -14,64 +14,77 @@ import (
 	"strconv"
 	"strings"
 	"slices"
-	"github.com/nlpodyssey/gopickle/types"
-	"github.com/nlpodyssey/gopickle/pickle"
 	"github.com/agnivade/levenshtein"
 )
 
-var cmudictCache map[string]*types.List
+// 单词（大写）→ 读音列表，每个读音为 ARPAbet 音素，第一个为默认读音
+var cmudictCache map[string][][]string
 var cmudictCacheKeys []string
 
-func EnglishResourcePreload() {
+func EnglishResourcePreload() error {
 	if cmudictCache == nil {
-		loadEnglishG2PDict()
+		return loadEnglishG2PDict()
 	}
+	return nil
 }
 
-func loadEnglishG2PDict() {
+// 加载主词典并叠加追加词典，格式与查找顺序见 english_cmudict.go
+func loadEnglishG2PDict() error {
 	fmt.Println("开始加载英语cmudict...")
 	start := time.Now()
 
-	foo, err := pickle.Load("cmudict_cache.pickle") 
+	dict, source, err := loadCMUDictBase()
 	if err != nil {
-		fmt.Printf("load pickle file failed: %v\n", err)
+		return err
 	}
-	cmudictFoo := foo.(*types.Dict)
+	fmt.Printf("加载英文词典 %s 条数: %d\n", source, len(dict))
 
-	//fmt.Printf("load pickle file success: %T \n", cmudictCache)
+	for _, path := range cmudictExtraPaths() {
+		layer, err := loadCMUDictFile(path)
+		if err != nil {
+			return newTTSError(ErrCodeModelLoad, "加载英文追加词典失败: "+path, err)
+		}
+		for word, pronunciations := range layer {
+			dict[word] = pronunciations
+		}
+		fmt.Printf("叠加英文词典 %s 条数: %d\n", path, len(layer))
+	}
 
-	//keys := cmudictFoo.Keys()
-	dictLen := len(*cmudictFoo)
-	cmudictCacheKeys = make([]string, 0, dictLen)
-	cmudictCache = make(map[string]*types.List, dictLen)
+	// 多音词选择读音用的词性标注模型
+	if err := loadEnglishPOSTagger(); err != nil {
+		return err
+	}
 
-	for _, entry := range *cmudictFoo {
-		// entry 结构通常是 {Key: interface{}, Value: interface{}}
-		if s, ok := entry.Key.(string); ok {
-			cmudictCacheKeys = append(cmudictCacheKeys, s)
-			
-			// 直接从 entry.Value 取值，不要再调用 .Get(v) !
-			if val, ok := entry.Value.(*types.List); ok {
-				cmudictCache[s] = val
-			}
-		}
+	keys := make([]string, 0, len(dict))
+	for word := range dict {
+		keys = append(keys, word)
 	}
-	slices.Sort(cmudictCacheKeys)
-	//fmt.Printf("cmudictCacheKeys: %v\n", cmudictCacheKeys)
+	slices.Sort(keys)
+	cmudictCache = dict
+	cmudictCacheKeys = keys
 
 	elapsed := time.Since(start)
-	fmt.Printf("加载cmudict完成条数: %d (耗时: %v)\n", dictLen, elapsed)
-	//test, _ := cmudictCache.Get("WHITEOOK")
-	//fmt.Printf("test: %v\n", test)	
+	fmt.Printf("加载cmudict完成条数: %d (耗时: %v)\n", len(dict), elapsed)
 
+	// 后台训练未登录词的字母-音素模型
+	go getEnglishLTSModel()
+	return nil
 }
 
+// 字母-音素模型无法生成发音时的兜底：在 cmudict 中找编辑距离最小的词
+// 长度差不小于当前最小距离的词不可能更近，跳过计算
 func FindClosestEnglishWord(target string) string {
+	if len(cmudictCacheKeys) == 0 {
+		return ""
+	}
 	closest := cmudictCacheKeys[0]
 	// 计算初始最小距离
 	minDist := levenshtein.ComputeDistance(target, cmudictCacheKeys[0])
 
 	for _, word := range cmudictCacheKeys[1:] {
+		if lengthDiff := len(word) - len(target); lengthDiff >= minDist || -lengthDiff >= minDist {
+			continue
+		}
 		dist := levenshtein.ComputeDistance(target, word)
 		// 如果距离更小，则更新最匹配项
 		if dist < minDist {
@@ -101,9 +114,10 @@ func split_phone_tone(phonetone string) ( string,  int) {
 	return strings.ToLower(phonePart), tone
 }
 
-func English_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string, []int, []int) {
-	if cmudictCache == nil {
-		loadEnglishG2PDict()
+// lexicon 为用户发音词典，可为 nil
+func English_g2p(text string, bertExtractor *BERTFeatureExtractor, lexicon *UserLexicon) ([]string, []int, []int, error) {
+	if err := EnglishResourcePreload(); err != nil {
+		return nil, nil, nil, err
 	}
 
 	en_phones := []string{}
@@ -120,7 +134,7 @@ func English_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string, []
 		if !strings.HasPrefix(token, "##") {
 			wordparts := []string{token}
 			groups = append(groups, wordparts)
-		}else{
+		}else if len(groups) > 0 {
 			lastGroup := groups[len(groups)-1].([]string)
 				
 			curWordPart := strings.TrimPrefix(token, "##")
@@ -130,69 +144,111 @@ func English_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string, []
 	}
 	//fmt.Println("english groups:", groups)
 
-	//对每个group进行g2p
-	for _, wordparts := range groups {
-		word := strings.Join(wordparts.([]string), "")
-		wordUp := strings.ToUpper(word)
-
-		var cmuPhones *types.List
-		if val, ok := cmudictCache[wordUp]; ok {
-			//fmt.Printf("%v g2p: %v\n", word,val)
-			cmuPhones = val
-		}else{
-			fmt.Printf("g2p单词表没有: %v\n", wordUp)	
-			start := time.Now()
-			closest := FindClosestEnglishWord(wordUp)
-			elapsed := time.Since(start)
-			fmt.Printf("找最相近的: %v (耗时: %v)\n", closest, elapsed)
-			//fmt.Printf("closest: %v\n", closest)
-			if closestVal, ok := cmudictCache[closest]; ok {
-				fmt.Printf("%v g2p: %v\n", closest, closestVal)
-				cmuPhones = closestVal
-			}else{
-				fmt.Printf("g2p单词表没有: %v\n", closest)
-			}
+	// 按空格切出保留大小写的原词，每个原词按基本分词对应若干个group；
+	// 数量对不上时（如分词器输出了意外的token）退回按group逐个处理，丢失大小写信息
+	words := strings.Fields(text)
+	wordTokenCounts := make([]int, len(words))
+	basicCount := 0
+	for i, word := range words {
+		wordTokenCounts[i] = len(englishBasicTokens(word))
+		basicCount += wordTokenCounts[i]
+	}
+	if basicCount != len(groups) {
+		fmt.Printf("英文原词与BERT分词没有对齐: %q\n", text)
+		words = words[:0]
+		wordTokenCounts = wordTokenCounts[:0]
+		for _, wordparts := range groups {
+			words = append(words, strings.Join(wordparts.([]string), ""))
+			wordTokenCounts = append(wordTokenCounts, 1)
+		}
+	}
+	shouting := isEnglishShouting(words)
+	// 词性用于多音词选择读音，见 english_heteronym.go
+	tags := tagEnglishWords(words)
+
+	//对每个单词进行g2p
+	groupIndex := 0
+	for i, word := range words {
+		var parts []englishWordPart
+		var err error
+		if phones, ok := resolveEnglishHeteronym(word, tags[i], lexicon); ok && !shouting {
+			parts = []englishWordPart{{tokens: wordTokenCounts[i], phones: phones}}
+		} else if parts, err = pronounceEnglishWord(word, lexicon, shouting); err != nil {
+			return nil, nil, nil, err
+		}
+		if len(parts) == 0 {
+			parts = []englishWordPart{{tokens: wordTokenCounts[i]}}
 		}
 
-		oneword_phone_count := 0
-		for i := 0; i < cmuPhones.Len(); i++ {
-			cmuPhone := cmuPhones.Get(i).(*types.List)
-			oneword_phone_count += cmuPhone.Len()
-			for j := 0; j < cmuPhone.Len(); j++ {
-				_cmuPhone := cmuPhone.Get(j).(string)
+		for _, part := range parts {
+			for _, _cmuPhone := range part.phones {
 				phonePart, tone := split_phone_tone(_cmuPhone)
 				//fmt.Printf("%v %v\n", phonePart, tone)
 				en_phones = append(en_phones, phonePart)
 				en_tones = append(en_tones, tone)
 			}
-		}
 
-		oneword_token_count := len(wordparts.([]string))
-
-		// 一个单词有多少个token, 音素，将音素数量均分到每个token上
-		// 最终效果是每个token对应多少音素
-		oneword_word2ph :=  make([]int, oneword_token_count)
-		// 2. 迭代分配每一个音素
-		for i := 0; i < oneword_phone_count; i++ {
-			// 3. 寻找当前分配值最小的索引
-			minIndex := 0
-			minTasks := oneword_word2ph[0]
-
-			for j := 1; j < oneword_token_count; j++ {
-				if oneword_word2ph[j] < minTasks {
-					minTasks = oneword_word2ph[j]
-					minIndex = j
-				}
+			oneword_token_count := 0
+			for _, wordparts := range groups[groupIndex : groupIndex+part.tokens] {
+				oneword_token_count += len(wordparts.([]string))
 			}
+			groupIndex += part.tokens
 
-			// 4. 给分配最少的 Token 增加一个音素
-			oneword_word2ph[minIndex]++
+			// 一个单词有多少个token, 音素，将音素数量均分到每个token上
+			// 最终效果是每个token对应多少音素
+			oneword_word2ph := distributePhones(len(part.phones), oneword_token_count)
+
+			// oneword_word2ph 拼接到 en_word2ph
+			en_word2ph = append(en_word2ph, oneword_word2ph...)
 		}
+	}
+	
+	return 	en_phones ,en_tones, en_word2ph, nil
+}
 
-		// oneword_word2ph 拼接到 en_word2ph
-		en_word2ph = append(en_word2ph, oneword_word2ph...)
+// 普通单词的读音：用户词典、cmudict，没有时由字母-音素模型生成，再不行找最相近的词
+func englishWordPhones(word string, lexicon *UserLexicon) ([]string, error) {
+	wordUp := strings.ToUpper(word)
+	if phones, ok := lookupEnglishDict(wordUp, lexicon); ok {
+		return phones, nil
+	}
+	if predicted := getEnglishLTSModel().Predict(wordUp); len(predicted) > 0 {
+		// 未登录词由字母-音素模型直接生成发音
+		fmt.Printf("g2p单词表没有: %v, 模型生成: %v\n", wordUp, predicted)
+		return predicted, nil
+	}
 
+	fmt.Printf("g2p单词表没有: %v\n", wordUp)	
+	start := time.Now()
+	closest := FindClosestEnglishWord(wordUp)
+	elapsed := time.Since(start)
+	fmt.Printf("找最相近的: %v (耗时: %v)\n", closest, elapsed)
+	//fmt.Printf("closest: %v\n", closest)
+	if closestVal, ok := cmudictCache[closest]; ok {
+		fmt.Printf("%v g2p: %v\n", closest, closestVal[0])
+		return closestVal[0], nil
 	}
-	
-	return 	en_phones ,en_tones, en_word2ph
+	return nil, newTTSError(ErrCodeG2P, "无法获取英文单词发音: "+word, nil)
+}
+
+// 将 phoneCount 个音素均分到 tokenCount 个token上，返回每个token的音素数
+func distributePhones(phoneCount int, tokenCount int) []int {
+	word2ph := make([]int, tokenCount)
+	// 迭代分配每一个音素
+	for i := 0; i < phoneCount; i++ {
+		// 寻找当前分配值最小的索引
+		minIndex := 0
+		minTasks := word2ph[0]
+
+		for j := 1; j < tokenCount; j++ {
+			if word2ph[j] < minTasks {
+				minTasks = word2ph[j]
+				minIndex = j
+			}
+		}
+
+		// 给分配最少的 Token 增加一个音素
+		word2ph[minIndex]++
+	}
+	return word2ph
 }
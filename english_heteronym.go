@@ -0,0 +1,162 @@
+package main
+
+// 英文多音词：按词性从几种读音中选一个，每个词只输出一种读音
+// 先查下表；表中没有、而 cmudict 有多个读音的词按通用规则在词典读音中选：
+//   双音节的名词/动词重音转移（RECORD、PERMIT），名词、形容词取重音在前的读音，动词取重音在后的读音
+//   -ATE 结尾的词（SEPARATE、ESTIMATE），动词取末尾读 EY 的读音，名词、形容词取弱读的读音
+// 用户词典中有的词、全大写的缩写不在这里处理
+
+import (
+	"strings"
+)
+
+// 多音词的一种读音，tags 为适用的词性标记前缀（"VB" 匹配全部动词标记），为空时作为默认读音
+type englishHeteronymReading struct {
+	tags   []string
+	phones string
+}
+
+var englishHeteronyms = map[string][]englishHeteronymReading{
+	"READ":     {{[]string{"VBD", "VBN"}, "R EH1 D"}, {nil, "R IY1 D"}},
+	"LIVE":     {{[]string{"VB"}, "L IH1 V"}, {nil, "L AY1 V"}},
+	"LIVES":    {{[]string{"VB"}, "L IH1 V Z"}, {nil, "L AY1 V Z"}},
+	"LEAD":     {{[]string{"JJ"}, "L EH1 D"}, {nil, "L IY1 D"}},
+	"WIND":     {{[]string{"VB"}, "W AY1 N D"}, {nil, "W IH1 N D"}},
+	"WINDS":    {{[]string{"VB"}, "W AY1 N D Z"}, {nil, "W IH1 N D Z"}},
+	"TEAR":     {{[]string{"VB"}, "T EH1 R"}, {nil, "T IH1 R"}},
+	"TEARS":    {{[]string{"VB"}, "T EH1 R Z"}, {nil, "T IH1 R Z"}},
+	"WOUND":    {{[]string{"VBD", "VBN"}, "W AW1 N D"}, {nil, "W UW1 N D"}},
+	"CLOSE":    {{[]string{"JJ", "RB"}, "K L OW1 S"}, {nil, "K L OW1 Z"}},
+	"USE":      {{[]string{"NN"}, "Y UW1 S"}, {nil, "Y UW1 Z"}},
+	"EXCUSE":   {{[]string{"NN"}, "IH0 K S K Y UW1 S"}, {nil, "IH0 K S K Y UW1 Z"}},
+	"ABUSE":    {{[]string{"NN"}, "AH0 B Y UW1 S"}, {nil, "AH0 B Y UW1 Z"}},
+	"HOUSE":    {{[]string{"VB"}, "HH AW1 Z"}, {nil, "HH AW1 S"}},
+	"MINUTE":   {{[]string{"JJ"}, "M AY0 N UW1 T"}, {nil, "M IH1 N AH0 T"}},
+	"DOVE":     {{[]string{"VBD"}, "D OW1 V"}, {nil, "D AH1 V"}},
+	"BOW":      {{[]string{"VB"}, "B AW1"}, {nil, "B OW1"}},
+	"DESERT":   {{[]string{"VB"}, "D IH0 Z ER1 T"}, {nil, "D EH1 Z ER0 T"}},
+	"CONTENT":  {{[]string{"NN"}, "K AA1 N T EH0 N T"}, {nil, "K AH0 N T EH1 N T"}},
+	"RECORD":   {{[]string{"VB"}, "R IH0 K AO1 R D"}, {nil, "R EH1 K ER0 D"}},
+	"PRESENT":  {{[]string{"VB"}, "P R IY0 Z EH1 N T"}, {nil, "P R EH1 Z AH0 N T"}},
+	"OBJECT":   {{[]string{"VB"}, "AH0 B JH EH1 K T"}, {nil, "AA1 B JH EH0 K T"}},
+	"PROJECT":  {{[]string{"VB"}, "P R AH0 JH EH1 K T"}, {nil, "P R AA1 JH EH0 K T"}},
+	"PERMIT":   {{[]string{"VB"}, "P ER0 M IH1 T"}, {nil, "P ER1 M IH2 T"}},
+	"CONDUCT":  {{[]string{"VB"}, "K AH0 N D AH1 K T"}, {nil, "K AA1 N D AH0 K T"}},
+	"CONTRACT": {{[]string{"VB"}, "K AH0 N T R AE1 K T"}, {nil, "K AA1 N T R AE2 K T"}},
+	"INCREASE": {{[]string{"VB"}, "IH0 N K R IY1 S"}, {nil, "IH1 N K R IY2 S"}},
+	"DECREASE": {{[]string{"VB"}, "D IH0 K R IY1 S"}, {nil, "D IY1 K R IY2 S"}},
+	"PRODUCE":  {{[]string{"VB"}, "P R AH0 D UW1 S"}, {nil, "P R OW1 D UW0 S"}},
+	"REFUSE":   {{[]string{"NN"}, "R EH1 F Y UW2 Z"}, {nil, "R IH0 F Y UW1 Z"}},
+	"REBEL":    {{[]string{"VB"}, "R IH0 B EH1 L"}, {nil, "R EH1 B AH0 L"}},
+	"SUBJECT":  {{[]string{"VB"}, "S AH0 B JH EH1 K T"}, {nil, "S AH1 B JH IH0 K T"}},
+	"PROGRESS": {{[]string{"VB"}, "P R AH0 G R EH1 S"}, {nil, "P R AA1 G R EH2 S"}},
+	"INVALID":  {{[]string{"NN"}, "IH1 N V AH0 L AH0 D"}, {nil, "IH0 N V AE1 L AH0 D"}},
+}
+
+// 多音词按词性选出的读音；不是多音词时返回 false
+func resolveEnglishHeteronym(word string, tag string, lexicon *UserLexicon) ([]string, bool) {
+	if !isEnglishPlainWord(word) {
+		return nil, false
+	}
+	upper := strings.ToUpper(word)
+	if _, ok := lexicon.Lookup(LexiconArpabet, upper); ok {
+		return nil, false
+	}
+	if readings, ok := englishHeteronyms[upper]; ok {
+		for _, reading := range readings {
+			if reading.tags == nil || matchEnglishTag(tag, reading.tags) {
+				return strings.Fields(reading.phones), true
+			}
+		}
+	}
+	if variants := cmudictCache[upper]; len(variants) > 1 {
+		if phones := selectEnglishVariant(upper, variants, tag); phones != nil {
+			return phones, true
+		}
+	}
+	return nil, false
+}
+
+// 只含字母，且不是两个字母以上的全大写缩写
+func isEnglishPlainWord(word string) bool {
+	if word == "" || !isAllLetters(word) {
+		return false
+	}
+	return len(word) == 1 || !isAllUpperLetters(word)
+}
+
+func isAllLetters(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if !isASCIILetter(word[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchEnglishTag(tag string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按通用规则在 cmudict 的多个读音中选择，规则不适用时返回 nil
+func selectEnglishVariant(upper string, variants [][]string, tag string) []string {
+	nominal := strings.HasPrefix(tag, "NN") || strings.HasPrefix(tag, "JJ")
+	verbal := strings.HasPrefix(tag, "VB")
+	if !nominal && !verbal {
+		return nil
+	}
+
+	var nounVariant, verbVariant []string
+	for _, phones := range variants {
+		vowels, primary, last := englishStressProfile(phones)
+		switch {
+		case strings.HasSuffix(upper, "ATE") && vowels >= 2:
+			if last == "EY" {
+				verbVariant = firstNonNil(verbVariant, phones)
+			} else {
+				nounVariant = firstNonNil(nounVariant, phones)
+			}
+		case vowels == 2 && primary == 0:
+			nounVariant = firstNonNil(nounVariant, phones)
+		case vowels == 2 && primary == 1:
+			verbVariant = firstNonNil(verbVariant, phones)
+		}
+	}
+	// 两种读音都存在时才说明是按词性区分的多音词
+	if nounVariant == nil || verbVariant == nil {
+		return nil
+	}
+	if verbal {
+		return verbVariant
+	}
+	return nounVariant
+}
+
+// 元音数、主重音所在的元音序号、最后一个元音（不带重音数字）
+func englishStressProfile(phones []string) (int, int, string) {
+	vowels, primary, last := 0, -1, ""
+	for _, phone := range phones {
+		stress := phone[len(phone)-1]
+		if stress < '0' || stress > '2' {
+			continue
+		}
+		if stress == '1' && primary < 0 {
+			primary = vowels
+		}
+		last = phone[:len(phone)-1]
+		vowels++
+	}
+	return vowels, primary, last
+}
+
+func firstNonNil(current []string, candidate []string) []string {
+	if current != nil {
+		return current
+	}
+	return candidate
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 英文未登录词的字母-音素转换（letter-to-sound），加载 cmudict 后在后台以词典本身为语料训练：
+// 1. 对齐：每个字母对应 0、1 或 2 个音素（如 PHONE 中的 H 不发音、X→K S），硬 EM 反复用 Viterbi 求对齐、按对齐结果重估概率
+// 2. 上下文规则：以字母左右各 0~ltsMaxContext 个字母为上下文统计对应的音素（含重音标记），
+//    较宽的上下文只保留与较窄上下文预测结果不同的条目；预测时从最宽的上下文开始查找，逐级回退
+// 3. 重音：预测结果保证恰好一个主重音
+// 训练需要数秒，期间遇到未登录词的请求会等待训练完成
+
+const (
+	ltsMaxContext      = 4
+	ltsAlignIterations = 4
+	ltsMinProbability  = 1e-6
+	ltsLetterCount     = 27 // A~Z 与撇号
+	ltsPadding         = '#'
+)
+
+var (
+	englishLTSOnce  sync.Once
+	englishLTSModel *EnglishLTSModel
+)
+
+// 字母-音素模型，训练完成后只读，并发安全
+type EnglishLTSModel struct {
+	tables []map[string]int32 // tables[k]：左右各 k 个字母的上下文 → 标签
+	labels []string           // 标签为空格分隔的 ARPAbet 音素，labels[0] 为空即不发音
+}
+
+// 返回训练好的模型，首次调用时训练；cmudict 为空时返回 nil
+func getEnglishLTSModel() *EnglishLTSModel {
+	englishLTSOnce.Do(func() {
+		start := time.Now()
+		dict := make(map[string][]string, len(cmudictCache))
+		for word, pronunciations := range cmudictCache {
+			dict[word] = pronunciations[0]
+		}
+		if len(dict) == 0 {
+			return
+		}
+		englishLTSModel = trainEnglishLTS(dict)
+		rules := 0
+		for _, table := range englishLTSModel.tables {
+			rules += len(table)
+		}
+		fmt.Printf("英语字母-音素模型训练完成, 规则数: %d (耗时: %v)\n", rules, time.Since(start))
+	})
+	return englishLTSModel
+}
+
+// 预测单词的 ARPAbet 发音（元音带重音数字），单词中没有字母时返回 nil
+func (m *EnglishLTSModel) Predict(word string) []string {
+	letters := ltsNormalizeWord(word)
+	if m == nil || letters == "" {
+		return nil
+	}
+	padded := ltsPadWord(letters)
+	var phones []string
+	for i := range letters {
+		center := i + ltsMaxContext
+		label := m.predictKey(padded[center-ltsMaxContext : center+ltsMaxContext+1])
+		phones = append(phones, strings.Fields(m.labels[label])...)
+	}
+	return ltsFixStress(phones)
+}
+
+// 按上下文窗口查找标签，窗口长度为奇数、中心为当前字母，从宽到窄回退
+func (m *EnglishLTSModel) predictKey(key string) int32 {
+	center := len(key) / 2
+	for k := min(center, len(m.tables)-1); k >= 0; k-- {
+		if label, ok := m.tables[k][key[center-k:center+k+1]]; ok {
+			return label
+		}
+	}
+	return 0
+}
+
+// 训练用的单词
+type ltsWord struct {
+	letters string
+	phones  []string // 带重音
+	base    []int    // 去掉重音后的音素编号，用于对齐
+	steps   []int8   // 对齐结果，每个字母对应的音素数
+}
+
+func trainEnglishLTS(dict map[string][]string) *EnglishLTSModel {
+	// 按字母序处理，保证结果与 map 遍历顺序无关
+	keys := make([]string, 0, len(dict))
+	for word := range dict {
+		keys = append(keys, word)
+	}
+	slices.Sort(keys)
+
+	baseIDs := map[string]int{}
+	var words []*ltsWord
+	for _, word := range keys {
+		phones := dict[word]
+		if ltsNormalizeWord(word) != word || len(phones) == 0 || len(phones) > 2*len(word) {
+			continue
+		}
+		w := &ltsWord{letters: word, phones: phones, base: make([]int, len(phones))}
+		for i, phone := range phones {
+			base := strings.TrimRight(phone, "0123456789")
+			id, ok := baseIDs[base]
+			if !ok {
+				id = len(baseIDs)
+				baseIDs[base] = id
+			}
+			w.base[i] = id
+		}
+		words = append(words, w)
+	}
+
+	alignWords(words, len(baseIDs))
+	return buildLTSTables(words)
+}
+
+// 硬 EM 对齐。类别编号：0 不发音，1+a 单个音素 a，1+n+a*n+b 两个音素 a b
+func alignWords(words []*ltsWord, numBase int) {
+	numClasses := 1 + numBase + numBase*numBase
+	counts := make([][]float64, ltsLetterCount)
+	for l := range counts {
+		counts[l] = make([]float64, numClasses)
+	}
+
+	// 初始值：字母与同一单词中出现的每个音素共现计数，按两者在词中相对位置的远近加权，
+	// 两个音素的组合与不发音给较小的权重
+	for _, w := range words {
+		n, m := float64(len(w.letters)), float64(len(w.base))
+		for i := 0; i < len(w.letters); i++ {
+			l := ltsLetterIndex(w.letters[i])
+			counts[l][0] += 0.5
+			for j, a := range w.base {
+				weight := 1 / (1 + math.Abs((float64(i)+0.5)*m/n-float64(j)-0.5))
+				counts[l][1+a] += weight
+				if j+1 < len(w.base) {
+					counts[l][1+numBase+a*numBase+w.base[j+1]] += 0.1 * weight
+				}
+			}
+		}
+	}
+
+	logProbs := make([][]float64, ltsLetterCount)
+	for iteration := 0; iteration <= ltsAlignIterations; iteration++ {
+		for l, row := range counts {
+			total := 0.0
+			for _, count := range row {
+				total += count
+			}
+			logProbs[l] = make([]float64, numClasses)
+			for c, count := range row {
+				p := ltsMinProbability
+				if total > 0 {
+					p = math.Max(count/total, ltsMinProbability)
+				}
+				logProbs[l][c] = math.Log(p)
+				row[c] = 0
+			}
+		}
+		for _, w := range words {
+			w.steps = alignLTSWord(w, logProbs, numBase)
+			j := 0
+			for i, step := range w.steps {
+				l := ltsLetterIndex(w.letters[i])
+				switch step {
+				case 0:
+					counts[l][0]++
+				case 1:
+					counts[l][1+w.base[j]]++
+				case 2:
+					counts[l][1+numBase+w.base[j]*numBase+w.base[j+1]]++
+				}
+				j += int(step)
+			}
+		}
+	}
+}
+
+// Viterbi 对齐，返回每个字母对应的音素数（0~2）
+func alignLTSWord(w *ltsWord, logProbs [][]float64, numBase int) []int8 {
+	n, m := len(w.letters), len(w.base)
+	width := m + 1
+	score := make([]float64, (n+1)*width)
+	back := make([]int8, (n+1)*width)
+	for i := range score {
+		score[i] = math.Inf(-1)
+	}
+	score[0] = 0
+
+	for i := 0; i < n; i++ {
+		row := logProbs[ltsLetterIndex(w.letters[i])]
+		for j := 0; j <= m; j++ {
+			s := score[i*width+j]
+			if math.IsInf(s, -1) {
+				continue
+			}
+			relax := func(step int, class int) {
+				next := (i+1)*width + j + step
+				if candidate := s + row[class]; candidate > score[next] {
+					score[next] = candidate
+					back[next] = int8(step)
+				}
+			}
+			relax(0, 0)
+			if j < m {
+				relax(1, 1+w.base[j])
+			}
+			if j+1 < m {
+				relax(2, 1+numBase+w.base[j]*numBase+w.base[j+1])
+			}
+		}
+	}
+
+	steps := make([]int8, n)
+	for i, j := n, m; i > 0; i-- {
+		steps[i-1] = back[i*width+j]
+		j -= int(steps[i-1])
+	}
+	return steps
+}
+
+// 按对齐结果统计各宽度上下文的标签，只保留与回退结果不同的条目
+func buildLTSTables(words []*ltsWord) *EnglishLTSModel {
+	model := &EnglishLTSModel{labels: []string{""}}
+	labelIDs := map[string]int32{"": 0}
+
+	type sample struct {
+		padded   string
+		position int
+		label    int32
+	}
+	var samples []sample
+	for _, w := range words {
+		padded := ltsPadWord(w.letters)
+		j := 0
+		for i, step := range w.steps {
+			label := strings.Join(w.phones[j:j+int(step)], " ")
+			id, ok := labelIDs[label]
+			if !ok {
+				id = int32(len(model.labels))
+				labelIDs[label] = id
+				model.labels = append(model.labels, label)
+			}
+			samples = append(samples, sample{padded: padded, position: i + ltsMaxContext, label: id})
+			j += int(step)
+		}
+	}
+
+	type record struct {
+		key   string
+		label int32
+	}
+	records := make([]record, len(samples))
+	for k := 0; k <= ltsMaxContext; k++ {
+		for i, s := range samples {
+			records[i] = record{key: s.padded[s.position-k : s.position+k+1], label: s.label}
+		}
+		slices.SortFunc(records, func(a, b record) int {
+			if c := strings.Compare(a.key, b.key); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.label, b.label)
+		})
+
+		table := map[string]int32{}
+		for start := 0; start < len(records); {
+			key := records[start].key
+			best, bestCount := int32(0), 0
+			end := start
+			for end < len(records) && records[end].key == key {
+				runEnd := end
+				for runEnd < len(records) && records[runEnd].key == key && records[runEnd].label == records[end].label {
+					runEnd++
+				}
+				if runEnd-end > bestCount {
+					best, bestCount = records[end].label, runEnd-end
+				}
+				end = runEnd
+			}
+			if k == 0 || model.predictKey(key[1:len(key)-1]) != best {
+				table[key] = best
+			}
+			start = end
+		}
+		model.tables = append(model.tables, table)
+	}
+	return model
+}
+
+// 保证恰好一个主重音：多个主重音时保留第一个，其余降为次重音；
+// 没有主重音时依次提升第一个次重音、第一个非 AH0 的元音、第一个元音
+func ltsFixStress(phones []string) []string {
+	primary := -1
+	var vowels []int
+	for i, phone := range phones {
+		stress := phone[len(phone)-1]
+		if stress < '0' || stress > '9' {
+			continue
+		}
+		vowels = append(vowels, i)
+		if stress == '1' {
+			if primary < 0 {
+				primary = i
+			} else {
+				phones[i] = phone[:len(phone)-1] + "2"
+			}
+		}
+	}
+	if primary >= 0 || len(vowels) == 0 {
+		return phones
+	}
+
+	primary = vowels[0]
+	for _, i := range vowels {
+		if strings.HasSuffix(phones[i], "2") {
+			primary = i
+			break
+		}
+	}
+	if !strings.HasSuffix(phones[primary], "2") {
+		for _, i := range vowels {
+			if phones[i] != "AH0" {
+				primary = i
+				break
+			}
+		}
+	}
+	phones[primary] = phones[primary][:len(phones[primary])-1] + "1"
+	return phones
+}
+
+// 转大写，只保留字母与撇号
+func ltsNormalizeWord(word string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToUpper(word) {
+		if (r >= 'A' && r <= 'Z') || r == '\'' {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func ltsPadWord(letters string) string {
+	padding := strings.Repeat(string(ltsPadding), ltsMaxContext)
+	return padding + letters + padding
+}
+
+func ltsLetterIndex(letter byte) int {
+	if letter == '\'' {
+		return 26
+	}
+	return int(letter - 'A')
+}
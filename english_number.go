@@ -0,0 +1,501 @@
+package main
+
+// 英文语境中的数字读法：数字两侧最近的文字是英文字母、且两侧都不是汉字时按英文读，改写为英文单词后交给英文g2p，
+// 如 "I have 3 apples"、"$5.99"、"2nd of May"、"at 10:30 pm"、"in 1999"；汉字语境仍由 NormalizeChineseText 读作中文
+// 与字母直接相连的数字（MP3、COVID-19）不在这里处理，由英文g2p按字母数字混合词读
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var englishOnesWords = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+	"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+
+var englishTensWords = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+
+var englishScaleWords = []string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"}
+
+// 不规则序数词，其余加 th，-ty 结尾改为 -tieth
+var englishIrregularOrdinals = map[string]string{
+	"one": "first", "two": "second", "three": "third", "five": "fifth",
+	"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+}
+
+var englishMonthNames = []string{"january", "february", "march", "april", "may", "june",
+	"july", "august", "september", "october", "november", "december"}
+
+// 货币：单数、复数、辅币单数、辅币复数，辅币为空时小数部分按 point 读
+var englishCurrencyNames = map[string][4]string{
+	"$": {"dollar", "dollars", "cent", "cents"},
+	"€": {"euro", "euros", "cent", "cents"},
+	"£": {"pound", "pounds", "penny", "pence"},
+	"¥": {"yen", "yen", "", ""},
+	"￥": {"yen", "yen", "", ""},
+}
+
+// 计量单位：单数、复数，key 区分大小写
+var englishUnitNames = map[string][2]string{
+	"km": {"kilometer", "kilometers"}, "cm": {"centimeter", "centimeters"}, "mm": {"millimeter", "millimeters"},
+	"kg": {"kilogram", "kilograms"}, "lb": {"pound", "pounds"}, "lbs": {"pound", "pounds"}, "oz": {"ounce", "ounces"},
+	"ft": {"foot", "feet"}, "mph": {"mile per hour", "miles per hour"}, "ml": {"milliliter", "milliliters"},
+	"KB": {"kilobyte", "kilobytes"}, "MB": {"megabyte", "megabytes"}, "GB": {"gigabyte", "gigabytes"}, "TB": {"terabyte", "terabytes"},
+	"Hz": {"hertz", "hertz"}, "kHz": {"kilohertz", "kilohertz"}, "MHz": {"megahertz", "megahertz"}, "GHz": {"gigahertz", "gigahertz"},
+	"kW": {"kilowatt", "kilowatts"}, "kWh": {"kilowatt hour", "kilowatt hours"},
+	"s": {"second", "seconds"}, "ms": {"millisecond", "milliseconds"}, "min": {"minute", "minutes"},
+}
+
+const englishNumberPattern = `(\d{1,3}(?:,\d{3})+|\d+)`
+
+// 数字后可接的单位，见 englishUnitNames
+const englishUnitPattern = `(?:km|cm|mm|kg|lbs|lb|oz|ft|mph|ml|KB|MB|GB|TB|Hz|kHz|MHz|GHz|kWh|kW|min|ms|s)\b`
+
+var (
+	// 2024-10-18
+	reEnglishISODate = regexp.MustCompile(`(\d{4})-(\d{1,2})-(\d{1,2})`)
+
+	// 10:30 / 10:30 pm / 9:05a.m.
+	reEnglishTime = regexp.MustCompile(`(\d{1,2}):(\d{2})(?:\s?([AaPp])\.?[Mm]\.?)?`)
+
+	// 5pm / 11 a.m.
+	reEnglishHourTime = regexp.MustCompile(`(\d{1,2})\s?([AaPp])\.?[Mm]\b\.?`)
+
+	// 555-1234 / 212-555-1234 / (212) 555-1234
+	reEnglishPhone = regexp.MustCompile(`(?:\((\d{3})\)\s?|(\d{3})-)?([2-9]\d{2})-(\d{4})`)
+
+	// 1990s / the 80s / '90s
+	reEnglishDecade = regexp.MustCompile(`('?)(\d{2})?(\d)0s\b`)
+
+	// $5.99 / $1.5 million
+	reEnglishCurrency = regexp.MustCompile(`([$€£¥￥])\s?` + englishNumberPattern + `(?:\.(\d+))?(?:\s(thousand|million|billion|trillion)\b)?`)
+
+	// 50% / -3.5%
+	reEnglishPercent = regexp.MustCompile(`(-?)` + englishNumberPattern + `(?:\.(\d+))?\s?%`)
+
+	// 2nd / 21st / 100th
+	reEnglishOrdinal = regexp.MustCompile(`(\d+)(?i:st|nd|rd|th)\b`)
+
+	// May 2 / Oct. 18, 2024
+	reEnglishMonthDay = regexp.MustCompile(`(?i)\b(january|february|march|april|may|june|july|august|september|october|november|december|` +
+		`jan|feb|mar|apr|jun|jul|aug|sept|sep|oct|nov|dec)\.?\s(\d{1,2})\b(?:,?\s(\d{4})\b)?`)
+
+	// 10-20 / 10~20 / 10-20km / 10-20%
+	reEnglishRange = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?[-~–]\s?(\d+(?:\.\d+)?)(?:\s?(%|` + englishUnitPattern + `))?`)
+
+	// -5 / 1,000 / 3.14 / 10 km
+	reEnglishNumber = regexp.MustCompile(`(-?)` + englishNumberPattern + `(?:\.(\d+))?(?:\s?(` + englishUnitPattern + `))?`)
+)
+
+// 把英文语境中的数字改写为英文单词
+func normalizeEnglishNumbers(text string) string {
+	text = replaceEnglishNumber(reEnglishISODate, text, isEnglishContext, func(m []string) string {
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return m[0]
+		}
+		return englishMonthNames[month-1] + " " + englishOrdinal(uint64(day)) + " " + englishYear(m[1])
+	})
+	text = replaceEnglishNumber(reEnglishTime, text, isEnglishContext, func(m []string) string {
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour > 24 || minute > 59 {
+			return m[0]
+		}
+		result := englishCardinal(uint64(hour))
+		switch {
+		case minute == 0 && m[3] == "":
+			result += " o'clock"
+		case minute == 0:
+		case minute < 10:
+			result += " oh " + englishCardinal(uint64(minute))
+		default:
+			result += " " + englishCardinal(uint64(minute))
+		}
+		if m[3] != "" {
+			// 大写两个字母，由英文g2p逐字母读
+			result += " " + strings.ToUpper(m[3]) + "M"
+		}
+		return result
+	})
+	text = replaceEnglishNumber(reEnglishHourTime, text, isEnglishWordContext, func(m []string) string {
+		hour, _ := strconv.Atoi(m[1])
+		if hour < 1 || hour > 12 {
+			return m[0]
+		}
+		return englishCardinal(uint64(hour)) + " " + strings.ToUpper(m[2]) + "M"
+	})
+	text = replaceEnglishNumber(reEnglishPhone, text, isEnglishContext, func(m []string) string {
+		// 电话号码逐位读，各组之间停顿
+		var groups []string
+		for _, group := range m[1:] {
+			if group != "" {
+				groups = append(groups, englishDigits(group))
+			}
+		}
+		return strings.Join(groups, ", ")
+	})
+	text = replaceEnglishNumber(reEnglishDecade, text, isEnglishDecadeContext, func(m []string) string {
+		return englishDecade(m[2], m[3])
+	})
+	text = replaceEnglishNumber(reEnglishCurrency, text, isEnglishWordContext, func(m []string) string {
+		names := englishCurrencyNames[m[1]]
+		integer := strings.ReplaceAll(m[2], ",", "")
+		if m[4] != "" {
+			// $1.5 million → one point five million dollars
+			return englishDecimal(integer, m[3]) + " " + m[4] + " " + names[1]
+		}
+		value, err := strconv.ParseUint(integer, 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		if names[2] == "" || len(m[3]) > 2 {
+			return englishDecimal(integer, m[3]) + " " + englishPlural(value == 1 && m[3] == "", names[0], names[1])
+		}
+		cents := uint64(0)
+		if m[3] != "" {
+			cents, _ = strconv.ParseUint((m[3] + "0")[:2], 10, 64)
+		}
+		result := englishCardinal(value) + " " + englishPlural(value == 1, names[0], names[1])
+		switch {
+		case cents == 0:
+		case value == 0:
+			result = englishCardinal(cents) + " " + englishPlural(cents == 1, names[2], names[3])
+		default:
+			result += " and " + englishCardinal(cents) + " " + englishPlural(cents == 1, names[2], names[3])
+		}
+		return result
+	})
+	text = replaceEnglishNumber(reEnglishPercent, text, isEnglishContext, func(m []string) string {
+		result := englishDecimal(strings.ReplaceAll(m[2], ",", ""), m[3]) + " percent"
+		if m[1] != "" {
+			result = "minus " + result
+		}
+		return result
+	})
+	text = replaceEnglishNumber(reEnglishOrdinal, text, isEnglishContext, func(m []string) string {
+		value, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		return englishOrdinal(value)
+	})
+	text = replaceEnglishNumber(reEnglishMonthDay, text, isEnglishWordContext, func(m []string) string {
+		day, _ := strconv.Atoi(m[2])
+		if day < 1 || day > 31 {
+			return m[0]
+		}
+		result := englishMonthName(m[1]) + " " + englishOrdinal(uint64(day))
+		if m[3] != "" {
+			result += " " + englishYear(m[3])
+		}
+		return result
+	})
+	text = replaceEnglishNumber(reEnglishRange, text, isEnglishContext, func(m []string) string {
+		result := englishNumberWords(m[1]) + " to " + englishNumberWords(m[2])
+		switch {
+		case m[3] == "%":
+			result += " percent"
+		case m[3] != "":
+			result += " " + englishUnitNames[m[3]][1]
+		}
+		return result
+	})
+	text = replaceEnglishNumber(reEnglishNumber, text, isEnglishNumberContext, func(m []string) string {
+		integer := strings.ReplaceAll(m[2], ",", "")
+		var result string
+		switch {
+		case m[3] != "":
+			result = englishDecimal(integer, m[3])
+		case len(m[2]) == 4 && m[4] == "" && m[1] == "" && integer >= "1100" && integer < "2100":
+			result = englishYear(integer)
+		default:
+			result = englishInteger(integer)
+		}
+		if m[4] != "" {
+			names := englishUnitNames[m[4]]
+			result += " " + englishPlural(integer == "1" && m[3] == "", names[0], names[1])
+		}
+		if m[1] != "" {
+			result = "minus " + result
+		}
+		return result
+	})
+	return text
+}
+
+// 只替换满足 context、且不与字母数字直接相连的匹配
+func replaceEnglishNumber(re *regexp.Regexp, text string, context func(string, int, int) bool, fn func([]string) string) string {
+	var builder strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if !isEnglishNumberBoundary(text, start, end) || !context(text, start, end) {
+			continue
+		}
+		m := make([]string, len(loc)/2)
+		for i := range m {
+			if loc[2*i] >= 0 {
+				m[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		builder.WriteString(text[last:start])
+		builder.WriteString(fn(m))
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// 匹配前后不能紧接字母或数字，前面也不能是跟在单词后的连字符、跟在字母数字后的小数点（如 GPT-4、V2.0 中的 4、0）
+func isEnglishNumberBoundary(text string, start int, end int) bool {
+	if start > 0 {
+		prev := text[start-1]
+		if isASCIIAlnum(prev) {
+			return false
+		}
+		if prev == '.' && start > 1 && isASCIIAlnum(text[start-2]) {
+			return false
+		}
+		if prev == '-' && precededByLetterWord(text, start-1) {
+			return false
+		}
+	}
+	if end < len(text) && isASCIIAlnum(text[end]) && isASCIIAlnum(text[end-1]) {
+		return false
+	}
+	return true
+}
+
+// text[:end] 末尾的字母数字串（可含时间中的冒号）是否含字母：GPT-4 的 4 跟在单词后，10:30-11:30 的 11 不是
+func precededByLetterWord(text string, end int) bool {
+	for i := end - 1; i >= 0 && (isASCIIAlnum(text[i]) || text[i] == ':'); i-- {
+		if isASCIILetter(text[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// 向两侧跳过空白、标点与数字，找到最近的文字：至少一侧为英文字母且两侧都不是汉字时为英文语境
+func isEnglishContext(text string, start int, end int) bool {
+	left, right := englishContextSides(text, start, end)
+	return (left == "en" || right == "en") && left != "zh" && right != "zh"
+}
+
+// 匹配中含英文单词（月份名、am/pm、million 等）时单词本身就是英文语境，两侧不是汉字即可，如整段输入为 "May 2, 2024"、"5pm"
+func isEnglishWordContext(text string, start int, end int) bool {
+	if !strings.ContainsFunc(text[start:end], func(r rune) bool { return r < 0x80 && isASCIILetter(byte(r)) }) {
+		return isEnglishContext(text, start, end)
+	}
+	left, right := englishContextSides(text, start, end)
+	return left != "zh" && right != "zh"
+}
+
+// 年代：四位数字，或两位数字前有撇号或 the，如 1990s、'90s、the 80s；其余的 80s 按数字加秒读
+func isEnglishDecadeContext(text string, start int, end int) bool {
+	if !isEnglishContext(text, start, end) {
+		return false
+	}
+	if end-start >= 5 || text[start] == '\'' {
+		return true
+	}
+	before := strings.TrimRight(text[:start], " ")
+	if len(before) == start || len(before) < 3 || !strings.EqualFold(before[len(before)-3:], "the") {
+		return false
+	}
+	return len(before) == 3 || !isASCIILetter(before[len(before)-4])
+}
+
+// 范围的一侧不单独改写：范围规则没有处理的 a-b、a~b（如后接未知单位）两侧都保留，交给中文正则化
+func isEnglishNumberContext(text string, start int, end int) bool {
+	return !isEnglishRangeSide(text, start, end) && isEnglishContext(text, start, end)
+}
+
+// 匹配之前或之后隔着 -、~、– 紧接数字
+func isEnglishRangeSide(text string, start int, end int) bool {
+	after := strings.TrimLeft(text[end:], " ")
+	if r, size := utf8.DecodeRuneInString(after); size > 0 && strings.ContainsRune("-~–", r) {
+		if rest := strings.TrimLeft(after[size:], " "); rest != "" && isASCIIDigit(rest[0]) {
+			return true
+		}
+	}
+	before := strings.TrimRight(text[:start], " ")
+	if r, size := utf8.DecodeLastRuneInString(before); size > 0 && strings.ContainsRune("-~–", r) {
+		if rest := strings.TrimRight(before[:len(before)-size], " "); rest != "" && isASCIIDigit(rest[len(rest)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// 两侧最近文字的类别："en" 英文字母、"zh" 汉字、"" 没有
+func englishContextSides(text string, start int, end int) (string, string) {
+	classify := func(r rune) string {
+		switch {
+		case r < 0x80 && isASCIILetter(byte(r)):
+			return "en"
+		case unicode.Is(unicode.Han, r):
+			return "zh"
+		}
+		return ""
+	}
+	left := ""
+	for i := start; i > 0 && left == ""; {
+		r, size := utf8.DecodeLastRuneInString(text[:i])
+		left = classify(r)
+		i -= size
+	}
+	right := ""
+	for _, r := range text[end:] {
+		if right = classify(r); right != "" {
+			break
+		}
+	}
+	return left, right
+}
+
+// 月份全称，缩写按前三个字母查找
+func englishMonthName(month string) string {
+	prefix := strings.ToLower(month)[:3]
+	for _, name := range englishMonthNames {
+		if strings.HasPrefix(name, prefix) {
+			return name
+		}
+	}
+	return month
+}
+
+// 基数词，如 123 → one hundred twenty three
+func englishCardinal(value uint64) string {
+	if value < 20 {
+		return englishOnesWords[value]
+	}
+	var groups []string
+	for scale := 0; value > 0; scale++ {
+		if group := value % 1000; group > 0 {
+			words := englishBelowThousand(group)
+			if englishScaleWords[scale] != "" {
+				words += " " + englishScaleWords[scale]
+			}
+			groups = append([]string{words}, groups...)
+		}
+		value /= 1000
+	}
+	return strings.Join(groups, " ")
+}
+
+func englishBelowThousand(value uint64) string {
+	var words []string
+	if value >= 100 {
+		words = append(words, englishOnesWords[value/100], "hundred")
+		value %= 100
+	}
+	switch {
+	case value == 0:
+	case value < 20:
+		words = append(words, englishOnesWords[value])
+	default:
+		words = append(words, englishTensWords[value/10])
+		if value%10 != 0 {
+			words = append(words, englishOnesWords[value%10])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// 序数词，如 21 → twenty first
+func englishOrdinal(value uint64) string {
+	words := strings.Fields(englishCardinal(value))
+	last := words[len(words)-1]
+	switch {
+	case englishIrregularOrdinals[last] != "":
+		last = englishIrregularOrdinals[last]
+	case strings.HasSuffix(last, "y"):
+		last = strings.TrimSuffix(last, "y") + "ieth"
+	default:
+		last += "th"
+	}
+	words[len(words)-1] = last
+	return strings.Join(words, " ")
+}
+
+// 年份按两位一组读：1999 → nineteen ninety nine，1905 → nineteen oh five，2000 → two thousand，2024 → twenty twenty four
+func englishYear(year string) string {
+	value, err := strconv.ParseUint(year, 10, 64)
+	if err != nil || len(year) != 4 {
+		return englishInteger(year)
+	}
+	high, low := value/100, value%100
+	switch {
+	case value%1000 < 10 && value >= 2000:
+		return englishCardinal(value)
+	case low == 0:
+		return englishCardinal(high) + " hundred"
+	case low < 10:
+		return englishCardinal(high) + " oh " + englishCardinal(low)
+	default:
+		return englishCardinal(high) + " " + englishCardinal(low)
+	}
+}
+
+// 年代读作复数：1990s → nineteen nineties，1900s → nineteen hundreds，2000s → two thousands，80s → eighties
+func englishDecade(century string, decade string) string {
+	tens := "tens"
+	if decade[0] >= '2' {
+		tens = strings.TrimSuffix(englishTensWords[decade[0]-'0'], "y") + "ies"
+	}
+	if century == "" {
+		return tens
+	}
+	value, _ := strconv.ParseUint(century+decade+"0", 10, 64)
+	switch {
+	case decade == "0" && value%1000 == 0:
+		return englishCardinal(value) + "s"
+	case decade == "0":
+		return englishCardinal(value/100) + " hundreds"
+	}
+	return englishCardinal(value/100) + " " + tens
+}
+
+// 整数：0 开头或超出范围的数字串逐位读
+func englishInteger(digits string) string {
+	value, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || (len(digits) > 1 && digits[0] == '0') {
+		return englishDigits(digits)
+	}
+	return englishCardinal(value)
+}
+
+// 整数或小数
+func englishNumberWords(number string) string {
+	integer, fraction, _ := strings.Cut(number, ".")
+	return englishDecimal(integer, fraction)
+}
+
+// 小数：整数部分按基数读，小数部分逐位读，如 3.14 → three point one four
+func englishDecimal(integer string, fraction string) string {
+	result := englishInteger(integer)
+	if fraction != "" {
+		result += " point " + englishDigits(fraction)
+	}
+	return result
+}
+
+func englishDigits(digits string) string {
+	words := make([]string, 0, len(digits))
+	for i := 0; i < len(digits); i++ {
+		words = append(words, englishOnesWords[digits[i]-'0'])
+	}
+	return strings.Join(words, " ")
+}
+
+func englishPlural(singular bool, one string, many string) string {
+	if singular {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestNormalizeEnglishNumbers(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		// 时间
+		{"meet at 5pm sharp", "meet at five PM sharp"},
+		{"at 11 a.m. today", "at eleven AM today"},
+		{"at 10:30 pm", "at ten thirty PM"},
+		{"wake at 7:05", "wake at seven oh five"},
+		{"at 9:00 today", "at nine o'clock today"},
+		{"10:30-11:30 today", "ten thirty-eleven thirty today"},
+
+		// 日期
+		{"May 2, 2024", "may second twenty twenty four"},
+		{"Oct. 18, 2024", "october eighteenth twenty twenty four"},
+		{"released 2024-10-18 here", "released october eighteenth twenty twenty four here"},
+		{"the mayor is 2nd", "the mayor is second"},
+
+		// 年代
+		{"the 1990s were great", "the nineteen nineties were great"},
+		{"the 80s were fun", "the eighties were fun"},
+		{"back in the '90s", "back in the nineties"},
+		{"the 1900s", "the nineteen hundreds"},
+		{"the 2000s", "the two thousands"},
+
+		// 电话号码逐位读
+		{"Call 555-1234 now", "Call five five five, one two three four now"},
+		{"call 212-555-1234 today", "call two one two, five five five, one two three four today"},
+
+		// 范围与单位
+		{"pages 10-20 today", "pages ten to twenty today"},
+		{"Run 10-20km today", "Run ten to twenty kilometers today"},
+		{"up 10-20% today", "up ten to twenty percent today"},
+		{"It took 5s to load", "It took five seconds to load"},
+		{"took 80s", "took eighty seconds"},
+		{"run 1 km", "run one kilometer"},
+
+		// 金额、百分比、序数、普通数字
+		{"$5.99 each", "five dollars and ninety nine cents each"},
+		{"$1 only", "one dollar only"},
+		{"$1.5 million", "one point five million dollars"},
+		{"up 50% today", "up fifty percent today"},
+		{"the 21st time", "the twenty first time"},
+		{"I have 3 apples", "I have three apples"},
+		{"about 1,000 people", "about one thousand people"},
+		{"pi is 3.14", "pi is three point one four"},
+		{"born in 1999", "born in nineteen ninety nine"},
+
+		// 与字母相连、跨字母数字串、汉字语境的数字不改写
+		{"GPT-4 is here", "GPT-4 is here"},
+		{"use x86-64 now", "use x86-64 now"},
+		{"try 10-20xyz", "try 10-20xyz"},
+		{"我有3个apple", "我有3个apple"},
+		{"跑了10-20km", "跑了10-20km"},
+	}
+	for _, c := range cases {
+		if got := normalizeEnglishNumbers(c.input); got != c.want {
+			t.Errorf("normalizeEnglishNumbers(%q) = %q, want %q", c.input, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,626 @@
+package main
+
+// 英文词性标注（Penn Treebank 标记），用于多音词选择读音，见 english_heteronym.go
+// 平均感知机，特征与 NLTK 的 averaged_perceptron_tagger 一致，模型来源依次为：
+//   TTS_POS_MODEL：本程序保存的模型文件，或 NLTK 的 averaged_perceptron_tagger_eng 目录
+//                  （含 *.weights.json、*.tagdict.json、*.classes.json）
+//   ./english_pos.model
+//   TTS_POS_CORPUS：标注语料，加载 cmudict 后在后台训练并保存为 ./english_pos.model，下次启动直接加载；
+//                   格式为每行一句 "word/TAG word/TAG ..."，或 CoNLL-U（取第 2 列词形、第 5 列 XPOS）
+// 都没有时使用内置的规则标注：功能词查表，实词按前后词判断名词、动词
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	englishPOSModelPath       = "./english_pos.model"
+	englishPOSTrainIterations = 5
+	englishPOSTagDictMinFreq  = 20
+	englishPOSTagDictMinRatio = 0.97
+)
+
+var (
+	englishPOSOnce   sync.Once
+	englishPOSTagger *EnglishPOSTagger
+	englishPOSCorpus string
+)
+
+// 平均感知机词性标注器，加载或训练完成后只读，并发安全
+type EnglishPOSTagger struct {
+	weights map[string]map[string]float64 // 特征 → 标记 → 权重
+	tagdict map[string]string             // 高频且无歧义的词直接给出标记
+	classes []string
+}
+
+// 加载词性标注模型；只配置了语料时在后台训练
+func loadEnglishPOSTagger() error {
+	path := os.Getenv("TTS_POS_MODEL")
+	if path == "" {
+		if _, err := os.Stat(englishPOSModelPath); err == nil {
+			path = englishPOSModelPath
+		}
+	}
+	if path != "" {
+		start := time.Now()
+		tagger, err := loadEnglishPOSModel(path)
+		if err != nil {
+			return newTTSError(ErrCodeModelLoad, "加载英文词性标注模型失败: "+path, err)
+		}
+		englishPOSTagger = tagger
+		fmt.Printf("加载英文词性标注模型 %s 特征数: %d (耗时: %v)\n", path, len(tagger.weights), time.Since(start))
+		return nil
+	}
+
+	if englishPOSCorpus = os.Getenv("TTS_POS_CORPUS"); englishPOSCorpus != "" {
+		go getEnglishPOSTagger()
+		return nil
+	}
+	fmt.Println("未配置英文词性标注模型，使用内置规则标注")
+	return nil
+}
+
+// 返回词性标注器，配置了语料且尚未训练时先训练；没有模型时返回 nil
+func getEnglishPOSTagger() *EnglishPOSTagger {
+	englishPOSOnce.Do(func() {
+		if englishPOSTagger != nil || englishPOSCorpus == "" {
+			return
+		}
+		start := time.Now()
+		sentences, err := readEnglishPOSCorpus(englishPOSCorpus)
+		if err != nil {
+			fmt.Printf("读取英文词性标注语料失败, 使用内置规则标注: %v\n", err)
+			return
+		}
+		englishPOSTagger = trainEnglishPOSTagger(sentences, englishPOSTrainIterations)
+		fmt.Printf("英文词性标注模型训练完成, 句数: %d, 特征数: %d (耗时: %v)\n", len(sentences), len(englishPOSTagger.weights), time.Since(start))
+		if err := englishPOSTagger.Save(englishPOSModelPath); err != nil {
+			fmt.Printf("保存英文词性标注模型失败: %v\n", err)
+		}
+	})
+	return englishPOSTagger
+}
+
+// 为单词序列标注词性
+func tagEnglishWords(words []string) []string {
+	if tagger := getEnglishPOSTagger(); tagger != nil {
+		return tagger.Tag(words)
+	}
+	return ruleTagEnglishWords(words)
+}
+
+func (t *EnglishPOSTagger) Tag(words []string) []string {
+	context := englishPOSContext(words)
+	tags := make([]string, len(words))
+	prev, prev2 := "-START-", "-START2-"
+	for i, word := range words {
+		tag, ok := t.tagdict[word]
+		if !ok {
+			tag = t.predict(englishPOSFeatures(i, word, context, prev, prev2))
+		}
+		tags[i] = tag
+		prev2, prev = prev, tag
+	}
+	return tags
+}
+
+// 得分最高的标记，得分相同时取字典序较大的，与 NLTK 一致
+func (t *EnglishPOSTagger) predict(features []string) string {
+	scores := make(map[string]float64, len(t.classes))
+	for _, feature := range features {
+		for class, weight := range t.weights[feature] {
+			scores[class] += weight
+		}
+	}
+	best := ""
+	bestScore := math.Inf(-1)
+	for _, class := range t.classes {
+		if score := scores[class]; score > bestScore || (score == bestScore && class > best) {
+			best, bestScore = class, score
+		}
+	}
+	return best
+}
+
+// 前后各补两个占位符的归一化词序列
+func englishPOSContext(words []string) []string {
+	context := make([]string, 0, len(words)+4)
+	context = append(context, "-START-", "-START2-")
+	for _, word := range words {
+		context = append(context, normalizeEnglishPOSWord(word))
+	}
+	return append(context, "-END-", "-END2-")
+}
+
+func normalizeEnglishPOSWord(word string) string {
+	switch {
+	case strings.Contains(word, "-") && !strings.HasPrefix(word, "-"):
+		return "!HYPHEN"
+	case len(word) == 4 && isASCIIDigits(word):
+		return "!YEAR"
+	case word != "" && isASCIIDigit(word[0]):
+		return "!DIGITS"
+	}
+	return strings.ToLower(word)
+}
+
+func englishPOSFeatures(i int, word string, context []string, prev string, prev2 string) []string {
+	i += 2
+	prefix := ""
+	if word != "" {
+		prefix = word[:1]
+	}
+	return []string{
+		"bias",
+		"i suffix " + englishPOSSuffix(word),
+		"i pref1 " + prefix,
+		"i-1 tag " + prev,
+		"i-2 tag " + prev2,
+		"i tag+i-2 tag " + prev + " " + prev2,
+		"i word " + context[i],
+		"i-1 tag+i word " + prev + " " + context[i],
+		"i-1 word " + context[i-1],
+		"i-1 suffix " + englishPOSSuffix(context[i-1]),
+		"i-2 word " + context[i-2],
+		"i+1 word " + context[i+1],
+		"i+1 suffix " + englishPOSSuffix(context[i+1]),
+		"i+2 word " + context[i+2],
+	}
+}
+
+func englishPOSSuffix(word string) string {
+	if len(word) > 3 {
+		return word[len(word)-3:]
+	}
+	return word
+}
+
+// 标注语料中的一个词
+type englishPOSToken struct {
+	word string
+	tag  string
+}
+
+// 训练：每轮打乱句子顺序，预测错误时更新权重，最后取各权重在全部更新步上的平均值
+func trainEnglishPOSTagger(sentences [][]englishPOSToken, iterations int) *EnglishPOSTagger {
+	tagger := &EnglishPOSTagger{weights: map[string]map[string]float64{}}
+	tagger.tagdict, tagger.classes = buildEnglishPOSTagDict(sentences)
+
+	type param struct{ feature, class string }
+	totals := map[param]float64{}
+	stamps := map[param]int{}
+	instances := 0
+	update := func(feature string, class string, delta float64) {
+		weights := tagger.weights[feature]
+		if weights == nil {
+			weights = map[string]float64{}
+			tagger.weights[feature] = weights
+		}
+		p := param{feature, class}
+		totals[p] += float64(instances-stamps[p]) * weights[class]
+		stamps[p] = instances
+		weights[class] += delta
+	}
+
+	random := rand.New(rand.NewSource(0))
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+	for iteration := 0; iteration < iterations; iteration++ {
+		random.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		for _, index := range order {
+			sentence := sentences[index]
+			words := make([]string, len(sentence))
+			for i, token := range sentence {
+				words[i] = token.word
+			}
+			context := englishPOSContext(words)
+			prev, prev2 := "-START-", "-START2-"
+			for i, token := range sentence {
+				guess, ok := tagger.tagdict[token.word]
+				if !ok {
+					features := englishPOSFeatures(i, token.word, context, prev, prev2)
+					guess = tagger.predict(features)
+					instances++
+					if guess != token.tag {
+						for _, feature := range features {
+							update(feature, token.tag, 1)
+							update(feature, guess, -1)
+						}
+					}
+				}
+				prev2, prev = prev, guess
+			}
+		}
+	}
+
+	for feature, weights := range tagger.weights {
+		for class, weight := range weights {
+			p := param{feature, class}
+			total := totals[p] + float64(instances-stamps[p])*weight
+			if average := math.Round(total/float64(instances)*1000) / 1000; average != 0 {
+				weights[class] = average
+			} else {
+				delete(weights, class)
+			}
+		}
+		if len(weights) == 0 {
+			delete(tagger.weights, feature)
+		}
+	}
+	return tagger
+}
+
+// 出现足够多次且几乎只有一种标记的词加入 tagdict，同时收集全部标记
+func buildEnglishPOSTagDict(sentences [][]englishPOSToken) (map[string]string, []string) {
+	counts := map[string]map[string]int{}
+	classSet := map[string]bool{}
+	for _, sentence := range sentences {
+		for _, token := range sentence {
+			if counts[token.word] == nil {
+				counts[token.word] = map[string]int{}
+			}
+			counts[token.word][token.tag]++
+			classSet[token.tag] = true
+		}
+	}
+
+	tagdict := map[string]string{}
+	for word, tagCounts := range counts {
+		total, best, bestCount := 0, "", 0
+		for tag, count := range tagCounts {
+			total += count
+			if count > bestCount || (count == bestCount && tag < best) {
+				best, bestCount = tag, count
+			}
+		}
+		if total >= englishPOSTagDictMinFreq && float64(bestCount)/float64(total) >= englishPOSTagDictMinRatio {
+			tagdict[word] = best
+		}
+	}
+
+	classes := make([]string, 0, len(classSet))
+	for class := range classSet {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+	return tagdict, classes
+}
+
+func readEnglishPOSCorpus(path string) ([][]englishPOSToken, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sentences [][]englishPOSToken
+	var conll []englishPOSToken
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			if len(conll) > 0 {
+				sentences = append(sentences, conll)
+				conll = nil
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.Contains(line, "\t"):
+			// CoNLL-U：ID FORM LEMMA UPOS XPOS ...，跳过多词 token 与空节点
+			columns := strings.Split(line, "\t")
+			if len(columns) < 5 {
+				return nil, fmt.Errorf("第 %d 行列数不足: %q", lineNo, line)
+			}
+			if strings.ContainsAny(columns[0], "-.") {
+				continue
+			}
+			tag := columns[4]
+			if tag == "_" {
+				tag = columns[3]
+			}
+			conll = append(conll, englishPOSToken{word: columns[1], tag: tag})
+		default:
+			var sentence []englishPOSToken
+			for _, field := range strings.Fields(line) {
+				slash := strings.LastIndex(field, "/")
+				if slash <= 0 || slash == len(field)-1 {
+					return nil, fmt.Errorf("第 %d 行格式错误: %q", lineNo, field)
+				}
+				sentence = append(sentence, englishPOSToken{word: field[:slash], tag: field[slash+1:]})
+			}
+			sentences = append(sentences, sentence)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(conll) > 0 {
+		sentences = append(sentences, conll)
+	}
+	if len(sentences) == 0 {
+		return nil, fmt.Errorf("语料为空: %s", path)
+	}
+	return sentences, nil
+}
+
+// 保存为文本格式，每行以制表符分隔："classes 标记..."、"tag 词 标记"、"weight 特征 标记 权重"
+func (t *EnglishPOSTagger) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "classes\t%s\n", strings.Join(t.classes, "\t"))
+
+	words := make([]string, 0, len(t.tagdict))
+	for word := range t.tagdict {
+		words = append(words, word)
+	}
+	slices.Sort(words)
+	for _, word := range words {
+		fmt.Fprintf(writer, "tag\t%s\t%s\n", word, t.tagdict[word])
+	}
+
+	features := make([]string, 0, len(t.weights))
+	for feature := range t.weights {
+		features = append(features, feature)
+	}
+	slices.Sort(features)
+	for _, feature := range features {
+		for _, class := range t.classes {
+			if weight, ok := t.weights[feature][class]; ok {
+				fmt.Fprintf(writer, "weight\t%s\t%s\t%s\n", feature, class, strconv.FormatFloat(weight, 'g', -1, 64))
+			}
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func loadEnglishPOSModel(path string) (*EnglishPOSTagger, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadNLTKPOSModel(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tagger := &EnglishPOSTagger{weights: map[string]map[string]float64{}, tagdict: map[string]string{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		fields := strings.Split(scanner.Text(), "\t")
+		switch {
+		case fields[0] == "classes":
+			tagger.classes = fields[1:]
+		case fields[0] == "tag" && len(fields) == 3:
+			tagger.tagdict[fields[1]] = fields[2]
+		case fields[0] == "weight" && len(fields) == 4:
+			weight, err := strconv.ParseFloat(fields[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行权重格式错误: %q", lineNo, fields[3])
+			}
+			if tagger.weights[fields[1]] == nil {
+				tagger.weights[fields[1]] = map[string]float64{}
+			}
+			tagger.weights[fields[1]][fields[2]] = weight
+		case fields[0] == "":
+		default:
+			return nil, fmt.Errorf("第 %d 行格式错误", lineNo)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tagger.classes) == 0 {
+		return nil, fmt.Errorf("模型缺少 classes")
+	}
+	return tagger, nil
+}
+
+// NLTK 3.9 起 averaged_perceptron_tagger_eng 以三个 JSON 文件发布
+func loadNLTKPOSModel(dir string) (*EnglishPOSTagger, error) {
+	find := func(suffix string) (string, error) {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+suffix))
+		if err != nil || len(matches) == 0 {
+			return "", fmt.Errorf("目录中找不到 *%s", suffix)
+		}
+		return matches[0], nil
+	}
+	readJSON := func(suffix string, value any) error {
+		path, err := find(suffix)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+
+	tagger := &EnglishPOSTagger{}
+	if err := readJSON(".weights.json", &tagger.weights); err != nil {
+		return nil, err
+	}
+	if err := readJSON(".tagdict.json", &tagger.tagdict); err != nil {
+		return nil, err
+	}
+	if err := readJSON(".classes.json", &tagger.classes); err != nil {
+		return nil, err
+	}
+	slices.Sort(tagger.classes)
+	return tagger, nil
+}
+
+// 规则标注用的功能词
+var englishClosedClassTags = map[string]string{
+	"the": "DT", "a": "DT", "an": "DT", "this": "DT", "that": "DT", "these": "DT", "those": "DT",
+	"every": "DT", "each": "DT", "some": "DT", "any": "DT", "no": "DT", "all": "DT", "another": "DT",
+	"my": "PRP$", "your": "PRP$", "his": "PRP$", "her": "PRP$", "its": "PRP$", "our": "PRP$", "their": "PRP$",
+	"i": "PRP", "you": "PRP", "he": "PRP", "she": "PRP", "it": "PRP", "we": "PRP", "they": "PRP",
+	"me": "PRP", "him": "PRP", "us": "PRP", "them": "PRP",
+	"who": "WP", "what": "WP", "which": "WDT", "to": "TO",
+	"will": "MD", "would": "MD", "can": "MD", "could": "MD", "shall": "MD", "should": "MD",
+	"may": "MD", "might": "MD", "must": "MD", "won't": "MD", "can't": "MD", "wouldn't": "MD",
+	"couldn't": "MD", "shouldn't": "MD", "i'll": "MD", "you'll": "MD", "we'll": "MD", "they'll": "MD",
+	"do": "VBP", "does": "VBZ", "did": "VBD", "don't": "VBP", "doesn't": "VBZ", "didn't": "VBD",
+	"have": "VBP", "has": "VBZ", "had": "VBD", "haven't": "VBP", "hasn't": "VBZ", "hadn't": "VBD",
+	"i've": "VBP", "you've": "VBP", "we've": "VBP", "they've": "VBP", "having": "VBG",
+	"am": "VBP", "is": "VBZ", "are": "VBP", "was": "VBD", "were": "VBD", "be": "VB", "been": "VBN", "being": "VBG",
+	"isn't": "VBZ", "aren't": "VBP", "wasn't": "VBD", "weren't": "VBD",
+	"i'm": "VBP", "you're": "VBP", "he's": "VBZ", "she's": "VBZ", "it's": "VBZ", "we're": "VBP", "they're": "VBP",
+	"in": "IN", "on": "IN", "at": "IN", "of": "IN", "for": "IN", "with": "IN", "by": "IN", "from": "IN",
+	"about": "IN", "into": "IN", "over": "IN", "under": "IN", "after": "IN", "before": "IN", "during": "IN",
+	"and": "CC", "or": "CC", "but": "CC",
+	"not": "RB", "never": "RB", "just": "RB", "already": "RB", "also": "RB", "ever": "RB",
+	"often": "RB", "always": "RB", "usually": "RB", "still": "RB", "very": "RB",
+	"yesterday": "RB", "ago": "RB", "last": "JJ",
+}
+
+// 句中出现这些词时，主语后的动词按过去式
+var englishPastMarkers = map[string]bool{
+	"yesterday": true, "ago": true, "last": true, "was": true, "were": true, "did": true, "had": true,
+}
+
+// 常见不规则动词的过去式，主语后出现时为 VBD，如 "She wound the clock"
+// 值为 true 的同时也是动词原形（read、put），只有第三人称单数主语后（原形应带 -s）才能确定为过去式
+var englishIrregularPast = map[string]bool{
+	"read": true, "put": true, "cut": true, "set": true, "hit": true, "let": true, "shut": true,
+	"cost": true, "hurt": true, "quit": true, "spread": true, "beat": true,
+	"wound": false, "dove": false, "led": false, "wrote": false, "went": false, "saw": false, "took": false,
+	"came": false, "made": false, "gave": false, "found": false, "told": false, "said": false, "got": false,
+	"knew": false, "thought": false, "left": false, "felt": false, "kept": false, "brought": false,
+	"began": false, "ran": false, "sat": false, "stood": false, "heard": false, "met": false, "paid": false,
+	"sent": false, "built": false, "lost": false, "spent": false, "won": false, "wore": false, "tore": false,
+	"drove": false, "rode": false, "rose": false, "ate": false, "drank": false, "sang": false, "swam": false,
+	"flew": false, "grew": false, "threw": false, "drew": false, "broke": false, "spoke": false, "chose": false,
+	"froze": false, "woke": false, "fell": false, "held": false, "sold": false, "taught": false,
+	"caught": false, "bought": false, "fought": false, "slept": false, "bound": false, "ground": false,
+}
+
+var englishDoAuxiliaries = map[string]bool{
+	"do": true, "does": true, "did": true, "don't": true, "doesn't": true, "didn't": true,
+}
+
+var englishHaveAuxiliaries = map[string]bool{
+	"have": true, "has": true, "had": true, "haven't": true, "hasn't": true, "hadn't": true, "having": true,
+	"i've": true, "you've": true, "we've": true, "they've": true,
+}
+
+// 内置规则标注：只区分多音词需要的名词、形容词、动词原形、过去式、过去分词等
+func ruleTagEnglishWords(words []string) []string {
+	lowers := make([]string, len(words))
+	past := false
+	for i, word := range words {
+		lowers[i] = strings.ToLower(word)
+		past = past || englishPastMarkers[lowers[i]]
+	}
+
+	tags := make([]string, len(words))
+	for i, lower := range lowers {
+		if tag, ok := englishClosedClassTags[lower]; ok {
+			tags[i] = tag
+			continue
+		}
+
+		// 跳过副词，找前一个有效词
+		prevWord, prevTag := "", ""
+		prevIndex := -1
+		for j := i - 1; j >= 0; j-- {
+			if tags[j] != "RB" {
+				prevWord, prevTag, prevIndex = lowers[j], tags[j], j
+				break
+			}
+		}
+		// 疑问句中助动词在主语前，如 "have you read"、"will they live"
+		if prevTag == "PRP" && prevIndex > 0 {
+			if auxiliary := lowers[prevIndex-1]; tags[prevIndex-1] == "MD" || englishDoAuxiliaries[auxiliary] || englishHaveAuxiliaries[auxiliary] {
+				prevWord, prevTag = auxiliary, tags[prevIndex-1]
+			}
+		}
+		nextTag := ""
+		if i+1 < len(lowers) {
+			nextTag = englishClosedClassTags[lowers[i+1]]
+		}
+		objectFollows := nextTag == "DT" || nextTag == "PRP$" || nextTag == "PRP"
+
+		switch {
+		case isASCIIDigit(lower[0]):
+			tags[i] = "CD"
+		case strings.HasSuffix(lower, "ly") && len(lower) > 4:
+			tags[i] = "RB"
+		case prevTag == "MD" || prevTag == "TO" || englishDoAuxiliaries[prevWord]:
+			tags[i] = "VB"
+		case englishHaveAuxiliaries[prevWord]:
+			tags[i] = "VBN"
+		case strings.HasPrefix(prevTag, "VB"):
+			// be 动词之后：进行时、被动，其余为表语形容词
+			switch {
+			case strings.HasSuffix(lower, "ing"):
+				tags[i] = "VBG"
+			case strings.HasSuffix(lower, "ed") || lower == "read":
+				tags[i] = "VBN"
+			default:
+				tags[i] = "JJ"
+			}
+		case prevTag == "DT" || prevTag == "PRP$" || prevTag == "JJ" || prevTag == "IN" || prevTag == "CD":
+			tags[i] = "NN"
+		case prevTag == "PRP" || prevTag == "WP" || prevTag == "WDT" || (prevTag == "NN" && objectFollows):
+			// 第三人称单数主语（he/she/it、单数名词）后 -s 结尾的为 VBZ，如 "He lives in Paris"
+			thirdPerson := prevWord == "he" || prevWord == "she" || prevWord == "it" || prevTag == "NN" || prevTag == "WP" || prevTag == "WDT"
+			alsoBase, irregularPast := englishIrregularPast[lower]
+			switch {
+			case past || strings.HasSuffix(lower, "ed"):
+				tags[i] = "VBD"
+			case irregularPast && (!alsoBase || thirdPerson):
+				tags[i] = "VBD"
+			case thirdPerson && strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss"):
+				tags[i] = "VBZ"
+			default:
+				tags[i] = "VBP"
+			}
+		case prevTag == "" && objectFollows:
+			// 句首祈使句，如 "Record the show"
+			tags[i] = "VB"
+		case strings.HasSuffix(lower, "ing"):
+			tags[i] = "VBG"
+		case strings.HasSuffix(lower, "ed"):
+			tags[i] = "VBD"
+		default:
+			tags[i] = "NN"
+		}
+	}
+	return tags
+}
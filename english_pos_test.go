@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleTagEnglishVerbs(t *testing.T) {
+	cases := []struct {
+		sentence string
+		word     string
+		want     string
+	}{
+		// 主语后的不规则过去式
+		{"She wound the clock", "wound", "VBD"},
+		{"I wound the clock", "wound", "VBD"},
+		{"He read the book", "read", "VBD"},
+		{"It dove into the lake", "dove", "VBD"},
+
+		// 原形与过去式同形时，非第三人称单数主语后按现在时
+		{"I read books", "read", "VBP"},
+		{"They read the news", "read", "VBP"},
+		{"They read the news yesterday", "read", "VBD"},
+
+		// 第三人称单数现在时与名词
+		{"He lives in Paris", "lives", "VBZ"},
+		{"The wound is deep", "wound", "NN"},
+		{"She has wound the clock", "wound", "VBN"},
+		{"Record the show", "Record", "VB"},
+	}
+	for _, c := range cases {
+		words := strings.Fields(c.sentence)
+		tags := ruleTagEnglishWords(words)
+		for i, word := range words {
+			if word == c.word && tags[i] != c.want {
+				t.Errorf("ruleTagEnglishWords(%q) 中 %q = %s, want %s", c.sentence, c.word, tags[i], c.want)
+			}
+		}
+	}
+}
@@ -12,7 +12,7 @@ import (
 	"strconv"
 	// "strings"
 	// "regexp"
-	"strings"
+	// "strings"
 	// "unicode"
 	//"github.com/mozillazg/go-pinyin"
 	//"github.com/go-ego/gpy"
@@ -23,13 +23,24 @@ import (
 
 )
 
-func MandarenResourcePreload() {
+func MandarenResourcePreload() error {
 	Mandaren_pinyinresourcePreload()
 
+	if mandarenSegmenter == nil {
+		segmenter, err := NewMandarenSegmenter(mandarenWordDictPath)
+		if err != nil {
+			return err
+		}
+		mandarenSegmenter = segmenter
+	}
+	return nil
 }
 
-func MandarenMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string, []int, []int, string) {
-	
+// lexicon 为用户发音词典，可为 nil
+func MandarenMix_g2p(text string, bertExtractor *BERTFeatureExtractor, lexicon *UserLexicon) ([]string, []int, []int, string, error) {
+	// 数字、日期、单位等先改写为汉字读法
+	text = NormalizeChineseText(text, ZH_X)
+
 	mix_phones := []string{"_"}
 	mix_tones := []int{0}
 	mix_word2ph := []int{1}
@@ -49,17 +60,20 @@ func MandarenMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string
 		if segment.Type == TypeChinese{
 			sentence := segment.Content
 			filteredText += sentence
-			phones, tones, word2ph := Mandaren_g2p(sentence)
+			phones, tones, word2ph := Mandaren_g2p(sentence, lexicon)
 			mix_phones = append(mix_phones, phones...)
 			mix_tones = append(mix_tones, tones...)
 			mix_word2ph = append(mix_word2ph, word2ph...)
 		}
 		if segment.Type == TypeNumber{
 			// 数字简单转换为中文模式，具体取决于业务模式，如钱币 日期 时间，可在前端进行处理
-			num, _ := strconv.ParseInt(segment.Content, 10, 64)
+			num, err := strconv.ParseInt(segment.Content, 10, 64)
+			if err != nil {
+				return nil, nil, nil, "", newTTSError(ErrCodeG2P, "无法解析数字: "+segment.Content, err)
+			}
 			zhstr := chinese_number.Number2Simplified(num)
 			filteredText += zhstr
-			phones, tones, word2ph := Mandaren_g2p(zhstr)
+			phones, tones, word2ph := Mandaren_g2p(zhstr, lexicon)
 			mix_phones = append(mix_phones, phones...)
 			mix_tones = append(mix_tones, tones...)
 			mix_word2ph = append(mix_word2ph, word2ph...)
@@ -67,7 +81,10 @@ func MandarenMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string
 		if segment.Type == TypeEnglish{
 			sentence := segment.Content
 			filteredText += sentence
-			en_phones ,en_tones, en_word2ph := English_g2p(sentence, bertExtractor)
+			en_phones ,en_tones, en_word2ph, err := English_g2p(sentence, bertExtractor, lexicon)
+			if err != nil {
+				return nil, nil, nil, "", err
+			}
 			mix_phones = append(mix_phones, en_phones...)
 			mix_tones = append(mix_tones, en_tones...)
 			mix_word2ph = append(mix_word2ph, en_word2ph...)
@@ -89,11 +106,11 @@ func MandarenMix_g2p(text string, bertExtractor *BERTFeatureExtractor) ([]string
 	mix_tones = append(mix_tones, 0)
 	mix_word2ph = append(mix_word2ph, 1)
 
-	return 	mix_phones ,mix_tones, mix_word2ph, filteredText
+	return 	mix_phones ,mix_tones, mix_word2ph, filteredText, nil
 }
 
 
-func Mandaren_g2p(zh_text string) ([]string, []int, []int) {
+func Mandaren_g2p(zh_text string, lexicon *UserLexicon) ([]string, []int, []int) {
 	//// 加载分词词典
 
 	// fmt.Println("测试完毕")
@@ -102,12 +119,9 @@ func Mandaren_g2p(zh_text string) ([]string, []int, []int) {
 	tones := []int{}
 	word2ph := []int{}
 
-	pys := pinyinSentenceDict.Convert(zh_text, " ").ASCII()
-	for _, py := range strings.Split(pys, " "){
-		initial := Get_initial(py)
-		final ,tone := Get_final_tone(py)
-		//fmt.Println("声母韵母提取：", initial, final, tone)
-		//fmt.Println("py声母韵母长度：", len(initial), len(final))
+	// 用户词典中的词覆盖默认拼音
+	for _, syllable := range lexicon.ApplyMandaren(zh_text, Mandaren_syllables(zh_text)) {
+		initial, final, tone := syllable.Initial, syllable.Final, syllable.Tone
 
 		phoneCountPerword := 0
 		if len(initial) > 0 {
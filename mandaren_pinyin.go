@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"strings"
 	"regexp"
 	"strconv"
@@ -100,19 +101,57 @@ func handleYW(p string) string {
 }
 
 
-func Mandaren_pinyin(zh_text string) []map[string]string {
-	retPinyins := []map[string]string{}	
+// 单个汉字的拼音拆分结果
+type MandarenSyllable struct {
+	Initial string
+	Final   string
+	Tone    int
+}
+
+// 中文转拼音并拆分声母韵母，声调经过变调处理
+// 推理端 Mandaren_g2p 与训练端 Mandaren_pinyin 共用，保证两端一致
+func Mandaren_syllables(zh_text string) []MandarenSyllable {
+	syllables := []MandarenSyllable{}
+	tones := []int{}
 
 	pys := pinyinSentenceDict.Convert(zh_text, " ").ASCII()
 	for _, py := range strings.Split(pys, " "){
 		initial := Get_initial(py)
 		final ,tone := Get_final_tone(py)
 		//fmt.Println("声母韵母提取：", initial, final, tone)
-		//fmt.Println("py声母韵母长度：", len(initial), len(final))
+		syllables = append(syllables, MandarenSyllable{Initial: initial, Final: final, Tone: tone})
+		tones = append(tones, tone)
+	}
+
+	tones = ApplyToneSandhi(zh_text, tones)
+	for i := range syllables {
+		syllables[i].Tone = tones[i]
+	}
+	return syllables
+}
+
+// 解析带数字声调的单个拼音，如 "hang2"、"lv4"、"de5"，5或不带数字为轻声
+func ParsePinyinSyllable(py string) (MandarenSyllable, error) {
+	py = strings.ReplaceAll(strings.ToLower(py), "ü", "v")
+	initial := Get_initial(py)
+	final, tone := Get_final_tone(py)
+	if final == "" || tone > 5 {
+		return MandarenSyllable{}, fmt.Errorf("无法解析拼音: %q", py)
+	}
+	if tone == 5 {
+		tone = mandarenNeutralTone
+	}
+	return MandarenSyllable{Initial: initial, Final: final, Tone: tone}, nil
+}
+
+func Mandaren_pinyin(zh_text string) []map[string]string {
+	retPinyins := []map[string]string{}	
+
+	for _, syllable := range Mandaren_syllables(zh_text) {
 		itemMap := map[string]string{
-			"initial": initial,
-			"final": final,
-			"tone": strconv.Itoa(tone),
+			"initial": syllable.Initial,
+			"final": syllable.Final,
+			"tone": strconv.Itoa(syllable.Tone),
 		}
 		retPinyins = append(retPinyins, itemMap)
 		
@@ -4,6 +4,7 @@ package main
 
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 	 
@@ -16,6 +17,12 @@ type UserRequest struct {
 }
 
 func main() {
+	// 0. 加载拼音与分词词典，变调依赖分词词典
+	if err := MandarenResourcePreload(); err != nil {
+		fmt.Printf("加载普通话资源失败: %v\n", err)
+		return
+	}
+
 	// 1. 设置为生产模式以提升性能（隐藏调试日志）
 	gin.SetMode(gin.ReleaseMode)
 
@@ -0,0 +1,166 @@
+package main
+
+// 普通话分词与词性标注，供变调使用
+// 词典格式与 jieba 的 dict.txt 相同：每行 "词 词频 词性"
+// 算法与 jieba 不带HMM的精确模式一致：构建DAG，按最大概率路径切分
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jieba 格式词典路径，随源码提供 jieba 的 dict.txt；变调规则依赖词与词性，缺失时加载失败
+var mandarenWordDictPath = "./jieba_dict.txt"
+
+// 分词结果
+type MandarenWord struct {
+	Word string
+	POS  string
+}
+
+// 普通话分词器
+type MandarenSegmenter struct {
+	freq       map[string]float64
+	pos        map[string]string
+	logTotal   float64
+	maxWordLen int
+}
+
+var mandarenSegmenter *MandarenSegmenter
+
+// 加载 jieba 格式词典
+func NewMandarenSegmenter(dictPath string) (*MandarenSegmenter, error) {
+	seg := &MandarenSegmenter{
+		freq: make(map[string]float64),
+		pos:  make(map[string]string),
+	}
+
+	start := time.Now()
+	file, err := os.Open(dictPath)
+	if err != nil {
+		return nil, newTTSError(ErrCodeModelLoad, "打开分词词典失败: "+dictPath, err)
+	}
+	defer file.Close()
+
+	total := 0.0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		freq, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		word := fields[0]
+		seg.freq[word] = freq
+		total += freq
+		if len(fields) >= 3 {
+			seg.pos[word] = fields[2]
+		}
+		if n := len([]rune(word)); n > seg.maxWordLen {
+			seg.maxWordLen = n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, newTTSError(ErrCodeModelLoad, "读取分词词典失败: "+dictPath, err)
+	}
+	if len(seg.freq) == 0 {
+		return nil, newTTSError(ErrCodeModelLoad, "分词词典为空: "+dictPath, nil)
+	}
+	seg.logTotal = math.Log(total)
+	fmt.Printf("加载分词词典完成条数: %d (耗时: %v)\n", len(seg.freq), time.Since(start))
+	return seg, nil
+}
+
+// Contains 词典中是否有该词
+func (s *MandarenSegmenter) Contains(word string) bool {
+	_, ok := s.freq[word]
+	return ok
+}
+
+// Cut 最大概率路径分词
+func (s *MandarenSegmenter) Cut(text string) []string {
+	runes := []rune(text)
+	n := len(runes)
+	if n == 0 {
+		return nil
+	}
+
+	// route[i] 为从 i 开始到句尾的最大对数概率及第一个词的结束位置
+	type routeItem struct {
+		logProb float64
+		end     int
+	}
+	route := make([]routeItem, n+1)
+	for i := n - 1; i >= 0; i-- {
+		// 单字总是候选，保证有路径
+		best := routeItem{logProb: s.wordLogProb(string(runes[i])) + route[i+1].logProb, end: i + 1}
+		for j := i + 2; j <= n && j-i <= s.maxWordLen; j++ {
+			word := string(runes[i:j])
+			if !s.Contains(word) {
+				continue
+			}
+			logProb := s.wordLogProb(word) + route[j].logProb
+			if logProb > best.logProb {
+				best = routeItem{logProb: logProb, end: j}
+			}
+		}
+		route[i] = best
+	}
+
+	words := []string{}
+	for i := 0; i < n; i = route[i].end {
+		words = append(words, string(runes[i:route[i].end]))
+	}
+	return words
+}
+
+// CutWithPOS 分词并标注词性，词典中没有的词标为 x
+func (s *MandarenSegmenter) CutWithPOS(text string) []MandarenWord {
+	words := s.Cut(text)
+	result := make([]MandarenWord, 0, len(words))
+	for _, word := range words {
+		pos, ok := s.pos[word]
+		if !ok {
+			pos = "x"
+		}
+		result = append(result, MandarenWord{Word: word, POS: pos})
+	}
+	return result
+}
+
+// CutForSearch 搜索引擎模式：在精确分词基础上，长词再给出词典中的2字、3字子词
+func (s *MandarenSegmenter) CutForSearch(text string) []string {
+	result := []string{}
+	for _, word := range s.Cut(text) {
+		runes := []rune(word)
+		for _, gram := range []int{2, 3} {
+			if len(runes) <= gram {
+				continue
+			}
+			for i := 0; i+gram <= len(runes); i++ {
+				sub := string(runes[i : i+gram])
+				if s.Contains(sub) {
+					result = append(result, sub)
+				}
+			}
+		}
+		result = append(result, word)
+	}
+	return result
+}
+
+func (s *MandarenSegmenter) wordLogProb(word string) float64 {
+	freq := s.freq[word]
+	if freq <= 0 {
+		freq = 1
+	}
+	return math.Log(freq) - s.logTotal
+}
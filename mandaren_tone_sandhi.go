@@ -0,0 +1,422 @@
+package main
+
+// 普通话变调，规则移植自 MeloTTS text/tone_sandhi.py 的 ToneSandhi：
+// 先分词并合并（不/一/叠词/连续三声/儿化），再按词做 不、一、轻声、三声 变调
+// 内部以 5 表示轻声，与 MeloTTS 一致；进出时与本项目的 0 互相转换
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// 本项目拼音无数字时声调为0，即轻声
+const mandarenNeutralTone = 0
+
+// 变调规则内部使用的轻声
+const sandhiNeutralTone = 5
+
+// 必读轻声的词
+var mustNeuralToneWords = toWordSet(`麻烦 麻利 鸳鸯 高粱 骨头 骆驼 马虎 首饰 馒头 馄饨 风筝 难为 队伍 阔气 闺女 门道 锄头 铺盖 铃铛 铁匠 钥匙 里脊 里头 部分 那么 道士 造化 迷糊 连累 这么 这个 运气 过去 软和 转悠 踏实 跳蚤 跟头 趔趄 财主 豆腐 讲究 记性 记号 认识 规矩 见识 裁缝 补丁 衣裳 衣服 衙门 街坊 行李 行当 蛤蟆 蘑菇 薄荷 葫芦 葡萄 萝卜 荸荠 苗条 苗头 苍蝇 芝麻 舒服 舒坦 舌头 自在 膏药 脾气 脑袋 脊梁 能耐 胳膊 胭脂 胡萝 胡琴 胡同 聪明 耽误 耽搁 耷拉 耳朵 老爷 老实 老婆 老头 老太 翻腾 罗嗦 罐头 编辑 结实 红火 累赘 糨糊 糊涂 精神 粮食 簸箕 篱笆 算计 算盘 答应 笤帚 笑语 笑话 窟窿 窝囊 窗户 稳当 稀罕 称呼 秧歌 秀气 秀才 福气 祖宗 砚台 码头 石榴 石头 石匠 知识 眼睛 眯缝 眨巴 眉毛 相声 盘算 白净 痢疾 痛快 疟疾 疙瘩 疏忽 畜生 生意 甘蔗 琵琶 琢磨 琉璃 玻璃 玫瑰 玄乎 狐狸 状元 特务 牲口 牙碜 牌楼 爽快 爱人 热闹 烧饼 烟筒 烂糊 点心 炊帚 灯笼 火候 漂亮 滑溜 溜达 温和 清楚 消息 浪头 活泼 比方 正经 欺负 模糊 槟榔 棺材 棒槌 棉花 核桃 栅栏 柴火 架势 枕头 枇杷 机灵 本事 木头 木匠 朋友 月饼 月亮 暖和 明白 时候 新鲜 故事 收拾 收成 提防 挖苦 挑剔 指甲 指头 拾掇 拳头 拨弄 招牌 招呼 抬举 护士 折腾 扫帚 打量 打算 打点 打扮 打听 打发 扎实 扁担 戒指 懒得 意识 意思 情形 悟性 怪物 思量 怎么 念头 念叨 快活 忙活 志气 心思 得罪 张罗 弟兄 开通 应酬 庄稼 干事 帮手 帐篷 希罕 师父 师傅 巴结 巴掌 差事 工夫 岁数 屁股 尾巴 少爷 小气 小伙 将就 对头 对付 寡妇 家伙 客气 实在 官司 学问 学生 字号 嫁妆 媳妇 媒人 婆家 娘家 委屈 姑娘 姐夫 妯娌 妥当 妖精 奴才 女婿 头发 太阳 大爷 大方 大意 大夫 多少 多么 外甥 壮实 地道 地方 在乎 困难 嘴巴 嘱咐 嘟囔 嘀咕 喜欢 喇嘛 喇叭 商量 唾沫 哑巴 哈欠 哆嗦 咳嗽 和尚 告诉 告示 含糊 吓唬 后头 名字 名堂 合同 吆喝 叫唤 口袋 厚道 厉害 千斤 包袱 包涵 匀称 勤快 动静 动弹 功夫 力气 前头 刺猬 刺激 别扭 利落 利索 利害 分析 出息 凑合 凉快 冷战 冤枉 冒失 养活 关系 先生 兄弟 便宜 使唤 佩服 作坊 体面 位置 似的 伙计 休息 什么 人家 亲戚 亲家 交情 云彩 事情 买卖 主意 丫头 丧气 两口 东西 东家 世故 不由 不在 下水 下巴 上头 上司 丈夫 丈人 一辈 那个 菩萨 父亲 母亲 咕噜 邋遢 费用 冤家 甜头 介绍 荒唐 大人 泥鳅 幸福 熟悉 计划 扑腾 蜡烛 姥爷 照顾 喉咙 吉他 弄堂 蚂蚱 凤凰 拖沓 寒碜 糟蹋 倒腾 报复 逻辑 盘缠 喽啰 牢骚 咖喱 扫把 惦记`)
+
+// 不读轻声的词
+var mustNotNeuralToneWords = toWordSet(`男子 女子 分子 原子 量子 莲子 石子 瓜子 电子 人人 虎虎 幺幺 干嘛 学子 哈哈 数数 袅袅 局地 以下 娃哈哈 花花草草 留得 耕地 想想 熙熙 攘攘 卵子 死死 冉冉 恳恳 佼佼 吵吵 打打 考考 整整 莘莘 落地 算子 家家户户 青青`)
+
+// 中文数字字符，对应 Python str.isnumeric 对汉字数字的判断
+const chineseNumericChars = "零〇一二三四五六七八九十百千万亿两壹贰叁肆伍陆柒捌玖拾佰仟"
+
+// 变调时视为标点的字符，"一" 后接标点仍读一声
+const sandhiPunctuation = "、：，；。？！“”‘’':,;.?!"
+
+func toWordSet(words string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range strings.Fields(words) {
+		set[word] = struct{}{}
+	}
+	return set
+}
+
+func inWordSet(set map[string]struct{}, word string) bool {
+	_, ok := set[word]
+	return ok
+}
+
+// 变调处理中的词，tones 与字一一对应
+type sandhiWord struct {
+	chars []rune
+	pos   string
+	tones []int
+}
+
+func (w *sandhiWord) text() string {
+	return string(w.chars)
+}
+
+// ApplyToneSandhi 对一段中文做变调，tones 为逐字声调（0为轻声），返回变调后的逐字声调
+// 字数与声调数不一致时（如有字无拼音）无法对齐，原样返回
+func ApplyToneSandhi(text string, tones []int) []int {
+	runes := []rune(text)
+	if len(runes) != len(tones) || len(runes) == 0 {
+		return tones
+	}
+
+	// 分词器由 MandarenResourcePreload 加载，按单字切分时词级变调规则都不会生效，不做变调
+	if mandarenSegmenter == nil {
+		fmt.Printf("分词词典未加载，跳过变调\n")
+		return tones
+	}
+	cut := mandarenSegmenter.CutWithPOS(text)
+
+	seg := make([]*sandhiWord, 0, len(cut))
+	offset := 0
+	for _, item := range cut {
+		n := len([]rune(item.Word))
+		wordTones := make([]int, n)
+		for i := 0; i < n; i++ {
+			wordTones[i] = tones[offset+i]
+			if wordTones[i] == mandarenNeutralTone {
+				wordTones[i] = sandhiNeutralTone
+			}
+		}
+		seg = append(seg, &sandhiWord{chars: runes[offset : offset+n], pos: item.POS, tones: wordTones})
+		offset += n
+	}
+
+	seg = preMergeForModify(seg)
+
+	result := make([]int, 0, len(tones))
+	for _, word := range seg {
+		for _, tone := range modifiedTone(word) {
+			if tone == sandhiNeutralTone {
+				tone = mandarenNeutralTone
+			}
+			result = append(result, tone)
+		}
+	}
+	return result
+}
+
+func modifiedTone(w *sandhiWord) []int {
+	buSandhi(w)
+	yiSandhi(w)
+	neuralSandhi(w)
+	threeSandhi(w)
+	return w.tones
+}
+
+func preMergeForModify(seg []*sandhiWord) []*sandhiWord {
+	seg = mergeBu(seg)
+	seg = mergeYi(seg)
+	seg = mergeReduplication(seg)
+	seg = mergeContinuousThreeTones(seg)
+	seg = mergeContinuousThreeTones2(seg)
+	seg = mergeEr(seg)
+	return seg
+}
+
+// 合并两个词，返回新词，词性取 a 的；不修改输入，合并时仍可按原分词判断
+func concatWords(a, b *sandhiWord) *sandhiWord {
+	return &sandhiWord{
+		chars: append(append([]rune{}, a.chars...), b.chars...),
+		pos:   a.pos,
+		tones: append(append([]int{}, a.tones...), b.tones...),
+	}
+}
+
+func allToneThree(tones []int) bool {
+	for _, tone := range tones {
+		if tone != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func isReduplication(w *sandhiWord) bool {
+	return len(w.chars) == 2 && w.chars[0] == w.chars[1]
+}
+
+func isNumericChar(r rune) bool {
+	return unicode.IsDigit(r) || strings.ContainsRune(chineseNumericChars, r)
+}
+
+// 不 与后面的词合并
+func mergeBu(seg []*sandhiWord) []*sandhiWord {
+	newSeg := []*sandhiWord{}
+	var last *sandhiWord
+	for _, word := range seg {
+		if last != nil && last.text() == "不" {
+			merged := concatWords(last, word)
+			merged.pos = word.pos
+			word = merged
+		}
+		if word.text() != "不" {
+			newSeg = append(newSeg, word)
+		}
+		last = word
+	}
+	if last != nil && last.text() == "不" {
+		newSeg = append(newSeg, &sandhiWord{chars: last.chars, pos: "d", tones: last.tones})
+	}
+	return newSeg
+}
+
+// 一 与左右叠词合并（听一听），单独的 一 与后面的词合并
+func mergeYi(seg []*sandhiWord) []*sandhiWord {
+	newSeg := []*sandhiWord{}
+	for i, word := range seg {
+		if i-1 >= 0 && word.text() == "一" && i+1 < len(seg) &&
+			seg[i-1].text() == seg[i+1].text() && seg[i-1].pos == "v" && len(newSeg) > 0 {
+			newSeg[len(newSeg)-1] = concatWords(concatWords(newSeg[len(newSeg)-1], word), seg[i+1])
+			continue
+		}
+		if i-2 >= 0 && seg[i-1].text() == "一" && seg[i-2].text() == word.text() && word.pos == "v" {
+			continue
+		}
+		newSeg = append(newSeg, word)
+	}
+
+	seg = newSeg
+	newSeg = []*sandhiWord{}
+	for _, word := range seg {
+		if len(newSeg) > 0 && newSeg[len(newSeg)-1].text() == "一" {
+			newSeg[len(newSeg)-1] = concatWords(newSeg[len(newSeg)-1], word)
+		} else {
+			newSeg = append(newSeg, word)
+		}
+	}
+	return newSeg
+}
+
+// 相同的词连续出现时合并（如 看看）
+func mergeReduplication(seg []*sandhiWord) []*sandhiWord {
+	newSeg := []*sandhiWord{}
+	for _, word := range seg {
+		if len(newSeg) > 0 && word.text() == newSeg[len(newSeg)-1].text() {
+			newSeg[len(newSeg)-1] = concatWords(newSeg[len(newSeg)-1], word)
+		} else {
+			newSeg = append(newSeg, word)
+		}
+	}
+	return newSeg
+}
+
+// 前后两个词都全是三声时合并，合并后不超过3个字
+func mergeContinuousThreeTones(seg []*sandhiWord) []*sandhiWord {
+	return mergeThreeTonesBy(seg, func(prev, cur *sandhiWord) bool {
+		return allToneThree(prev.tones) && allToneThree(cur.tones)
+	})
+}
+
+// 前一个词的末字与后一个词的首字都是三声时合并
+func mergeContinuousThreeTones2(seg []*sandhiWord) []*sandhiWord {
+	return mergeThreeTonesBy(seg, func(prev, cur *sandhiWord) bool {
+		return len(prev.tones) > 0 && len(cur.tones) > 0 &&
+			prev.tones[len(prev.tones)-1] == 3 && cur.tones[0] == 3
+	})
+}
+
+func mergeThreeTonesBy(seg []*sandhiWord, match func(prev, cur *sandhiWord) bool) []*sandhiWord {
+	newSeg := []*sandhiWord{}
+	mergeLast := make([]bool, len(seg))
+	for i, word := range seg {
+		if i-1 >= 0 && match(seg[i-1], word) && !mergeLast[i-1] &&
+			!isReduplication(seg[i-1]) && len(seg[i-1].chars)+len(word.chars) <= 3 {
+			newSeg[len(newSeg)-1] = concatWords(newSeg[len(newSeg)-1], word)
+			mergeLast[i] = true
+		} else {
+			newSeg = append(newSeg, word)
+		}
+	}
+	return newSeg
+}
+
+// 儿化：儿 与前面的词合并
+func mergeEr(seg []*sandhiWord) []*sandhiWord {
+	newSeg := []*sandhiWord{}
+	for i, word := range seg {
+		if i-1 >= 0 && word.text() == "儿" && seg[i-1].text() != "#" {
+			newSeg[len(newSeg)-1] = concatWords(newSeg[len(newSeg)-1], word)
+		} else {
+			newSeg = append(newSeg, word)
+		}
+	}
+	return newSeg
+}
+
+func buSandhi(w *sandhiWord) {
+	// 看不懂：中间的 不 读轻声
+	if len(w.chars) == 3 && w.chars[1] == '不' {
+		w.tones[1] = sandhiNeutralTone
+		return
+	}
+	// 不 在四声前读二声，如 不怕
+	for i, char := range w.chars {
+		if char == '不' && i+1 < len(w.chars) && w.tones[i+1] == 4 {
+			w.tones[i] = 2
+		}
+	}
+}
+
+func yiSandhi(w *sandhiWord) {
+	if !strings.ContainsRune(string(w.chars), '一') {
+		return
+	}
+	// 数字串中的 一 不变调，如 一零零
+	allNumeric := true
+	for _, char := range w.chars {
+		if char != '一' && !isNumericChar(char) {
+			allNumeric = false
+			break
+		}
+	}
+	if allNumeric {
+		return
+	}
+	// 叠词中间的 一 读轻声，如 看一看
+	if len(w.chars) == 3 && w.chars[1] == '一' && w.chars[0] == w.chars[2] {
+		w.tones[1] = sandhiNeutralTone
+		return
+	}
+	// 序数 第一 读一声
+	if strings.HasPrefix(w.text(), "第一") {
+		w.tones[1] = 1
+		return
+	}
+	for i, char := range w.chars {
+		if char != '一' || i+1 >= len(w.chars) {
+			continue
+		}
+		if w.tones[i+1] == 4 {
+			// 一 在四声前读二声，如 一段
+			w.tones[i] = 2
+		} else if !strings.ContainsRune(sandhiPunctuation, w.chars[i+1]) {
+			// 一 在非四声前读四声，如 一天
+			w.tones[i] = 4
+		}
+	}
+}
+
+func neuralSandhi(w *sandhiWord) {
+	word := w.text()
+	n := len(w.chars)
+	last := n - 1
+
+	// 名词、动词、形容词的叠字，如 奶奶、试试
+	for j := 1; j < n; j++ {
+		if w.chars[j] == w.chars[j-1] && len(w.pos) > 0 && strings.ContainsRune("nva", rune(w.pos[0])) &&
+			!inWordSet(mustNotNeuralToneWords, word) {
+			w.tones[j] = sandhiNeutralTone
+		}
+	}
+
+	geIdx := strings.IndexRune(word, '个')
+	if geIdx >= 0 {
+		geIdx = len([]rune(word[:geIdx]))
+	}
+
+	switch {
+	case n >= 1 && strings.ContainsRune("吧呢啊呐噻嘛吖嗨哦哒额滴哩哟喽啰耶喔诶", w.chars[last]):
+		w.tones[last] = sandhiNeutralTone
+	case n >= 1 && strings.ContainsRune("的地得", w.chars[last]):
+		w.tones[last] = sandhiNeutralTone
+	// 走了、看着、去过
+	case n == 1 && strings.ContainsRune("了着过", w.chars[0]) && (w.pos == "ul" || w.pos == "uz" || w.pos == "ug"):
+		w.tones[last] = sandhiNeutralTone
+	case n > 1 && strings.ContainsRune("们子", w.chars[last]) && (w.pos == "r" || w.pos == "n") &&
+		!inWordSet(mustNotNeuralToneWords, word):
+		w.tones[last] = sandhiNeutralTone
+	// 桌上、地下、家里
+	case n > 1 && strings.ContainsRune("上下里", w.chars[last]) && (w.pos == "s" || w.pos == "l" || w.pos == "f"):
+		w.tones[last] = sandhiNeutralTone
+	// 上来、下去
+	case n > 1 && strings.ContainsRune("来去", w.chars[last]) && strings.ContainsRune("上下进出回过起开", w.chars[n-2]):
+		w.tones[last] = sandhiNeutralTone
+	// 个作量词
+	case (geIdx >= 1 && (isNumericChar(w.chars[geIdx-1]) || strings.ContainsRune("几有两半多各整每做是", w.chars[geIdx-1]))) || word == "个":
+		w.tones[geIdx] = sandhiNeutralTone
+	default:
+		if inWordSet(mustNeuralToneWords, word) || (n >= 2 && inWordSet(mustNeuralToneWords, string(w.chars[n-2:]))) {
+			w.tones[last] = sandhiNeutralTone
+		}
+	}
+
+	// 拆成两个子词后再检查一次必读轻声词
+	first, second := splitSandhiWord(w.chars)
+	for _, sub := range [][2]int{{0, first}, {first, first + second}} {
+		subChars := w.chars[sub[0]:sub[1]]
+		if len(subChars) == 0 {
+			continue
+		}
+		subWord := string(subChars)
+		if inWordSet(mustNeuralToneWords, subWord) ||
+			(len(subChars) >= 2 && inWordSet(mustNeuralToneWords, string(subChars[len(subChars)-2:]))) {
+			w.tones[sub[1]-1] = sandhiNeutralTone
+		}
+	}
+}
+
+func threeSandhi(w *sandhiWord) {
+	n := len(w.chars)
+	switch {
+	case n == 2 && allToneThree(w.tones):
+		w.tones[0] = 2
+	case n == 3:
+		first, _ := splitSandhiWord(w.chars)
+		if allToneThree(w.tones) {
+			if first == 2 {
+				// 双音节+单音节，如 蒙古/包
+				w.tones[0] = 2
+				w.tones[1] = 2
+			} else if first == 1 {
+				// 单音节+双音节，如 纸/老虎
+				w.tones[1] = 2
+			}
+			return
+		}
+		parts := [][]int{w.tones[:first], w.tones[first:]}
+		for i, sub := range parts {
+			if allToneThree(sub) && len(sub) == 2 {
+				// 所有/人
+				sub[0] = 2
+			} else if i == 1 && len(sub) > 0 && len(parts[0]) > 0 && !allToneThree(sub) &&
+				sub[0] == 3 && parts[0][len(parts[0])-1] == 3 {
+				// 好/喜欢
+				parts[0][len(parts[0])-1] = 2
+			}
+		}
+	case n == 4:
+		// 四字成语按两两拆分
+		for _, sub := range [][]int{w.tones[:2], w.tones[2:]} {
+			if allToneThree(sub) {
+				sub[0] = 2
+			}
+		}
+	}
+}
+
+// 把词拆为两个子词，返回两个子词的字数
+// 与 MeloTTS 一致：取搜索模式分词中最短的子词，它在词首则作为第一个子词，否则作为第二个
+func splitSandhiWord(chars []rune) (int, int) {
+	word := string(chars)
+	candidates := []string{}
+	if mandarenSegmenter != nil {
+		candidates = mandarenSegmenter.CutForSearch(word)
+	}
+	if len(candidates) == 0 {
+		candidates = []string{string(chars[:1])}
+	}
+
+	shortest := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if len([]rune(candidate)) < len([]rune(shortest)) {
+			shortest = candidate
+		}
+	}
+
+	n := len([]rune(shortest))
+	if strings.HasPrefix(word, shortest) {
+		return n, len(chars) - n
+	}
+	return len(chars) - n, n
+}
@@ -6,9 +6,8 @@ import (
 	"io"
 	"encoding/json"
 	"fmt"
-	"log"
 	"runtime"
-	"encoding/binary"
+	"strings"
 	ort "github.com/yalue/onnxruntime_go"	
 )
 
@@ -39,16 +38,21 @@ type XWX_TTS struct {
 	bertModelPath string
 	bertTokenizerPath string
 
+	model *TTSModelConfig // 模型注册表中的配置
 	symbolIDMap map[string]int
 	session *ort.DynamicAdvancedSession
+	durationOutputName string // 模型导出的时长输出名，没有时为空
+	batchUnsupported bool // 模型不支持 batch>1 推理时置位，之后不再合批
+	noiseWChannels int // 模型导出了噪声输入时的通道数，否则为0，见 tts-seed.go
+	noiseZChannels int
 	bertExtractor *BERTFeatureExtractor
 }
 
 
 
-func init_onnx_environment() {
+func init_onnx_environment() error {
 	if ort.IsInitialized() {
-		return
+		return nil
 	}
 	// 设置ONNX Runtime环境
 	// 判断当前系统
@@ -57,7 +61,7 @@ func init_onnx_environment() {
 	} else if runtime.GOOS == "linux" {
 		ort.SetSharedLibraryPath("./onnxruntime-linux-x64-gpu-1.23.2/lib/libonnxruntime.so")
 	} else {
-		panic("不支持的操作系统")
+		return newTTSError(ErrCodeModelLoad, "不支持的操作系统: "+runtime.GOOS, nil)
 	}
 
 
@@ -69,13 +73,13 @@ func init_onnx_environment() {
 	
     err := ort.InitializeEnvironment()
     if err != nil {
-        panic(err)
+        return newTTSError(ErrCodeModelLoad, "初始化ONNX Runtime失败", err)
     }
 	//对象销毁时释放
     //defer ort.DestroyEnvironment()
 
 	fmt.Println("ONNX Runtime initialized successfully")
-
+	return nil
 }
 
 
@@ -84,25 +88,49 @@ func NewXWX_TTS(language Language, deviceType DeviceType) (*XWX_TTS, error) {
 	start := time.Now()
 	cpuCores := runtime.NumCPU()
     fmt.Printf("CPU核心数: %d\n", cpuCores)
+	// 引擎池中的多个会话平分CPU核心，避免线程过量争抢
+	if ttsEnginePoolSize > 1 {
+		cpuCores = max(1, cpuCores/ttsEnginePoolSize)
+		fmt.Printf("引擎池大小: %d, 每个会话按 %d 核配置线程\n", ttsEnginePoolSize, cpuCores)
+	}
 	
 	m := &XWX_TTS{
 		cpuCores: cpuCores,
 		language: language,
 		deviceType: deviceType,
 	}
-	init_onnx_environment()
-	m.prepareModelPath()
-
-	m.load_symbolid()
-	m.init_tts_onnx_model()
-	m.init_bert_model()
+	if err := init_onnx_environment(); err != nil {
+		return nil, err
+	}
+	if err := m.prepareModelPath(); err != nil {
+		return nil, err
+	}
+	if err := m.load_symbolid(); err != nil {
+		return nil, err
+	}
+	if err := m.init_tts_onnx_model(); err != nil {
+		return nil, err
+	}
+	if err := m.init_bert_model(); err != nil {
+		m.Destroy()
+		return nil, err
+	}
 
 	//g2p相关资源预加载
-	CantoneseResourcePreload()
+	if err := CantoneseResourcePreload(); err != nil {
+		m.Destroy()
+		return nil, err
+	}
 	// 预加载英文g2p字典
-	EnglishResourcePreload()
+	if err := EnglishResourcePreload(); err != nil {
+		m.Destroy()
+		return nil, err
+	}
 	// 预加载普通话g2p字典
-	MandarenResourcePreload()
+	if err := MandarenResourcePreload(); err != nil {
+		m.Destroy()
+		return nil, err
+	}
 
 	fmt.Printf("初始化tts引擎耗时: %v\n", time.Since(start))
 
@@ -151,10 +179,10 @@ func (m *XWX_TTS) Destroy() {
 
 // }
 
-func (m *XWX_TTS) init_tts_onnx_model() {
+func (m *XWX_TTS) init_tts_onnx_model() error {
 	options, err := ort.NewSessionOptions()
 	if err != nil {
-		panic(err)
+		return newTTSError(ErrCodeModelLoad, "创建会话配置失败", err)
 	}
 	defer options.Destroy()
 
@@ -183,13 +211,28 @@ func (m *XWX_TTS) init_tts_onnx_model() {
 	inputNames := []string{"x", "x_lengths", "tones", "sid", "bert", "ja_bert", "sdp_ratio", "noise_scale", "noise_scale_w", "length_scale"}
 	
 	outputNames := []string{"y"}
+	// 模型额外导出了时长或对齐矩阵时一并取出，用于计算时间戳
+	inputInfos, outputInfos, err := ort.GetInputOutputInfo(m.ttsModelPath)
+	if err != nil {
+		return newTTSError(ErrCodeModelLoad, "读取TTS模型输出信息失败: "+m.ttsModelPath, err)
+	}
+	// 模型把噪声导出为输入时按 seed 生成噪声，合成可复现
+	inputNames = append(inputNames, m.detectNoiseInputs(inputInfos)...)
+	for _, info := range outputInfos {
+		if _, ok := ttsDurationOutputNames[info.Name]; ok {
+			m.durationOutputName = info.Name
+			outputNames = append(outputNames, info.Name)
+			fmt.Printf("TTS模型导出了时长输出: %s\n", info.Name)
+			break
+		}
+	}
 
 	// 根据设备类型配置执行提供程序
 	if m.deviceType == GPU {
 		// 1. 创建 CUDA 提供程序选项
 		cudaOptions, err := ort.NewCUDAProviderOptions()
 		if err != nil {
-			log.Fatal("无法创建 CUDA 选项:", err)
+			return newTTSError(ErrCodeModelLoad, "无法创建 CUDA 选项", err)
 		}
 		defer cudaOptions.Destroy() // 使用完记得销毁，释放 C 内存
 
@@ -199,7 +242,7 @@ func (m *XWX_TTS) init_tts_onnx_model() {
 			"device_id": "0",
 		})
 		if err != nil {
-			log.Fatal("设置 CUDA 参数失败:", err)
+			return newTTSError(ErrCodeModelLoad, "设置 CUDA 参数失败", err)
 		}
 
 		// 3. 将选项添加进会话配置中
@@ -218,75 +261,73 @@ func (m *XWX_TTS) init_tts_onnx_model() {
     
     dynamicSession, err := ort.NewDynamicAdvancedSession(m.ttsModelPath, inputNames, outputNames, options)
 	if err != nil {
-		panic(err)
+		return newTTSError(ErrCodeModelLoad, "加载TTS模型失败: "+m.ttsModelPath, err)
 	}
 	m.session = dynamicSession
+	return nil
 }
 
-func (m *XWX_TTS) init_bert_model() {
+func (m *XWX_TTS) init_bert_model() error {
 
 	bertExtractor, err := NewBERTFeatureExtractor(m.bertModelPath, m.bertTokenizerPath)
 	if err != nil {
-		log.Fatalf("创建BERT特征提取器失败: %v", err)
-	}else{
-		//log.Infof("创建BERT特征提取器成功: %v", bertExtractor)
+		return newTTSError(ErrCodeModelLoad, "创建BERT特征提取器失败", err)
 	}
-	//defer bertExtractor.Destroy()
 	//销毁权归于 XWX_TTS
 	m.bertExtractor = bertExtractor
+	return nil
 }
 
-func (m *XWX_TTS)prepareModelPath() {
-	// 根据语言设置模型路径
-	if m.language == YUE_EN {
-		m.ttsModelPath = 	  "./yue_en_tts-model.onnx"
-		m.bertModelPath = 	  "./bert-base-multilingual-cased.onnx"
-		m.bertTokenizerPath = "./bert-base-multilingual-cased.json"
-	}
-	if m.language == ZH_X {
-		m.ttsModelPath = 	  "./zh_x_tts-model.onnx"
-		m.bertModelPath = 	  "./bert-base-multilingual-uncased.onnx"
-		m.bertTokenizerPath = "./bert-base-multilingual-uncased.json"
+func (m *XWX_TTS)prepareModelPath() error {
+	// 根据语言从模型注册表取模型路径
+	model, err := lookupTTSModel(m.language)
+	if err != nil {
+		return err
 	}
+	m.model = model
+	m.ttsModelPath = model.Model
+	m.bertModelPath = model.BertModel
+	m.bertTokenizerPath = model.BertTokenizer
 
 	//fmt.Println("m.language:", m.language)
 	//fmt.Println("m.ttsModelPath:", m.ttsModelPath)
 	
 	// 检查文件是否存在
-	if _, err := os.Stat(m.ttsModelPath); os.IsNotExist(err) {
-		panic(fmt.Sprintf("模型文件不存在: %s", m.ttsModelPath))
-	}
-	if _, err := os.Stat(m.bertModelPath); os.IsNotExist(err) {
-		panic(fmt.Sprintf("模型文件不存在: %s", m.bertModelPath))
-	}
-	if _, err := os.Stat(m.bertTokenizerPath); os.IsNotExist(err) {
-		panic(fmt.Sprintf("模型文件不存在: %s", m.bertTokenizerPath))
+	for _, path := range []string{m.ttsModelPath, m.bertModelPath, m.bertTokenizerPath, model.Symbols} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return newTTSError(ErrCodeModelNotFound, "模型文件不存在: "+path, nil)
+		}
 	}
+	return nil
 }
 
-func (m *XWX_TTS)load_symbolid() {
+func (m *XWX_TTS)load_symbolid() error {
 	// 加载音素符号ID映射
-	symbolidFileName := ""
-	if m.language == YUE_EN {
-		symbolidFileName = "yue_en_symbolid.json"
-	} else if m.language == ZH_X {
-		symbolidFileName = "zh_x_symbolid.json"
-	}
+	symbolidFileName := m.model.Symbols
 
 	symbolIDMap := make(map[string]int)
 	jsonFile, err := os.Open(symbolidFileName)
 	if err != nil {
-		panic(err)
+		return newTTSError(ErrCodeModelNotFound, "音素ID文件不存在: "+symbolidFileName, err)
 	}
 	defer jsonFile.Close()
-	byteValue, _ := io.ReadAll(jsonFile)
+	byteValue, err := io.ReadAll(jsonFile)
+	if err != nil {
+		return newTTSError(ErrCodeModelLoad, "读取音素ID文件失败: "+symbolidFileName, err)
+	}
 	var jsonData map[string][]string
-	json.Unmarshal(byteValue, &jsonData)
+	if err := json.Unmarshal(byteValue, &jsonData); err != nil {
+		return newTTSError(ErrCodeModelLoad, "解析音素ID文件失败: "+symbolidFileName, err)
+	}
 	symbols := jsonData["symbols"]
+	if len(symbols) == 0 {
+		return newTTSError(ErrCodeModelLoad, "音素ID文件缺少 symbols: "+symbolidFileName, nil)
+	}
 	for i, symbol := range symbols {
 		symbolIDMap[symbol] = i
 	}
 	m.symbolIDMap = symbolIDMap
+	return nil
 }
 
 func (m *XWX_TTS)mapping_phones(phones []string) []int64 {
@@ -324,85 +365,183 @@ func (m *XWX_TTS)mapping_word2ph(word2ph []int) []int64 {
 }
 
 // 推理得到pcm音频数据 speakerid一般为0， speed为 0.5~2.0
-// 返回数据为float32类型的pcm音频数据, 采样率24000
-func (m *XWX_TTS)Tts_pcm(text string, speakerid int, speed float32) []float32 {
+// 返回数据为float32类型的pcm音频数据, 采样率见 SampleRate()，默认24000
+// lexicon 为用户发音词典，可为 nil；params 为推理超参数，nil 时使用模型配置的默认值
+// 音素数超过 TTS_CHUNK_MAX_PHONES 的长文本分块推理后拼接，见 tts-text-chunker.go
+func (m *XWX_TTS)Tts_pcm(text string, lexicon *UserLexicon, speakerid int, speed float32, params *TTSInferenceParams) ([]float32, error) {
+	chunks, err := m.planChunks(text, lexicon)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) > 1 {
+		return m.synthesizeChunks(chunks, speakerid, speed, params)
+	}
+	return m.Tts_phones(chunks[0].phones, chunks[0].tones, chunks[0].word2ph, chunks[0].text, speakerid, speed, params)
+}
+
+// 按模型配置的g2p前端做g2p，返回的音素首尾带 "_"，filteredText 用于提取BERT特征
+func (m *XWX_TTS)G2p(text string, lexicon *UserLexicon) ([]string, []int, []int, string, error) {
+	if m.model.Frontend == FrontendCantonese {
+		return CantoneseMix_g2p(text, m.bertExtractor, lexicon)
+	} else if m.model.Frontend == FrontendMandarin {
+		return MandarenMix_g2p(text, m.bertExtractor, lexicon)
+	}
+	return nil, nil, nil, "", newTTSError(ErrCodeUnsupportedLanguage, "不支持的g2p前端: "+string(m.model.Frontend), nil)
+}
+
+// 模型中本语言声调的偏移，melotts 所有语言的声调共用一张表
+func (m *XWX_TTS)toneOffset() int {
+	return *m.model.ToneOffset
+}
+
+// params 为 nil 时取模型配置的默认值，否则校验取值范围
+func (m *XWX_TTS)inferenceParams(params *TTSInferenceParams) (*TTSInferenceParams, error) {
+	if params == nil {
+		return &m.model.Inference, nil
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// 模型输出音频的采样率
+func (m *XWX_TTS)SampleRate() int {
+	return m.model.SampleRate
+}
+
+// 由音素直接推理，mix_phones 首尾需带 "_"，mix_word2ph 与 filteredText 的BERT token对齐
+func (m *XWX_TTS)Tts_phones(mix_phones []string, mix_tones []int, mix_word2ph []int, filteredText string, speakerid int, speed float32, params *TTSInferenceParams) ([]float32, error) {
+	data, _, err := m.tts_phones_durations(mix_phones, mix_tones, mix_word2ph, filteredText, speakerid, speed, params)
+	return data, err
+}
+
+// 同 Tts_phones，另外返回每个音素（含隔位0）的时长帧数，模型没有导出时长时为 nil
+func (m *XWX_TTS)tts_phones_durations(mix_phones []string, mix_tones []int, mix_word2ph []int, filteredText string, speakerid int, speed float32, params *TTSInferenceParams) ([]float32, []float32, error) {
 	startTime000 := time.Now()
-	mix_phones := []string{"_"}
-	mix_tones := []int{0}
-	mix_word2ph := []int{1}
-	filteredText := ""
-
-	toneOffset := 20
-	if m.language == YUE_EN {
-		toneOffset = 20
-		mix_phones ,mix_tones, mix_word2ph, filteredText = CantoneseMix_g2p(text, m.bertExtractor)
-	} else if m.language == ZH_X {
-		toneOffset = 14
-		mix_phones ,mix_tones, mix_word2ph, filteredText = MandarenMix_g2p(text, m.bertExtractor)
+	if speed <= 0 {
+		return nil, nil, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("speed 必须大于0，当前为 %v", speed), nil)
+	}
+	params, err := m.inferenceParams(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 模型没有噪声输入时，带 seed 的请求以零噪声推理保证可复现
+	params = seededInferenceParams(params, m.SeedMode())
+	if speakerid < 0 {
+		return nil, nil, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("speaker_id 不能为负数，当前为 %d", speakerid), nil)
 	}
+
 	mappedPhones := m.mapping_phones(mix_phones)
-	mappedTones := m.mapping_tones(mix_tones, toneOffset)
+	mappedTones := m.mapping_tones(mix_tones, m.toneOffset())
 	mappedWord2ph := m.mapping_word2ph(mix_word2ph)
 		
 	mappedPhonesLen := int64(len(mappedPhones))
 	mappedTonesLen := int64(len(mappedTones))
 	//mappedWord2phLen := int64(len(mappedWord2ph))
+	if mappedPhonesLen != mappedTonesLen {
+		return nil, nil, newTTSError(ErrCodeG2P, fmt.Sprintf("音素与声调数量不一致: %d != %d", mappedPhonesLen, mappedTonesLen), nil)
+	}
 
 	
 	xShape := ort.NewShape(1, mappedPhonesLen)
-	xTensor, _ := ort.NewTensor(xShape, mappedPhones)  
+	xTensor, err := ort.NewTensor(xShape, mappedPhones)  
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建x张量失败", err)
+	}
 	defer xTensor.Destroy()
 
 	xLengthsData := []int64{mappedPhonesLen}
 	xLengthsShape := ort.NewShape(1)
-	xLengthsTensor, _ := ort.NewTensor(xLengthsShape, xLengthsData) // x_lengths 数据
+	xLengthsTensor, err := ort.NewTensor(xLengthsShape, xLengthsData) // x_lengths 数据
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建x_lengths张量失败", err)
+	}
 	defer xLengthsTensor.Destroy()
 
 	tonesShape := ort.NewShape(1, mappedTonesLen)
-	tonesTensor, _ := ort.NewTensor(tonesShape, mappedTones) // tones 数据	
+	tonesTensor, err := ort.NewTensor(tonesShape, mappedTones) // tones 数据	
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建tones张量失败", err)
+	}
 	defer tonesTensor.Destroy()
 	fmt.Printf("mappedTones: %v \n", mappedTones)
 	
 	sidData := []int64{int64(speakerid)} //speakerid ,外部指定
 	sidShape := ort.NewShape(1)
-	sidTensor, _ := ort.NewTensor(sidShape, sidData) // sid 数据
+	sidTensor, err := ort.NewTensor(sidShape, sidData) // sid 数据
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建sid张量失败", err)
+	}
 	defer sidTensor.Destroy()
 
 	//根据melotts逻辑，bertshape全0向量
 	bertShape := ort.NewShape(1, 1024, mappedPhonesLen)
-    bertTensor, _ := ort.NewEmptyTensor[float32](bertShape)
+    bertTensor, err := ort.NewEmptyTensor[float32](bertShape)
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建bert张量失败", err)
+	}
     defer bertTensor.Destroy()
 
 	jaBertTensor, err := m.bertExtractor.ExtractFeaturesForTTS(filteredText, mappedWord2ph)
-	fmt.Println("jaBertTensor形状:", jaBertTensor.GetShape())
 	if err != nil {
-		fmt.Printf("提取JA-BERT特征失败: %v", err)
+		return nil, nil, newTTSError(ErrCodeBERT, "提取JA-BERT特征失败", err)
 	}
 	defer jaBertTensor.Destroy()
+	fmt.Println("jaBertTensor形状:", jaBertTensor.GetShape())
 
-	sdpRatioData := []float32{0.5}
+	sdpRatioData := []float32{params.SdpRatio}
 	sdpRatioShape := ort.NewShape(1)
-	sdpRatioTensor, _ := ort.NewTensor(sdpRatioShape, sdpRatioData) // sdp_ratio 数据
+	sdpRatioTensor, err := ort.NewTensor(sdpRatioShape, sdpRatioData) // sdp_ratio 数据
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建sdp_ratio张量失败", err)
+	}
 	defer sdpRatioTensor.Destroy()
 
-	noiseScaleData := []float32{0.6}
+	noiseScaleData := []float32{params.NoiseScale}
 	noiseScaleShape := ort.NewShape(1)
-	noiseScaleTensor, _ := ort.NewTensor(noiseScaleShape, noiseScaleData) // noise_scale 数据
+	noiseScaleTensor, err := ort.NewTensor(noiseScaleShape, noiseScaleData) // noise_scale 数据
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建noise_scale张量失败", err)
+	}
 	defer noiseScaleTensor.Destroy()
 
 	//noiseScaleWTensor, _ := ort.NewEmptyTensor[float32](noiseScaleShape)
-	noiseScaleWTensor, _ := ort.NewTensor(noiseScaleShape, []float32{0.9})
+	noiseScaleWTensor, err := ort.NewTensor(noiseScaleShape, []float32{params.NoiseScaleW})
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建noise_scale_w张量失败", err)
+	}
     defer noiseScaleWTensor.Destroy()
 
 
 	lengthScaleData := []float32{float32(1.0 / speed)}
 	lengthScaleShape := ort.NewShape(1)
-	lengthScaleTensor, _ := ort.NewTensor(lengthScaleShape, lengthScaleData) // length_scale 数据
+	lengthScaleTensor, err := ort.NewTensor(lengthScaleShape, lengthScaleData) // length_scale 数据
+	if err != nil {
+		return nil, nil, newTTSError(ErrCodeInference, "创建length_scale张量失败", err)
+	}
 	defer lengthScaleTensor.Destroy()
 
 	inputs := []ort.Value{xTensor, xLengthsTensor, tonesTensor, sidTensor, bertTensor, jaBertTensor, sdpRatioTensor, noiseScaleTensor, noiseScaleWTensor, lengthScaleTensor}	
+	if m.SeedMode() == SeedModeNoiseInputs {
+		seed := newTTSSeed()
+		if params.Seed != nil {
+			seed = *params.Seed
+		}
+		noiseTensors, err := m.newNoiseTensors(seed, 1, mappedPhonesLen, speed)
+		if err != nil {
+			return nil, nil, newTTSError(ErrCodeInference, "创建噪声张量失败", err)
+		}
+		for _, tensor := range noiseTensors {
+			defer tensor.Destroy()
+		}
+		inputs = append(inputs, noiseTensors...)
+	}
 
 	outputs := []ort.Value{nil} // 会自动分配输出张量
+	if m.durationOutputName != "" {
+		outputs = append(outputs, nil)
+	}
 
 	// 计算推理耗时
 	startTime := time.Now()
@@ -410,7 +549,7 @@ func (m *XWX_TTS)Tts_pcm(text string, speakerid int, speed float32) []float32 {
 	duration := time.Since(startTime)
 	
 	if err != nil {
-		panic(err)
+		return nil, nil, newTTSError(ErrCodeInference, "TTS模型推理失败", err)
 	}
 	
 	fmt.Printf("\n=== 推理性能 ===")
@@ -418,115 +557,131 @@ func (m *XWX_TTS)Tts_pcm(text string, speakerid int, speed float32) []float32 {
 	fmt.Printf("推理速度: %.2f seconds\n", duration.Seconds())
 
 	// 清理自动分配的输出张量
-	defer outputs[0].Destroy()
-
-    // 打印输出张量的形状信息
-    //fmt.Println("\n=== 输出张量形状信息 ===")
-    //outputShape := outputs[0].GetShape()
-    //fmt.Printf("输出张量维度: %d\n", len(outputShape))
-    //fmt.Printf("输出形状: %v\n", outputShape)
-    //fmt.Printf("输出扁平化大小: %d\n", outputShape.FlattenedSize())
+	for _, output := range outputs {
+		defer output.Destroy()
+	}
 
 	duration = time.Since(startTime000)
 	fmt.Printf("TTS 处理耗时: %v\n", duration)
 
 	floatTensor, ok := outputs[0].(*ort.Tensor[float32])
     if !ok {
-        fmt.Errorf("无法转换为float32张量")
+        return nil, nil, newTTSError(ErrCodeInference, "无法转换为float32张量", nil)
     }
     
-    data := floatTensor.GetData()
+	// 输出张量随 defer 销毁，返回前拷贝一份
+	data := append([]float32(nil), floatTensor.GetData()...)
     //fmt.Printf("音频数据长度: %d 个采样点\n", len(data))
-	//fmt.Printf("音频数据示例: %v\n", data[:20])
 
-	return data
+	if m.durationOutputName == "" {
+		return data, nil, nil
+	}
+	durations, err := readDurationOutput(outputs[1], len(mappedPhones))
+	if err != nil {
+		// 时长只用于时间戳，读取失败不影响音频
+		fmt.Printf("读取时长输出失败: %v\n", err)
+		return data, nil, nil
+	}
+	return data, durations, nil
 }
 
-func (m *XWX_TTS)TtsTest(text string, wavOutPath string) {
+// SSML合成：按片段依次推理，<break> 插入静音，<prosody rate> 调整该片段的 length_scale
+// 同一片段内的文本与 <phoneme> 拼接为一个音素序列，超过 TTS_CHUNK_MAX_PHONES 时与 Tts_pcm 一样分块推理
+func (m *XWX_TTS)Tts_ssml(ssml string, lexicon *UserLexicon, speakerid int, speed float32, params *TTSInferenceParams) ([]float32, error) {
+	segments, err := ParseSSML(ssml, m.model.Frontend, speakerid)
+	if err != nil {
+		return nil, err
+	}
 
-	speaker_id := 0
-	speed := float32(1.2)
-	pcmData := m.Tts_pcm(text, speaker_id, speed)
+	sampleRate := m.SampleRate()
+	pcm := []float32{}
+	for _, segment := range segments {
+		if segment.Pause > 0 {
+			pcm = append(pcm, make([]float32, sampleRate*segment.Pause/1000)...)
+			continue
+		}
 
-	sampleRate := 24000
-	err := saveAsWAV(pcmData, wavOutPath , sampleRate)
-	
-	if err != nil {
-		fmt.Printf("写入WAV文件失败: %v", err)
+		mix_phones := []string{"_"}
+		mix_tones := []int{0}
+		mix_word2ph := []int{1}
+		texts := []string{}
+		for _, piece := range segment.Pieces {
+			phones, tones, word2ph, text, err := m.ssmlPiece_g2p(piece, lexicon)
+			if err != nil {
+				return nil, err
+			}
+			mix_phones = append(mix_phones, phones...)
+			mix_tones = append(mix_tones, tones...)
+			mix_word2ph = append(mix_word2ph, word2ph...)
+			texts = append(texts, text)
+		}
+		mix_phones = append(mix_phones, "_")
+		mix_tones = append(mix_tones, 0)
+		mix_word2ph = append(mix_word2ph, 1)
+
+		// 片段之间以空格拼接，BERT分词时空格不产生token，也避免英文单词粘连
+		chunks := m.splitChunks(mix_phones, mix_tones, mix_word2ph, strings.Join(texts, " "))
+		var audio []float32
+		if len(chunks) > 1 {
+			audio, err = m.synthesizeChunks(chunks, segment.SpeakerID, speed*segment.Rate, params)
+		} else {
+			audio, err = m.Tts_phones(chunks[0].phones, chunks[0].tones, chunks[0].word2ph, chunks[0].text, segment.SpeakerID, speed*segment.Rate, params)
+		}
+		if err != nil {
+			return nil, err
+		}
+		pcm = append(pcm, audio...)
 	}
+	return pcm, nil
 }
 
+// SSML片段g2p，返回的音素不带首尾 "_"
+func (m *XWX_TTS)ssmlPiece_g2p(piece SSMLPiece, lexicon *UserLexicon) ([]string, []int, []int, string, error) {
+	if piece.Alphabet == "" {
+		phones, tones, word2ph, text, err := m.G2p(piece.Text, lexicon)
+		if err != nil {
+			return nil, nil, nil, "", err
+		}
+		return phones[1:len(phones)-1], tones[1:len(tones)-1], word2ph[1:len(word2ph)-1], text, nil
+	}
 
-// saveAsWAV 将张量数据保存为WAV文件
-func saveAsWAV(pcmData []float32, filename string, sampleRate int) error {
-    
-    fmt.Printf("音频时长: %.2f 秒\n", float64(len(pcmData))/float64(sampleRate))
-    
-    // 创建WAV文件
-    file, err := os.Create(filename)
-    if err != nil {
-        return fmt.Errorf("创建文件失败: %w", err)
-    }
-    defer file.Close()
-    
-    // 写入WAV文件头
-    err = writeWAVHeader(file, len(pcmData), sampleRate)
-    if err != nil {
-        return fmt.Errorf("写入WAV头失败: %w", err)
-    }
-    
-    // 将float32数据转换为16位PCM并写入
-    for _, sample := range pcmData {
-        // 确保数据范围在 [-1, 1] 之间
-        if sample > 1.0 {
-            sample = 1.0
-        } else if sample < -1.0 {
-            sample = -1.0
-        }
-        
-        // 转换为16位PCM
-        pcmValue := int16(sample * 32767)
-        
-        // 写入小端格式
-        err = binary.Write(file, binary.LittleEndian, pcmValue)
-        if err != nil {
-            return fmt.Errorf("写入音频数据失败: %w", err)
-        }
-    }
-    
-    fmt.Printf("✓ WAV文件已保存: %s\n", filename)
-    return nil
+	phones, tones, counts, err := Phoneme_g2p(piece.Alphabet, piece.Phonemes)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	// 模型音素表中没有的音素会被 mapping_phones 丢弃，这里提前报错
+	for _, phone := range phones {
+		if _, ok := m.symbolIDMap[phone]; !ok {
+			return nil, nil, nil, "", newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("模型不支持音素 %q (phoneme: %s)", phone, piece.Phonemes), nil)
+		}
+	}
+
+	// 音节数与BERT token数一致时逐个对应，否则把音素均分到各个token上
+	tokenCount := len(m.bertExtractor.Tokenize(piece.Text))
+	if tokenCount == 0 {
+		return nil, nil, nil, "", newTTSError(ErrCodeInvalidRequest, "phoneme 标注的文本无法分词: "+piece.Text, nil)
+	}
+	word2ph := counts
+	if len(counts) != tokenCount {
+		word2ph = distributePhones(len(phones), tokenCount)
+	}
+	return phones, tones, word2ph, piece.Text, nil
 }
 
-// writeWAVHeader 写入WAV文件头
-func writeWAVHeader(file *os.File, numSamples int, sampleRate int) error {
-    // WAV文件参数
-    const (
-        bitsPerSample = 16
-        numChannels   = 1
-    )
-    
-    dataSize := uint32(numSamples * bitsPerSample / 8 * numChannels)
-    fileSize := dataSize + 36 // 文件大小 = 数据大小 + 头大小(44) - "RIFF"标识(4) - 文件大小字段(4)
-    
-    // 写入RIFF头
-    file.WriteString("RIFF")
-    binary.Write(file, binary.LittleEndian, uint32(fileSize))
-    file.WriteString("WAVE")
-    
-    // 写入fmt chunk
-    file.WriteString("fmt ")
-    binary.Write(file, binary.LittleEndian, uint32(16)) // chunk size
-    binary.Write(file, binary.LittleEndian, uint16(1))  // PCM格式
-    binary.Write(file, binary.LittleEndian, uint16(numChannels))
-    binary.Write(file, binary.LittleEndian, uint32(sampleRate))
-    binary.Write(file, binary.LittleEndian, uint32(sampleRate*bitsPerSample/8*numChannels)) // 字节率
-    binary.Write(file, binary.LittleEndian, uint16(bitsPerSample/8*numChannels)) // 块对齐
-    binary.Write(file, binary.LittleEndian, uint16(bitsPerSample))
-    
-    // 写入data chunk头
-    file.WriteString("data")
-    binary.Write(file, binary.LittleEndian, dataSize)
-    
-    return nil
+func (m *XWX_TTS)TtsTest(text string, wavOutPath string) {
+
+	speaker_id := 0
+	speed := float32(1.2)
+	pcmData, err := m.Tts_pcm(text, userLexicon, speaker_id, speed, nil)
+	if err != nil {
+		fmt.Printf("TTS合成失败: %v\n", err)
+		return
+	}
+
+	sampleRate := m.SampleRate()
+	err = saveAudioFile(pcmData, wavOutPath , sampleRate)
+	
+	if err != nil {
+		fmt.Printf("写入音频文件失败: %v", err)
+	}
 }
@@ -0,0 +1,434 @@
+package main
+
+// SSML 解析：把 <speak> 文档编译为按顺序合成的片段
+// 支持 <break>、<prosody rate>、<say-as>、<phoneme>、<sub>、<voice>，其余标签（p、s、emphasis 等）只取其中文本
+// 相同发音人、相同语速的连续文本与 <phoneme> 合并为一个片段，一次推理完成，保持韵律连贯
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SSML 中的一段文本或 <phoneme>
+type SSMLPiece struct {
+	Text     string // 文本；<phoneme> 时为被标注的原文，用于提取BERT特征
+	Alphabet string // pinyin / jyutping / arpabet，为空表示普通文本
+	Phonemes string // <phoneme> 的 ph 属性
+}
+
+// 编译后的合成片段，Pause > 0 时为静音片段
+type SSMLSegment struct {
+	Pieces    []SSMLPiece
+	Pause     int     // 静音毫秒数
+	SpeakerID int     // 发音人ID
+	Rate      float32 // 相对请求语速的倍数
+}
+
+// <break strength> 对应的静音毫秒数，不带属性的 <break/> 按 medium 处理
+var ssmlBreakStrengthMap = map[string]int{
+	"none":     0,
+	"x-weak":   100,
+	"weak":     250,
+	"medium":   500,
+	"strong":   750,
+	"x-strong": 1000,
+}
+
+// 单个 <break> 最长静音
+const ssmlMaxBreakMs = 10000
+
+// <prosody rate> 关键字对应的语速倍数
+var ssmlRateMap = map[string]float32{
+	"x-slow":  0.5,
+	"slow":    0.75,
+	"medium":  1.0,
+	"default": 1.0,
+	"fast":    1.25,
+	"x-fast":  1.5,
+}
+
+var (
+	reSSMLWhitespace = regexp.MustCompile(`\s+`)
+	reSSMLBreakTime  = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(ms|s)$`)
+	reSSMLDateFields = regexp.MustCompile(`\d+`)
+	reSSMLCardinal   = regexp.MustCompile(`^(-)?(\d+(?:\.\d+)?)$`)
+)
+
+// 解析时的元素上下文
+type ssmlFrame struct {
+	name      string
+	speakerID int
+	rate      float32
+
+	// say-as / sub / phoneme 收集内部文本后整体处理
+	capture bool
+	text    strings.Builder
+	attrs   map[string]string
+}
+
+// ParseSSML 解析SSML文档，speakerID 为 <voice> 之外使用的发音人
+func ParseSSML(doc string, language Language, speakerID int) ([]SSMLSegment, error) {
+	decoder := xml.NewDecoder(strings.NewReader(doc))
+	decoder.Strict = true
+
+	segments := []SSMLSegment{}
+	stack := []*ssmlFrame{{name: "", speakerID: speakerID, rate: 1.0}}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, newTTSError(ErrCodeInvalidRequest, "SSML格式错误", err)
+		}
+		top := stack[len(stack)-1]
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			frame := &ssmlFrame{name: t.Name.Local, speakerID: top.speakerID, rate: top.rate, attrs: map[string]string{}}
+			for _, attr := range t.Attr {
+				frame.attrs[attr.Name.Local] = attr.Value
+			}
+			// say-as 等内部的子元素只取文本
+			if top.capture {
+				frame.capture = true
+				stack = append(stack, frame)
+				continue
+			}
+
+			switch frame.name {
+			case "break":
+				pause, err := parseSSMLBreak(frame.attrs)
+				if err != nil {
+					return nil, err
+				}
+				if pause > 0 {
+					segments = append(segments, SSMLSegment{Pause: pause})
+				}
+			case "prosody":
+				if rateAttr, ok := frame.attrs["rate"]; ok {
+					rate, err := parseSSMLRate(rateAttr)
+					if err != nil {
+						return nil, err
+					}
+					frame.rate *= rate
+				}
+			case "voice":
+				name := frame.attrs["name"]
+				id, ok := parseOpenAIVoice(name)
+				if !ok {
+					return nil, newTTSError(ErrCodeInvalidRequest, "SSML不支持的发音人: "+name, nil)
+				}
+				frame.speakerID = id
+			case "say-as", "sub", "phoneme":
+				frame.capture = true
+			}
+			stack = append(stack, frame)
+
+		case xml.EndElement:
+			frame := top
+			stack = stack[:len(stack)-1]
+			parent := stack[len(stack)-1]
+			if parent.capture {
+				parent.text.WriteString(frame.text.String())
+				continue
+			}
+
+			switch frame.name {
+			case "say-as":
+				text := renderSSMLSayAs(frame.attrs["interpret-as"], frame.attrs["format"], frame.text.String(), language)
+				segments = appendSSMLPiece(segments, frame, SSMLPiece{Text: text})
+			case "sub":
+				alias, ok := frame.attrs["alias"]
+				if !ok {
+					alias = frame.text.String()
+				}
+				segments = appendSSMLPiece(segments, frame, SSMLPiece{Text: alias})
+			case "phoneme":
+				piece, err := newSSMLPhoneme(frame.attrs, frame.text.String(), language)
+				if err != nil {
+					return nil, err
+				}
+				segments = appendSSMLPiece(segments, frame, piece)
+			}
+
+		case xml.CharData:
+			if top.capture {
+				top.text.Write(t)
+				continue
+			}
+			segments = appendSSMLPiece(segments, top, SSMLPiece{Text: string(t)})
+		}
+	}
+	if len(stack) != 1 {
+		return nil, newTTSError(ErrCodeInvalidRequest, "SSML标签未闭合", nil)
+	}
+
+	// 去掉只含空白的文本
+	result := make([]SSMLSegment, 0, len(segments))
+	for _, segment := range segments {
+		if segment.Pause > 0 {
+			result = append(result, segment)
+			continue
+		}
+		pieces := make([]SSMLPiece, 0, len(segment.Pieces))
+		for _, piece := range segment.Pieces {
+			piece.Text = strings.TrimSpace(piece.Text)
+			if piece.Text == "" && piece.Alphabet == "" {
+				continue
+			}
+			pieces = append(pieces, piece)
+		}
+		if len(pieces) > 0 {
+			segment.Pieces = pieces
+			result = append(result, segment)
+		}
+	}
+	return result, nil
+}
+
+// 追加到最后一个片段，发音人或语速不同、或上一个是静音时新开片段
+func appendSSMLPiece(segments []SSMLSegment, frame *ssmlFrame, piece SSMLPiece) []SSMLSegment {
+	piece.Text = reSSMLWhitespace.ReplaceAllString(piece.Text, " ")
+	if piece.Text == "" && piece.Alphabet == "" {
+		return segments
+	}
+
+	if n := len(segments); n > 0 {
+		last := &segments[n-1]
+		if last.Pause == 0 && last.SpeakerID == frame.speakerID && last.Rate == frame.rate {
+			// 连续的普通文本直接拼接
+			if lastPiece := &last.Pieces[len(last.Pieces)-1]; lastPiece.Alphabet == "" && piece.Alphabet == "" {
+				lastPiece.Text += piece.Text
+			} else {
+				last.Pieces = append(last.Pieces, piece)
+			}
+			return segments
+		}
+	}
+	return append(segments, SSMLSegment{
+		Pieces:    []SSMLPiece{piece},
+		SpeakerID: frame.speakerID,
+		Rate:      frame.rate,
+	})
+}
+
+// 解析 <break time="500ms"> / <break strength="strong">
+func parseSSMLBreak(attrs map[string]string) (int, error) {
+	if timeAttr, ok := attrs["time"]; ok {
+		matches := reSSMLBreakTime.FindStringSubmatch(strings.TrimSpace(timeAttr))
+		if matches == nil {
+			return 0, newTTSError(ErrCodeInvalidRequest, "SSML break time 格式错误: "+timeAttr, nil)
+		}
+		value, _ := strconv.ParseFloat(matches[1], 64)
+		if matches[2] == "s" {
+			value *= 1000
+		}
+		if value > ssmlMaxBreakMs {
+			value = ssmlMaxBreakMs
+		}
+		return int(value), nil
+	}
+	strength, ok := attrs["strength"]
+	if !ok {
+		strength = "medium"
+	}
+	pause, ok := ssmlBreakStrengthMap[strength]
+	if !ok {
+		return 0, newTTSError(ErrCodeInvalidRequest, "SSML break strength 不支持: "+strength, nil)
+	}
+	return pause, nil
+}
+
+// 解析 <prosody rate>：关键字、百分比（"150%"、"+20%"）或倍数（"1.2"）
+func parseSSMLRate(rate string) (float32, error) {
+	rate = strings.TrimSpace(rate)
+	if value, ok := ssmlRateMap[rate]; ok {
+		return value, nil
+	}
+
+	var value float64
+	var err error
+	switch {
+	case strings.HasSuffix(rate, "%") && (strings.HasPrefix(rate, "+") || strings.HasPrefix(rate, "-")):
+		value, err = strconv.ParseFloat(strings.TrimSuffix(rate, "%"), 64)
+		value = 1 + value/100
+	case strings.HasSuffix(rate, "%"):
+		value, err = strconv.ParseFloat(strings.TrimSuffix(rate, "%"), 64)
+		value /= 100
+	default:
+		value, err = strconv.ParseFloat(rate, 64)
+	}
+	if err != nil || value <= 0 {
+		return 0, newTTSError(ErrCodeInvalidRequest, "SSML prosody rate 格式错误: "+rate, err)
+	}
+	return float32(value), nil
+}
+
+// 按 interpret-as 把 <say-as> 内容改写为可直接 g2p 的文本，不认识的类型原样返回
+func renderSSMLSayAs(interpretAs string, format string, text string, language Language) string {
+	text = strings.TrimSpace(text)
+	switch interpretAs {
+	case "characters", "spell-out":
+		// 逐字读：数字读单个汉字，字母之间加空格按单个字母发音
+		parts := []string{}
+		for _, r := range text {
+			switch {
+			case r >= '0' && r <= '9':
+				parts = append(parts, chineseDigits[r-'0'])
+			case unicode.IsSpace(r):
+				continue
+			default:
+				parts = append(parts, string(r))
+			}
+		}
+		return strings.Join(parts, " ")
+	case "cardinal", "number":
+		number := strings.ReplaceAll(text, ",", "")
+		matches := reSSMLCardinal.FindStringSubmatch(number)
+		if matches == nil {
+			return NormalizeChineseText(text, language)
+		}
+		result := readDecimal(matches[2], language)
+		if matches[1] != "" {
+			result = "负" + result
+		}
+		return result
+	case "telephone":
+		return readDigits(text, language, true)
+	case "date":
+		return renderSSMLDate(text, format, language)
+	}
+	return text
+}
+
+// 日期按 format（ymd、mdy、dmy、md、dm、ym、y）读，默认按字段数推断
+func renderSSMLDate(text string, format string, language Language) string {
+	fields := reSSMLDateFields.FindAllString(text, -1)
+	if format == "" {
+		switch {
+		case len(fields) == 3:
+			format = "ymd"
+		case len(fields) == 2 && len(fields[0]) == 4:
+			format = "ym"
+		case len(fields) == 2:
+			format = "md"
+		case len(fields) == 1 && len(fields[0]) == 4:
+			format = "y"
+		}
+	}
+	if len(format) != len(fields) || len(fields) == 0 {
+		return NormalizeChineseText(text, language)
+	}
+
+	year, month, day := "", "", ""
+	for i, field := range strings.Split(format, "") {
+		switch field {
+		case "y":
+			year = fields[i]
+		case "m":
+			month = fields[i]
+		case "d":
+			day = fields[i]
+		default:
+			return NormalizeChineseText(text, language)
+		}
+	}
+
+	result := ""
+	if year != "" {
+		result += readDigits(year, language, false) + "年"
+	}
+	if month != "" {
+		result += readCardinal(month, language, false) + "月"
+	}
+	if day != "" {
+		result += readCardinal(day, language, false) + "日"
+	}
+	return result
+}
+
+// 校验 <phoneme> 的 alphabet 是否适用于当前语言
+func newSSMLPhoneme(attrs map[string]string, text string, language Language) (SSMLPiece, error) {
+	alphabet := strings.ToLower(attrs["alphabet"])
+	ph := strings.TrimSpace(attrs["ph"])
+	if ph == "" {
+		return SSMLPiece{}, newTTSError(ErrCodeInvalidRequest, "SSML phoneme 缺少 ph 属性", nil)
+	}
+	text = strings.TrimSpace(reSSMLWhitespace.ReplaceAllString(text, " "))
+	if text == "" {
+		return SSMLPiece{}, newTTSError(ErrCodeInvalidRequest, "SSML phoneme 需要包含被标注的文本: "+ph, nil)
+	}
+
+	switch {
+	case alphabet == "arpabet":
+	case alphabet == "pinyin" && language == ZH_X:
+	case alphabet == "jyutping" && language == YUE_EN:
+	default:
+		return SSMLPiece{}, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("语言 %s 不支持 phoneme alphabet: %q", language, alphabet), nil)
+	}
+	return SSMLPiece{Text: text, Alphabet: alphabet, Phonemes: ph}, nil
+}
+
+// Phoneme_g2p 把 <phoneme> 的 ph 转为音素与声调，第三个返回值为每个音节（arpabet 为整个单词）的音素数
+func Phoneme_g2p(alphabet string, ph string) ([]string, []int, []int, error) {
+	phones := []string{}
+	tones := []int{}
+	counts := []int{}
+
+	switch alphabet {
+	case "pinyin":
+		for _, py := range strings.Fields(ph) {
+			syllable, err := ParsePinyinSyllable(py)
+			if err != nil {
+				return nil, nil, nil, newTTSError(ErrCodeInvalidRequest, "无法解析拼音: "+py, err)
+			}
+			count := 0
+			if syllable.Initial != "" {
+				phones = append(phones, syllable.Initial)
+				tones = append(tones, syllable.Tone)
+				count++
+			}
+			phones = append(phones, syllable.Final)
+			tones = append(tones, syllable.Tone)
+			counts = append(counts, count+1)
+		}
+	case "jyutping":
+		syllables, err := ParseJyutpingSyllables(ph)
+		if err != nil {
+			return nil, nil, nil, newTTSError(ErrCodeInvalidRequest, "无法解析粤拼: "+ph, err)
+		}
+		for _, syllable := range syllables {
+			tone, _ := strconv.Atoi(syllable.Tone)
+			count := 0
+			for _, phone := range []string{syllable.Initial, syllable.Nucleus, syllable.Coda} {
+				if phone != "" {
+					phones = append(phones, phone)
+					tones = append(tones, tone)
+					count++
+				}
+			}
+			counts = append(counts, count)
+		}
+	case "arpabet":
+		for _, phoneTone := range strings.Fields(ph) {
+			phone, tone := split_phone_tone(phoneTone)
+			phones = append(phones, phone)
+			tones = append(tones, tone)
+		}
+		counts = append(counts, len(phones))
+	default:
+		return nil, nil, nil, newTTSError(ErrCodeInvalidRequest, "不支持的 phoneme alphabet: "+alphabet, nil)
+	}
+
+	if len(phones) == 0 {
+		return nil, nil, nil, newTTSError(ErrCodeInvalidRequest, "phoneme ph 为空", nil)
+	}
+	return phones, tones, counts, nil
+}
@@ -0,0 +1,439 @@
+package main
+
+// 中文文本正则化（TN）：在 SplitText 之前把数字、日期、时间、金额、单位等改写为汉字读法
+// 规则按顺序执行，先处理格式明确的（日期、电话），最后处理普通数字
+// 普通话与粤语的读法差异由 language 区分；粤语输出仍为简体，后续统一转为香港繁体
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var chineseDigits = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+
+// 数字后接这些量词时 2 读作"两"
+const liangMeasureWords = "个只本次件条张把双对位名台辆天年周岁层种份块片支首座间家套斤米吨倍项门颗粒场篇句段群批部所节棵头匹口扇架艘幅盏封笔根轮遍趟辈样"
+
+// 计量单位读法，key 区分大小写
+var measureUnitMap = map[string]string{
+	"km": "千米", "m": "米", "dm": "分米", "cm": "厘米", "mm": "毫米", "nm": "纳米",
+	"kg": "千克", "g": "克", "mg": "毫克", "t": "吨",
+	"L": "升", "l": "升", "ml": "毫升", "mL": "毫升",
+	"km/h": "千米每小时", "m/s": "米每秒",
+	"h": "小时", "min": "分钟", "s": "秒", "ms": "毫秒",
+	"Hz": "赫兹", "kHz": "千赫兹", "MHz": "兆赫兹", "GHz": "吉赫兹",
+	"W": "瓦", "kW": "千瓦", "V": "伏", "mAh": "毫安时", "kWh": "千瓦时",
+	"m²": "平方米", "m2": "平方米", "km²": "平方千米", "m³": "立方米", "m3": "立方米",
+	"℃": "摄氏度", "°C": "摄氏度", "°F": "华氏度", "°": "度",
+}
+
+// 货币符号读法
+var currencySymbolMap = map[string]string{
+	"¥": "元", "￥": "元", "$": "美元", "€": "欧元", "£": "英镑",
+}
+
+var (
+	reFullWidthDigit = regexp.MustCompile(`[０-９]`)
+	reThousandsSep   = regexp.MustCompile(`\d{1,3}(?:,\d{3})+(?:\.\d+)?`)
+
+	// 2024年10月18日 / 2024-10-18 / 2024/10/18
+	reFullDate  = regexp.MustCompile(`(\d{4})年(\d{1,2})月(\d{1,2})([日号])`)
+	reDashDate  = regexp.MustCompile(`(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})`)
+	reYear      = regexp.MustCompile(`(\d{4})年`)
+	reMonthDay  = regexp.MustCompile(`(\d{1,2})月(\d{1,2})([日号])`)
+	reMonthOnly = regexp.MustCompile(`(\d{1,2})月`)
+
+	// 10/18号，月/日
+	reSlashMonthDay = regexp.MustCompile(`(\d{1,2})/(\d{1,2})([日号])`)
+
+	// 12:30 / 08:05:09
+	reTime = regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?`)
+
+	// 比分、比例 3:2，不是时间的冒号读作"比"
+	reRatio = regexp.MustCompile(`(\d+):(\d+)`)
+
+	// 手机号、座机、身份证号，逐位读
+	reMobile   = regexp.MustCompile(`(?:\+86[- ]?)?1[3-9]\d{9}`)
+	reLandline = regexp.MustCompile(`0\d{2,3}-\d{7,8}`)
+	reIDNumber = regexp.MustCompile(`\d{17}[\dXx]`)
+
+	// ¥99.9 / $5
+	reCurrency = regexp.MustCompile(`([¥￥$€£])\s?(\d+(?:\.\d+)?)`)
+
+	// 50% / 3.5‰
+	rePercent = regexp.MustCompile(`(-?\d+(?:\.\d+)?)\s?([%％‰])`)
+
+	// 3/4
+	reFraction = regexp.MustCompile(`(\d+)/(\d+)`)
+
+	// 10-20 / 10~20km
+	reRange = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?[-~～—至]\s?(\d+(?:\.\d+)?)([A-Za-z°℃℉²³/]*)`)
+
+	// 10km / 36.5℃ / -5℃
+	reUnit = regexp.MustCompile(`(-?)(\d+(?:\.\d+)?)\s?([A-Za-z°℃℉²³/]+)`)
+
+	// 第2名
+	reOrdinal = regexp.MustCompile(`第(\d+)`)
+
+	// -5 / 3.14 / 42
+	reNumber = regexp.MustCompile(`(-)?(\d+)(?:\.(\d+))?`)
+)
+
+// NormalizeChineseText 中文文本正则化，language 决定普通话或粤语的读法
+func NormalizeChineseText(text string, language Language) string {
+	// 全角数字转半角
+	text = reFullWidthDigit.ReplaceAllStringFunc(text, func(s string) string {
+		r := []rune(s)[0]
+		return string('0' + (r - '０'))
+	})
+	// 英文语境中的数字按英文读
+	text = normalizeEnglishNumbers(text)
+	// 去掉千分位逗号
+	text = reThousandsSep.ReplaceAllStringFunc(text, func(s string) string {
+		return strings.ReplaceAll(s, ",", "")
+	})
+
+	text = replaceSubmatch(reFullDate, text, func(m []string) string {
+		return readDigits(m[1], language, false) + "年" + readCardinal(m[2], language, false) + "月" +
+			readCardinal(m[3], language, false) + m[4]
+	})
+	text = replaceSubmatch(reDashDate, text, func(m []string) string {
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return m[0]
+		}
+		return readDigits(m[1], language, false) + "年" + readCardinal(m[2], language, false) + "月" +
+			readCardinal(m[3], language, false) + "日"
+	})
+	text = replaceSubmatch(reYear, text, func(m []string) string {
+		return readDigits(m[1], language, false) + "年"
+	})
+	text = replaceSubmatch(reMonthDay, text, func(m []string) string {
+		return readCardinal(m[1], language, false) + "月" + readCardinal(m[2], language, false) + m[3]
+	})
+	text = replaceSubmatch(reMonthOnly, text, func(m []string) string {
+		return readCardinal(m[1], language, false) + "月"
+	})
+	// 先于分数处理，避免 10/18号 读作十八分之十
+	text = replaceDigitString(reSlashMonthDay, text, func(m []string) string {
+		month, _ := strconv.Atoi(m[1])
+		day, _ := strconv.Atoi(m[2])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return m[0]
+		}
+		return readCardinal(m[1], language, false) + "月" + readCardinal(m[2], language, false) + m[3]
+	})
+
+	text = replaceSubmatch(reTime, text, func(m []string) string {
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour > 24 || minute > 59 {
+			return m[0]
+		}
+		result := readCardinal(m[1], language, false) + "点"
+		switch {
+		case minute == 0 && m[3] == "":
+			if language == ZH_X {
+				result += "整"
+			}
+		case minute == 30 && m[3] == "" && language == YUE_EN:
+			// 粤语习惯说 "X点半"
+			result += "半"
+		default:
+			if minute < 10 {
+				result += "零"
+			}
+			result += readCardinal(strconv.Itoa(minute), language, false) + "分"
+		}
+		if m[3] != "" {
+			result += readCardinal(m[3], language, false) + "秒"
+		}
+		return result
+	})
+	// 英文语境中的冒号留给英文g2p
+	text = replaceSubmatchWhere(reRatio, text, func(start int, end int) bool {
+		return !isEnglishContext(text, start, end)
+	}, func(m []string) string {
+		return readCardinal(m[1], language, false) + "比" + readCardinal(m[2], language, false)
+	})
+
+	// 身份证号、座机先于手机号处理，避免身份证号中间的 11 位被当作手机号
+	text = replaceDigitString(reIDNumber, text, func(m []string) string {
+		result := readDigits(strings.TrimRight(m[0], "Xx"), language, true)
+		if strings.HasSuffix(strings.ToUpper(m[0]), "X") {
+			result += "叉"
+		}
+		return result
+	})
+	text = replaceDigitString(reLandline, text, func(m []string) string {
+		return readDigits(strings.ReplaceAll(m[0], "-", ""), language, true)
+	})
+	text = replaceDigitString(reMobile, text, func(m []string) string {
+		return readDigits(strings.TrimLeft(m[0], "+86- "), language, true)
+	})
+
+	text = replaceSubmatch(reCurrency, text, func(m []string) string {
+		return readDecimal(m[2], language) + currencySymbolMap[m[1]]
+	})
+	text = replaceSubmatch(rePercent, text, func(m []string) string {
+		prefix := "百分之"
+		if m[2] == "‰" {
+			prefix = "千分之"
+		}
+		number := strings.TrimPrefix(m[1], "-")
+		result := prefix + readDecimal(number, language)
+		if strings.HasPrefix(m[1], "-") {
+			result = "负" + result
+		}
+		return result
+	})
+	text = replaceSubmatch(reFraction, text, func(m []string) string {
+		return readCardinal(m[2], language, false) + "分之" + readCardinal(m[1], language, false)
+	})
+	// 前面紧接字母数字串时连字符不是范围，如 x86-64；只检查匹配之前，匹配本身可含单位字母
+	text = replaceSubmatchWhere(reRange, text, func(start int, end int) bool {
+		return !inEnglishWordRun(text[:start], start, start)
+	}, func(m []string) string {
+		unit, ok := measureUnitMap[m[3]]
+		if m[3] != "" && !ok {
+			return m[0]
+		}
+		return readDecimal(m[1], language) + "到" + readDecimal(m[2], language) + unit
+	})
+	// 英文语境中的数字加单位已由 normalizeEnglishNumbers 处理，剩下的（如 5x）保留原样，不按中文单位读
+	text = replaceUnits(text, language)
+	text = replaceSubmatch(reOrdinal, text, func(m []string) string {
+		return "第" + readCardinal(m[1], language, false)
+	})
+
+	// 剩余的普通数字
+	var builder strings.Builder
+	last := 0
+	for _, loc := range reNumber.FindAllStringSubmatchIndex(text, -1) {
+		builder.WriteString(text[last:loc[0]])
+		last = loc[1]
+
+		// 含字母的字母数字串中的数字保留原样，由英文g2p读，如 MP3、4K、COVID-19、10-20xyz
+		if inEnglishWordRun(text, loc[0], loc[1]) {
+			builder.WriteString(text[loc[0]:loc[1]])
+			continue
+		}
+
+		// 负号前是字母或数字时为连字符，如 COVID-19
+		negative := loc[2] >= 0
+		if negative && loc[0] > 0 && isASCIIAlnum(text[loc[0]-1]) {
+			builder.WriteString("-")
+			negative = false
+		}
+
+		integer := text[loc[4]:loc[5]]
+		var reading string
+		switch {
+		case loc[6] >= 0:
+			reading = readDecimal(integer+"."+text[loc[6]:loc[7]], language)
+		case len(integer) > 1 && integer[0] == '0', len(integer) > 16:
+			// 0开头或过长的数字串（编号、卡号）逐位读
+			reading = readDigits(integer, language, true)
+		default:
+			// 2 后接量词读"两"，如 2个、2年
+			nextRune, _ := utf8.DecodeRuneInString(text[loc[1]:])
+			reading = readCardinal(integer, language, strings.ContainsRune(liangMeasureWords, nextRune))
+		}
+		if negative {
+			reading = "负" + reading
+		}
+		builder.WriteString(reading)
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// 数字加计量单位，如 10km、36.5℃、-5℃
+func replaceUnits(text string, language Language) string {
+	var builder strings.Builder
+	last := 0
+	for _, loc := range reUnit.FindAllStringSubmatchIndex(text, -1) {
+		unit, ok := measureUnitMap[text[loc[6]:loc[7]]]
+		// 英文语境中的数字加单位已由 normalizeEnglishNumbers 处理，剩下的（如 5x）保留原样，不按中文单位读
+		if !ok || isEnglishContext(text, loc[4], loc[1]) {
+			continue
+		}
+		builder.WriteString(text[last:loc[0]])
+		last = loc[1]
+
+		// 负号前是字母或数字时为连字符，如 A-5kg
+		if loc[3] > loc[2] {
+			if loc[2] > 0 && isASCIIAlnum(text[loc[2]-1]) {
+				builder.WriteString("-")
+			} else {
+				builder.WriteString("负")
+			}
+		}
+		builder.WriteString(readDecimal(text[loc[4]:loc[5]], language) + unit)
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// text[start:end] 所在的字母数字串（可由连字符、撇号连接，与 SplitText 的英文单词一致）是否含字母
+func inEnglishWordRun(text string, start int, end int) bool {
+	isWordByte := func(c byte) bool {
+		return isASCIIAlnum(c) || c == '-' || c == '\''
+	}
+	for i := start - 1; i >= 0 && isWordByte(text[i]); i-- {
+		if isASCIILetter(text[i]) {
+			return true
+		}
+	}
+	for i := end; i < len(text) && isWordByte(text[i]); i++ {
+		if isASCIILetter(text[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCIIAlnum(c byte) bool {
+	return (c >= '0' && c <= '9') || isASCIILetter(c)
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// 对每个匹配调用 fn，fn 收到 FindStringSubmatch 的结果
+func replaceSubmatch(re *regexp.Regexp, text string, fn func([]string) string) string {
+	return re.ReplaceAllStringFunc(text, func(s string) string {
+		return fn(re.FindStringSubmatch(s))
+	})
+}
+
+// 与 replaceSubmatch 相同，但前后紧接数字的匹配不替换（Go 正则不支持环视），
+// 用于手机号、座机、身份证号等定长数字串，避免从更长的数字串中间截取
+func replaceDigitString(re *regexp.Regexp, text string, fn func([]string) string) string {
+	return replaceSubmatchWhere(re, text, func(start int, end int) bool {
+		return (start == 0 || !isASCIIDigit(text[start-1])) && (end == len(text) || !isASCIIDigit(text[end]))
+	}, fn)
+}
+
+// 只替换 keep 返回 true 的匹配，keep 接收匹配在 text 中的位置
+func replaceSubmatchWhere(re *regexp.Regexp, text string, keep func(int, int) bool, fn func([]string) string) string {
+	var builder strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if !keep(start, end) {
+			continue
+		}
+		m := make([]string, len(loc)/2)
+		for i := range m {
+			if loc[2*i] >= 0 {
+				m[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		builder.WriteString(text[last:start])
+		builder.WriteString(fn(m))
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// readDigits 逐位读数字；phone 为 true 时普通话把 1 读作"幺"
+func readDigits(digits string, language Language, phone bool) string {
+	var builder strings.Builder
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			continue
+		}
+		if r == '1' && phone && language == ZH_X {
+			builder.WriteString("幺")
+			continue
+		}
+		builder.WriteString(chineseDigits[r-'0'])
+	}
+	return builder.String()
+}
+
+// readDecimal 读小数，整数部分按数值读，小数部分逐位读
+func readDecimal(number string, language Language) string {
+	integer, fraction, hasPoint := strings.Cut(number, ".")
+	result := readCardinal(integer, language, false)
+	if hasPoint && fraction != "" {
+		result += "点" + readDigits(fraction, language, false)
+	}
+	return result
+}
+
+// readCardinal 按数值读整数，最多16位；liang 为 true 时单独的 2 读作"两"
+// 普通话和粤语在 2 后接百、千、万、亿 时都读"两"
+func readCardinal(digits string, language Language, liang bool) string {
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return "零"
+	}
+	if len(digits) > 16 {
+		return readDigits(digits, language, false)
+	}
+	num, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return readDigits(digits, language, false)
+	}
+	if num == 2 && liang {
+		return "两"
+	}
+
+	sectionUnits := []string{"", "万", "亿", "万亿"}
+	result := ""
+	needZero := false
+	for pos := 0; num > 0; pos++ {
+		section := num % 10000
+		if needZero {
+			result = "零" + result
+		}
+		sectionText := readSection(section)
+		if section != 0 {
+			sectionText += sectionUnits[pos]
+		}
+		result = sectionText + result
+		needZero = section > 0 && section < 1000
+		num /= 10000
+	}
+
+	// 一十X 读作 十X
+	if strings.HasPrefix(result, "一十") {
+		result = strings.TrimPrefix(result, "一")
+	}
+	// 开头的 二百/二千/二万/二亿 读作 两
+	for _, unit := range []string{"百", "千", "万", "亿"} {
+		if strings.HasPrefix(result, "二"+unit) {
+			result = "两" + strings.TrimPrefix(result, "二")
+			break
+		}
+	}
+	return result
+}
+
+// readSection 读 0~9999 的一节
+func readSection(section uint64) string {
+	units := []string{"", "十", "百", "千"}
+	result := ""
+	zero := true
+	for pos := 0; section > 0; pos++ {
+		digit := section % 10
+		if digit == 0 {
+			if !zero {
+				zero = true
+				result = "零" + result
+			}
+		} else {
+			zero = false
+			result = chineseDigits[digit] + units[pos] + result
+		}
+		section /= 10
+	}
+	return result
+}
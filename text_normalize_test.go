@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestNormalizeChineseText(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"温度-5℃", "温度负五摄氏度"},
+		{"温度-3.5°C左右", "温度负三点五摄氏度左右"},
+		{"36.5℃", "三十六点五摄氏度"},
+		{"跑了10-20km", "跑了十到二十千米"},
+		{"10/18号开会", "十月十八号开会"},
+		{"3/4的人", "四分之三的人"},
+		{"比赛3:2结束", "比赛三比二结束"},
+		{"10:30开会", "十点三十分开会"},
+		{"2024/10/18", "二零二四年十月十八日"},
+		{"手机13812345678", "手机幺三八幺二三四五六七八"},
+		{"身份证110101199003071234", "身份证幺幺零幺零幺幺九九零零三零七幺二三四"},
+		{"电话010-12345678", "电话零幺零幺二三四五六七八"},
+		{"x86-64架构", "x86-64架构"},
+		{"我有2个", "我有两个"},
+	}
+	for _, c := range cases {
+		if got := NormalizeChineseText(c.input, ZH_X); got != c.want {
+			t.Errorf("NormalizeChineseText(%q) = %q, want %q", c.input, got, c.want)
+		}
+	}
+}
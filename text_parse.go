@@ -3,6 +3,7 @@ package main
 import (
 	//"fmt"
 	"regexp"
+	"strings"
 	"unicode"
 )
 
@@ -14,6 +15,12 @@ var (
 	
 	// 标点符号快速查找表
 	punctMap = make(map[rune]struct{})
+
+	// 句末标点，流式合成时在这些位置切句
+	sentenceEndMap = make(map[rune]struct{})
+
+	// 紧跟在句末标点后的闭合符号，归入前一句
+	closingPunctMap = make(map[rune]struct{})
 )
 
 const (
@@ -30,6 +37,12 @@ func init() {
 	for _, r := range allPunctuation {
 		punctMap[r] = struct{}{}
 	}
+	for _, r := range "。！？；…!?;\n" {
+		sentenceEndMap[r] = struct{}{}
+	}
+	for _, r := range `"'”’」』》）)]】` {
+		closingPunctMap[r] = struct{}{}
+	}
 }
 
 type TextSegment struct {
@@ -68,12 +81,18 @@ func SplitText(input string) []TextSegment {
 		return nil
 	}
 
+	types := make([]string, len(runes))
+	for i, r := range runes {
+		types[i] = getCharType(r)
+	}
+	markEnglishWordRunes(runes, types)
+
 	var segments []TextSegment
 	currentStart := 0
-	currentType := getCharType(runes[0])
+	currentType := types[0]
 
 	for i := 1; i < len(runes); i++ {
-		t := getCharType(runes[i])
+		t := types[i]
 		if t != currentType {
 			// 记录当前片段
 			segments = append(segments, TextSegment{
@@ -93,3 +112,159 @@ func SplitText(input string) []TextSegment {
 
 	return segments
 }
+
+// 含字母的单词中，与字母相连的数字（MP3、4K）以及夹在字母数字之间的连字符、撇号（COVID-19、don't）归为英文，
+// 整个单词交给英文g2p按缩写、复合词处理
+func markEnglishWordRunes(runes []rune, types []string) {
+	isWordRune := func(r rune) bool {
+		return r < 0x80 && (isASCIIAlnum(byte(r)) || r == '-' || r == '\'')
+	}
+	isAlnum := func(r rune) bool {
+		return r < 0x80 && isASCIIAlnum(byte(r))
+	}
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		hasLetter := false
+		for end < len(runes) && isWordRune(runes[end]) {
+			hasLetter = hasLetter || types[end] == TypeEnglish
+			end++
+		}
+		if hasLetter {
+			for i := start; i < end; i++ {
+				switch {
+				case isAlnum(runes[i]):
+					types[i] = TypeEnglish
+				case i > start && i+1 < end && isAlnum(runes[i-1]) && isAlnum(runes[i+1]):
+					types[i] = TypeEnglish
+				}
+			}
+		}
+		start = end
+	}
+}
+
+// isSentenceEnd 判断 runes[i] 是否为句子结束位置
+// 英文句点只有后面跟空白或位于末尾时才算句末，避免切开 3.14 这类数字
+func isSentenceEnd(runes []rune, i int) bool {
+	r := runes[i]
+	if _, ok := sentenceEndMap[r]; ok {
+		return true
+	}
+	if r == '.' {
+		return i+1 >= len(runes) || unicode.IsSpace(runes[i+1])
+	}
+	return false
+}
+
+// sentenceEnds 返回每个句子的结束位置（不含），连续的句末标点
+// （如"？！"）以及其后的闭合引号、括号归入同一句
+func sentenceEnds(runes []rune) []int {
+	var ends []int
+	for i := 0; i < len(runes); i++ {
+		if !isSentenceEnd(runes, i) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) {
+			if isSentenceEnd(runes, end) {
+				end++
+				continue
+			}
+			if _, ok := closingPunctMap[runes[end]]; ok && !isOpeningASCIIQuote(runes, end) {
+				end++
+				continue
+			}
+			break
+		}
+		ends = append(ends, end)
+		i = end - 1
+	}
+	return ends
+}
+
+// ASCII 引号不分左右，句末标点后的引号是否为下一句的开引号：
+// 双引号按之前出现的次数判断，奇数次说明有未闭合的引号，这是闭引号；单引号后紧接英文字母时是开引号（或撇号）
+func isOpeningASCIIQuote(runes []rune, i int) bool {
+	switch runes[i] {
+	case '"':
+		count := 0
+		for _, r := range runes[:i] {
+			if r == '"' {
+				count++
+			}
+		}
+		return count%2 == 0
+	case '\'':
+		return i+1 < len(runes) && runes[i+1] < 0x80 && isASCIILetter(byte(runes[i+1]))
+	}
+	return false
+}
+
+// SplitSentences 按句末标点把文本切分为句子，标点保留在句尾
+func SplitSentences(input string) []string {
+	runes := []rune(input)
+	sentences, rest := splitByEnds(runes, len(runes))
+	if rest = strings.TrimSpace(rest); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// SplitCompleteSentences 用于增量输入：只切出已经完整的句子，未结束的尾部作为 rest 原样返回
+// 句末标点必须后面还有字符才算完整，防止 "3." 之后才到达 "14" 被误切
+// 尾部超过 maxRunes 时提前切分，控制首包延迟与缓冲区大小，返回的 rest 不超过 maxRunes；maxRunes<=0 不限制
+func SplitCompleteSentences(input string, maxRunes int) ([]string, string) {
+	runes := []rune(input)
+	sentences, rest := splitByEnds(runes, len(runes)-1)
+
+	tail := []rune(rest)
+	for maxRunes > 0 && len(tail) > maxRunes {
+		cut := forcedSentenceCut(tail, maxRunes)
+		if sentence := strings.TrimSpace(string(tail[:cut])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		tail = tail[cut:]
+	}
+
+	return sentences, string(tail)
+}
+
+// forcedSentenceCut 在前 maxRunes 个字符中找提前切分的位置：优先在最后一个分句标点之后
+// （中文，、；：，英文 ,;: 须后接空白，避免切开 1,000、10:30），其次在最后一个空白处，都没有时硬切在 maxRunes 处
+// 连字符、撇号不作为切分点，避免切开 well-known、don't 这类单词
+func forcedSentenceCut(tail []rune, maxRunes int) int {
+	for i := maxRunes - 1; i > 0; i-- {
+		switch r := tail[i]; {
+		case strings.ContainsRune("，、；：", r):
+			return i + 1
+		case strings.ContainsRune(",;:", r) && i+1 < len(tail) && unicode.IsSpace(tail[i+1]):
+			return i + 1
+		}
+	}
+	for i := maxRunes - 1; i > 0; i-- {
+		if unicode.IsSpace(tail[i]) {
+			return i + 1
+		}
+	}
+	return maxRunes
+}
+
+// splitByEnds 在不超过 limit 的句末位置切句，返回句子和剩余文本
+func splitByEnds(runes []rune, limit int) ([]string, string) {
+	var sentences []string
+	start := 0
+	for _, end := range sentenceEnds(runes) {
+		if end > limit {
+			break
+		}
+		if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = end
+	}
+	return sentences, string(runes[start:])
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	ort "github.com/yalue/onnxruntime_go"
+	"golang.org/x/text/unicode/norm"
+)
+
+// 音素、字词级时间戳，用于字幕、口型同步、卡拉OK高亮
+// 模型导出了时长（w_ceil 等，形状 [1,1,T_x]）或对齐矩阵（attn，形状 [1,1,T_y,T_x]）时按模型结果计算，
+// 否则按音素个数均分音频时长估算，Source 字段区分两种来源
+
+// 可识别的时长输出名，导出ONNX时把 VITS 的 w_ceil 或 attn 加入输出即可
+var ttsDurationOutputNames = map[string]struct{}{
+	"w_ceil":    {},
+	"durations": {},
+	"duration":  {},
+	"attn":      {},
+}
+
+const (
+	AlignmentSourceModel     = "model"     // 来自模型时长输出
+	AlignmentSourceEstimated = "estimated" // 按音素均分估算
+)
+
+// 单个音素的起止时间，单位秒
+type TTSPhoneTiming struct {
+	Phone string  `json:"phone"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// 单个字或词的起止时间，单位秒；中文按字，英文按单词，Word 为输入文本中的原文（如 "Hello"、"100"、"I'm"）
+type TTSWordTiming struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// 合成结果的时间对齐
+type TTSAlignment struct {
+	Source string           `json:"source"`
+	Phones []TTSPhoneTiming `json:"phones"`
+	Words  []TTSWordTiming  `json:"words"`
+}
+
+// 合成并计算时间戳，返回的音频与 Tts_pcm 相同
+// 长文本与 Tts_pcm 一样分块推理，各块的时间戳按块在拼接结果中的位置平移
+func (m *XWX_TTS) Tts_pcm_timestamps(text string, lexicon *UserLexicon, speakerid int, speed float32, params *TTSInferenceParams) ([]float32, *TTSAlignment, error) {
+	chunks, err := m.planChunks(text, lexicon)
+	if err != nil {
+		return nil, nil, err
+	}
+	sampleRate := m.SampleRate()
+	audios := make([][]float32, len(chunks))
+	alignments := make([]*TTSAlignment, len(chunks))
+	for i, chunk := range chunks {
+		data, durations, err := m.tts_phones_durations(chunk.phones, chunk.tones, chunk.word2ph, chunk.text, speakerid, speed, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		tokens := m.bertExtractor.Tokenize(chunk.text)
+		audios[i] = data
+		alignments[i] = buildTTSAlignment(chunk.phones, chunk.word2ph, tokens, durations, float64(len(data))/float64(sampleRate))
+	}
+	if len(chunks) == 1 {
+		alignments[0].Words = m.mapWordsToInput(text, alignments[0].Words)
+		return audios[0], alignments[0], nil
+	}
+
+	// 与 synthesizeChunks 一样去掉各块首尾静音后拼接
+	trimStarts := make([]int, len(chunks))
+	for i, audio := range audios {
+		start, end := chunkSpeechBounds(audio, sampleRate)
+		audios[i] = audio[start:end]
+		trimStarts[i] = start
+	}
+	data, offsets := joinTTSChunksWithOffsets(audios, chunks, sampleRate)
+
+	alignment := &TTSAlignment{
+		Source: AlignmentSourceModel,
+		Phones: []TTSPhoneTiming{},
+		Words:  []TTSWordTiming{},
+	}
+	for i, chunkAlignment := range alignments {
+		if chunkAlignment.Source == AlignmentSourceEstimated {
+			alignment.Source = AlignmentSourceEstimated
+		}
+		// 块内时间以去静音前的音频为准，减去切掉的开头再加上块在拼接结果中的起点，并限制在块的音频范围内
+		shift := float64(offsets[i]-trimStarts[i]) / float64(sampleRate)
+		lower := float64(offsets[i]) / float64(sampleRate)
+		upper := float64(offsets[i]+len(audios[i])) / float64(sampleRate)
+		place := func(seconds float64) float64 {
+			return roundSeconds(math.Min(math.Max(seconds+shift, lower), upper))
+		}
+		for _, phone := range chunkAlignment.Phones {
+			alignment.Phones = append(alignment.Phones, TTSPhoneTiming{Phone: phone.Phone, Start: place(phone.Start), End: place(phone.End)})
+		}
+		for _, word := range chunkAlignment.Words {
+			alignment.Words = append(alignment.Words, TTSWordTiming{Word: word.Word, Start: place(word.Start), End: place(word.End)})
+		}
+	}
+	alignment.Words = m.mapWordsToInput(text, alignment.Words)
+	return data, alignment, nil
+}
+
+// 找不到的 token 之后最多跳过的输入字数，足够覆盖身份证号、电话号码等正则化改写的数字串
+const ttsAlignmentMaxSkip = 48
+
+// 把按 BERT token 得到的时间戳映射回输入文本中的字词
+// token 是正则化、小写并去掉重音后的文本（"100" → "一百"，"Hello" → "hello"），按顺序在输入中查找：
+// 能找到的 token 对应找到的位置；找不到的是正则化改写的结果，对应前后两个找到的 token 之间的输入
+// 粤语前端把文本转为香港繁体后才分词，查找时输入也先转换（字数不变时）
+func (m *XWX_TTS) mapWordsToInput(input string, tokens []TTSWordTiming) []TTSWordTiming {
+	reference := input
+	if m.model.Frontend == FrontendCantonese && s2hk != nil {
+		if converted, err := s2hk.Convert(input); err == nil && utf8.RuneCountInString(converted) == utf8.RuneCountInString(input) {
+			reference = converted
+		}
+	}
+	return mapTimingsToInput([]rune(input), foldAlignmentRunes(reference), tokens)
+}
+
+// inputRunes 为原文，refRunes 为与之逐字对应、用于查找的文本
+func mapTimingsToInput(inputRunes []rune, refRunes []rune, tokens []TTSWordTiming) []TTSWordTiming {
+	// 每个 token 对应的输入区间 [start, end)（字下标）
+	type tokenSpan struct {
+		timing     TTSWordTiming
+		start, end int
+	}
+	var spans []tokenSpan
+	var pending []TTSWordTiming
+	lastStart, lastEnd := 0, 0
+	flush := func(start int, end int) {
+		if start >= end {
+			start, end = lastStart, lastEnd // 没有对应的输入时归入前一个 token
+		}
+		for _, timing := range pending {
+			spans = append(spans, tokenSpan{timing, start, end})
+		}
+		pending = nil
+	}
+
+	pos := 0
+	for _, token := range tokens {
+		tokenRunes := foldAlignmentRunes(token.Word)
+		if !containsAlignmentWordRune(tokenRunes) {
+			continue // 标点
+		}
+		for pos < len(refRunes) && !isAlignmentWordRune(refRunes[pos]) && !isHanRune(refRunes[pos]) {
+			pos++
+		}
+		at := -1
+		if alignmentMatchAt(refRunes, tokenRunes, pos) {
+			at = pos
+		} else {
+			// 跳过的输入只能是数字、符号或紧接数字的字母（单位等），不能含汉字
+			for j := pos + 1; j < len(refRunes) && j-pos <= ttsAlignmentMaxSkip && !isHanRune(refRunes[j-1]); j++ {
+				if !isAlignmentWordRune(refRunes[j-1]) || !isAlignmentWordRune(refRunes[j]) {
+					if alignmentMatchAt(refRunes, tokenRunes, j) && alignmentSkippable(refRunes[pos:j]) {
+						at = j
+						break
+					}
+				}
+			}
+		}
+		if at < 0 {
+			pending = append(pending, token)
+			continue
+		}
+		flush(pos, at)
+		lastStart, lastEnd = at, at+len(tokenRunes)
+		spans = append(spans, tokenSpan{token, lastStart, lastEnd})
+		pos = lastEnd
+	}
+	flush(pos, len(refRunes))
+
+	// 按输入的字词汇总：汉字逐字，其余为空白与汉字分隔的串，去掉首尾标点
+	words := []TTSWordTiming{}
+	previousEnd := 0.0
+	for _, span := range splitAlignmentInputWords(inputRunes) {
+		word := TTSWordTiming{Word: string(inputRunes[span[0]:span[1]]), Start: -1}
+		for _, token := range spans {
+			if token.start >= span[1] || token.end <= span[0] {
+				continue
+			}
+			if word.Start < 0 || token.timing.Start < word.Start {
+				word.Start = token.timing.Start
+			}
+			word.End = math.Max(word.End, token.timing.End)
+		}
+		if word.Start < 0 {
+			word.Start, word.End = previousEnd, previousEnd
+		}
+		previousEnd = word.End
+		words = append(words, word)
+	}
+	return words
+}
+
+// 输入中的字词区间：汉字逐字；其余以空白与汉字分隔，去掉首尾标点（负号保留），不含字母数字的丢弃
+func splitAlignmentInputWords(runes []rune) [][2]int {
+	var spans [][2]int
+	add := func(start int, end int) {
+		for start < end && unicode.IsPunct(runes[start]) && !(runes[start] == '-' && start+1 < end && unicode.IsDigit(runes[start+1])) {
+			start++
+		}
+		for end > start && unicode.IsPunct(runes[end-1]) {
+			end--
+		}
+		if containsAlignmentWordRune(runes[start:end]) {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+	start := -1
+	for i, r := range runes {
+		if unicode.IsSpace(r) || isHanRune(r) {
+			if start >= 0 {
+				add(start, i)
+				start = -1
+			}
+			if isHanRune(r) {
+				spans = append(spans, [2]int{i, i + 1})
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		add(start, len(runes))
+	}
+	return spans
+}
+
+// token 在 refRunes 的 at 处完整出现，且不是更长的字母数字串的一部分
+func alignmentMatchAt(refRunes []rune, tokenRunes []rune, at int) bool {
+	end := at + len(tokenRunes)
+	if end > len(refRunes) {
+		return false
+	}
+	for i, r := range tokenRunes {
+		if refRunes[at+i] != r {
+			return false
+		}
+	}
+	return end == len(refRunes) || !isAlignmentWordRune(refRunes[end]) || !isAlignmentWordRune(refRunes[end-1])
+}
+
+// 正则化可能改写掉的输入：不含汉字，字母须紧接数字（如 5km、10:30pm、1990s），独立的单词不能跳过
+func alignmentSkippable(runes []rune) bool {
+	for i, r := range runes {
+		if isHanRune(r) {
+			return false
+		}
+		if !unicode.IsLetter(r) || (i > 0 && unicode.IsLetter(runes[i-1])) {
+			continue
+		}
+		end := i
+		for end < len(runes) && unicode.IsLetter(runes[end]) {
+			end++
+		}
+		if !(i > 0 && unicode.IsDigit(runes[i-1])) && !(end < len(runes) && unicode.IsDigit(runes[end])) {
+			return false
+		}
+	}
+	return true
+}
+
+// 小写并去掉重音，与 uncased BERT 的规范化一致
+func foldAlignmentRunes(text string) []rune {
+	runes := []rune(strings.TrimPrefix(text, "##"))
+	for i, r := range runes {
+		if r >= 0x80 && !isHanRune(r) {
+			if decomposed := []rune(norm.NFD.String(string(r))); len(decomposed) > 0 {
+				r = decomposed[0]
+			}
+		}
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// 汉字以外的字母与数字，与汉字分开处理
+func isAlignmentWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isHanRune(r)
+}
+
+func containsAlignmentWordRune(runes []rune) bool {
+	for _, r := range runes {
+		if isAlignmentWordRune(r) || isHanRune(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHanRune(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// 读取模型的时长输出，返回每个输入位置（含隔位0）的帧数
+func readDurationOutput(value ort.Value, inputLen int) ([]float32, error) {
+	var data []float32
+	var shape ort.Shape
+	switch tensor := value.(type) {
+	case *ort.Tensor[float32]:
+		data = tensor.GetData()
+		shape = tensor.GetShape()
+	case *ort.Tensor[int64]:
+		shape = tensor.GetShape()
+		for _, v := range tensor.GetData() {
+			data = append(data, float32(v))
+		}
+	default:
+		return nil, fmt.Errorf("不支持的时长输出类型")
+	}
+
+	if len(data) == inputLen {
+		return append([]float32(nil), data...), nil
+	}
+	// 对齐矩阵 [.., T_y, T_x]：每个输入位置的帧数为该列之和
+	if len(shape) >= 2 && int(shape[len(shape)-1]) == inputLen && len(data)%inputLen == 0 {
+		durations := make([]float32, inputLen)
+		for i, v := range data {
+			durations[i%inputLen] += v
+		}
+		return durations, nil
+	}
+	return nil, fmt.Errorf("时长输出形状 %v 与输入长度 %d 不匹配", shape, inputLen)
+}
+
+// 由时长计算音素与字词的时间戳
+// phones、word2ph 为 g2p 结果（首尾带 "_"），tokens 为 BERT 分词结果，与 word2ph 去掉首尾后一一对应
+// durations 为模型输出的每个位置帧数，长度为 2*len(phones)+1，为 nil 时按音素均分
+func buildTTSAlignment(phones []string, word2ph []int, tokens []string, durations []float32, totalSeconds float64) *TTSAlignment {
+	alignment := &TTSAlignment{
+		Source: AlignmentSourceModel,
+		Phones: []TTSPhoneTiming{},
+		Words:  []TTSWordTiming{},
+	}
+	if len(durations) != 2*len(phones)+1 {
+		// 估算：隔位0不占时长，每个音素时长相同
+		alignment.Source = AlignmentSourceEstimated
+		durations = make([]float32, 2*len(phones)+1)
+		for i := range phones {
+			durations[2*i+1] = 1
+		}
+	}
+
+	// cumulative[k] 为前 k 个位置的帧数之和
+	cumulative := make([]float64, len(durations)+1)
+	for i, d := range durations {
+		cumulative[i+1] = cumulative[i] + float64(d)
+	}
+	totalFrames := cumulative[len(durations)]
+	if totalFrames <= 0 {
+		return alignment
+	}
+	secondsPerFrame := totalSeconds / totalFrames
+
+	// 音素 i 对应位置 2i+1，前面的隔位归入该音素，最后一个隔位归入最后一个音素
+	for i, phone := range phones {
+		end := cumulative[2*i+2] * secondsPerFrame
+		if i == len(phones)-1 {
+			end = totalSeconds
+		}
+		alignment.Phones = append(alignment.Phones, TTSPhoneTiming{
+			Phone: phone,
+			Start: roundSeconds(cumulative[2*i] * secondsPerFrame),
+			End:   roundSeconds(end),
+		})
+	}
+
+	// tokens 可能不含 [CLS]/[SEP]，与 word2ph 首尾的 "_" 对应
+	if len(tokens)+2 == len(word2ph) {
+		tokens = append(append([]string{""}, tokens...), "")
+	}
+	if len(tokens) != len(word2ph) {
+		fmt.Printf("BERT token数(%d)与word2ph(%d)不一致，只返回音素时间戳\n", len(tokens), len(word2ph))
+		return alignment
+	}
+
+	phoneIndex := word2ph[0]
+	for i := 1; i < len(word2ph)-1; i++ {
+		count := word2ph[i]
+		start, end := alignment.Phones[phoneIndex].Start, alignment.Phones[phoneIndex].Start
+		if count > 0 {
+			end = alignment.Phones[phoneIndex+count-1].End
+		}
+		phoneIndex += count
+
+		// 英文子词合并到前一个单词
+		token := tokens[i]
+		if strings.HasPrefix(token, "##") && len(alignment.Words) > 0 {
+			last := &alignment.Words[len(alignment.Words)-1]
+			last.Word += strings.TrimPrefix(token, "##")
+			last.End = end
+			continue
+		}
+		alignment.Words = append(alignment.Words, TTSWordTiming{Word: token, Start: start, End: end})
+	}
+	return alignment
+}
+
+// 保留到毫秒
+func roundSeconds(seconds float64) float64 {
+	return float64(int64(seconds*1000+0.5)) / 1000
+}
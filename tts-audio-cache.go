@@ -0,0 +1,587 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 合成音频缓存：同样的文本与音色参数直接返回缓存的PCM，不再推理BERT与VITS
+// key 为 规范化文本、语言、发音人、语速、推理超参数、seed、SSML、请求词条覆盖与模型版本 的 sha256
+// 内存一层按LRU淘汰，磁盘一层可选，按总大小上限淘汰最久未用的条目
+// 两层都按TTL过期：内存按写入时间计，磁盘按最近一次使用时间计
+//
+// 通过环境变量配置：
+//
+//	TTS_CACHE_MEMORY_MB   内存缓存上限，默认128，0为关闭
+//	TTS_CACHE_DIR         磁盘缓存目录，为空时不启用磁盘缓存
+//	TTS_CACHE_DISK_MB     磁盘缓存上限，默认1024
+//	TTS_CACHE_TTL_HOURS   过期时间，默认720（30天），0为不过期
+//
+// 管理接口：
+//
+//	GET    /cache              缓存统计
+//	DELETE /cache              清空缓存，?language=zh_x 时只清除该语言
+//	DELETE /cache/:key         清除一条，key 见响应头 X-Cache-Key
+var ttsAudioCache *TTSAudioCache
+
+// 磁盘缓存文件头
+const ttsAudioCacheMagic = "TTSC\x01"
+
+var reTTSCacheWhitespace = regexp.MustCompile(`\s+`)
+var reTTSCacheKey = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// 一条缓存的音频
+type TTSAudioCacheEntry struct {
+	PCM        []float32
+	SampleRate int
+	Language   Language
+	Seed       *int64 // 合成时使用的 seed，没有时为 nil
+	created    time.Time
+}
+
+type ttsDiskCacheItem struct {
+	size    int64
+	modTime time.Time
+}
+
+// 两层音频缓存
+type TTSAudioCache struct {
+	mutex sync.Mutex
+
+	maxMemoryBytes int64
+	memoryBytes    int64
+	lru            *list.List // 元素为 *ttsMemoryCacheItem，表头为最近使用
+	items          map[string]*list.Element
+
+	dir          string
+	maxDiskBytes int64
+	diskBytes    int64
+	diskIndex    map[string]ttsDiskCacheItem
+
+	ttl time.Duration
+
+	hits     int64
+	diskHits int64
+	misses   int64
+}
+
+type ttsMemoryCacheItem struct {
+	key   string
+	entry *TTSAudioCacheEntry
+	size  int64
+}
+
+// 缓存统计，GET /cache 返回
+type TTSAudioCacheStats struct {
+	MemoryEntries  int     `json:"memory_entries"`
+	MemoryBytes    int64   `json:"memory_bytes"`
+	MaxMemoryBytes int64   `json:"max_memory_bytes"`
+	DiskEnabled    bool    `json:"disk_enabled"`
+	DiskEntries    int     `json:"disk_entries"`
+	DiskBytes      int64   `json:"disk_bytes"`
+	MaxDiskBytes   int64   `json:"max_disk_bytes"`
+	TTLHours       float64 `json:"ttl_hours"`
+	Hits           int64   `json:"hits"`
+	DiskHits       int64   `json:"disk_hits"` // hits 中来自磁盘的次数
+	Misses         int64   `json:"misses"`
+	HitRate        float64 `json:"hit_rate"`
+}
+
+// 启动时按环境变量创建缓存，内存与磁盘都关闭时不启用
+func initTTSAudioCacheOnStartup() {
+	memoryMB := int64(128)
+	if n, err := strconv.ParseInt(os.Getenv("TTS_CACHE_MEMORY_MB"), 10, 64); err == nil && n >= 0 {
+		memoryMB = n
+	}
+	diskMB := int64(1024)
+	if n, err := strconv.ParseInt(os.Getenv("TTS_CACHE_DISK_MB"), 10, 64); err == nil && n > 0 {
+		diskMB = n
+	}
+	ttl := 720 * time.Hour
+	if n, err := strconv.ParseFloat(os.Getenv("TTS_CACHE_TTL_HOURS"), 64); err == nil && n >= 0 {
+		ttl = time.Duration(n * float64(time.Hour))
+	}
+	dir := os.Getenv("TTS_CACHE_DIR")
+	if memoryMB == 0 && dir == "" {
+		fmt.Println("音频缓存未启用")
+		return
+	}
+
+	cache, err := NewTTSAudioCache(memoryMB<<20, dir, diskMB<<20, ttl)
+	if err != nil {
+		fmt.Printf("初始化磁盘音频缓存失败，只使用内存缓存: %v\n", err)
+		cache, _ = NewTTSAudioCache(memoryMB<<20, "", 0, ttl)
+	}
+	ttsAudioCache = cache
+	stats := cache.Stats()
+	fmt.Printf("音频缓存已启用: 内存上限 %dMB, 磁盘目录 %q (已有 %d 条), TTL %v\n", memoryMB, dir, stats.DiskEntries, ttl)
+}
+
+// 创建缓存，dir 为空时不启用磁盘一层；已有的磁盘缓存文件会被索引
+func NewTTSAudioCache(maxMemoryBytes int64, dir string, maxDiskBytes int64, ttl time.Duration) (*TTSAudioCache, error) {
+	cache := &TTSAudioCache{
+		maxMemoryBytes: maxMemoryBytes,
+		lru:            list.New(),
+		items:          make(map[string]*list.Element),
+		dir:            dir,
+		maxDiskBytes:   maxDiskBytes,
+		diskIndex:      make(map[string]ttsDiskCacheItem),
+		ttl:            ttl,
+	}
+	if dir == "" {
+		return cache, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".pcm") {
+			return err
+		}
+		key := strings.TrimSuffix(filepath.Base(path), ".pcm")
+		if !reTTSCacheKey.MatchString(key) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		cache.diskIndex[key] = ttsDiskCacheItem{size: info.Size(), modTime: info.ModTime()}
+		cache.diskBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cache.mutex.Lock()
+	cache.evictDiskLocked()
+	cache.mutex.Unlock()
+	return cache, nil
+}
+
+// 计算缓存 key；seed 只在请求显式指定时参与计算，未指定时命中的条目返回当初使用的 seed
+// 全局用户词典按内容摘要参与计算，词典修改后旧条目自然不再命中
+func ttsAudioCacheKey(text string, language Language, isSSML bool, speakerID int, speed float32, params *TTSInferenceParams, seed *int64, lexicon []UserLexiconEntry) (string, error) {
+	model, err := lookupTTSModel(language)
+	if err != nil {
+		return "", err
+	}
+	normalized := strings.TrimSpace(reTTSCacheWhitespace.ReplaceAllString(text, " "))
+	if !isSSML {
+		normalized = NormalizeChineseText(normalized, model.Frontend)
+	}
+	fields, err := json.Marshal(struct {
+		Text           string             `json:"text"`
+		Language       Language           `json:"language"`
+		SSML           bool               `json:"ssml"`
+		SpeakerID      int                `json:"speaker_id"`
+		Speed          float32            `json:"speed"`
+		SdpRatio       float32            `json:"sdp_ratio"`
+		NoiseScale     float32            `json:"noise_scale"`
+		NoiseScaleW    float32            `json:"noise_scale_w"`
+		Seed           *int64             `json:"seed"`
+		Lexicon        []UserLexiconEntry `json:"lexicon"`
+		LexiconVersion string             `json:"lexicon_version"`
+		ModelVersion   string             `json:"model_version"`
+	}{normalized, language, isSSML, speakerID, speed, params.SdpRatio, params.NoiseScale, params.NoiseScaleW, seed, lexicon, userLexicon.Version(), ttsModelVersion(model)})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+var ttsModelVersions sync.Map // 模型路径 -> 版本
+
+// 模型版本：模型文件路径、大小与修改时间的摘要，替换模型文件并重启后旧缓存自然失效
+func ttsModelVersion(model *TTSModelConfig) string {
+	if version, ok := ttsModelVersions.Load(model.Model); ok {
+		return version.(string)
+	}
+	hash := sha256.New()
+	for _, path := range []string{model.Model, model.BertModel, model.BertTokenizer, model.Symbols} {
+		fmt.Fprintf(hash, "%s;", path)
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(hash, "%d;%d;", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	fmt.Fprintf(hash, "%d;%d", *model.ToneOffset, model.SampleRate)
+	version := hex.EncodeToString(hash.Sum(nil))[:16]
+	ttsModelVersions.Store(model.Model, version)
+	return version
+}
+
+// Get 先查内存再查磁盘，磁盘命中后放入内存
+func (c *TTSAudioCache) Get(key string) (*TTSAudioCacheEntry, bool) {
+	c.mutex.Lock()
+	if element, ok := c.items[key]; ok {
+		item := element.Value.(*ttsMemoryCacheItem)
+		if c.expired(item.entry.created) {
+			c.removeMemoryLocked(element)
+		} else {
+			c.lru.MoveToFront(element)
+			c.hits++
+			c.mutex.Unlock()
+			return item.entry, true
+		}
+	}
+	diskItem, onDisk := c.diskIndex[key]
+	c.mutex.Unlock()
+
+	if onDisk {
+		if c.expired(diskItem.modTime) {
+			c.removeDisk(key)
+		} else if entry, err := c.readDisk(key); err == nil {
+			now := time.Now()
+			os.Chtimes(c.diskPath(key), now, now) // 修改时间即最近使用时间
+			c.mutex.Lock()
+			c.diskIndex[key] = ttsDiskCacheItem{size: diskItem.size, modTime: now}
+			c.hits++
+			c.diskHits++
+			c.putMemoryLocked(key, entry)
+			c.mutex.Unlock()
+			return entry, true
+		} else {
+			fmt.Printf("读取磁盘音频缓存失败，删除: %v\n", err)
+			c.removeDisk(key)
+		}
+	}
+
+	c.mutex.Lock()
+	c.misses++
+	c.mutex.Unlock()
+	return nil, false
+}
+
+// Put 写入内存与磁盘
+func (c *TTSAudioCache) Put(key string, entry *TTSAudioCacheEntry) {
+	entry.created = time.Now()
+	c.mutex.Lock()
+	c.putMemoryLocked(key, entry)
+	c.mutex.Unlock()
+
+	if c.dir == "" {
+		return
+	}
+	size, err := c.writeDisk(key, entry)
+	if err != nil {
+		fmt.Printf("写入磁盘音频缓存失败: %v\n", err)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if old, ok := c.diskIndex[key]; ok {
+		c.diskBytes -= old.size
+	}
+	c.diskIndex[key] = ttsDiskCacheItem{size: size, modTime: entry.created}
+	c.diskBytes += size
+	c.evictDiskLocked()
+}
+
+// Purge 清除缓存，language 为空时全部清除，返回清除的条数（内存与磁盘中的同一条只计一次）
+func (c *TTSAudioCache) Purge(language Language) int {
+	removed := make(map[string]bool)
+	c.mutex.Lock()
+	for key, element := range c.items {
+		if language == "" || element.Value.(*ttsMemoryCacheItem).entry.Language == language {
+			c.removeMemoryLocked(element)
+			removed[key] = true
+		}
+	}
+	var diskKeys []string
+	for key := range c.diskIndex {
+		diskKeys = append(diskKeys, key)
+	}
+	c.mutex.Unlock()
+
+	for _, key := range diskKeys {
+		if language != "" {
+			entryLanguage, err := c.readDiskLanguage(key)
+			if err == nil && entryLanguage != language {
+				continue
+			}
+		}
+		c.removeDisk(key)
+		removed[key] = true
+	}
+	return len(removed)
+}
+
+// Delete 清除一条，返回是否存在
+func (c *TTSAudioCache) Delete(key string) bool {
+	c.mutex.Lock()
+	element, inMemory := c.items[key]
+	if inMemory {
+		c.removeMemoryLocked(element)
+	}
+	_, onDisk := c.diskIndex[key]
+	c.mutex.Unlock()
+	if onDisk {
+		c.removeDisk(key)
+	}
+	return inMemory || onDisk
+}
+
+func (c *TTSAudioCache) Stats() TTSAudioCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := TTSAudioCacheStats{
+		MemoryEntries:  len(c.items),
+		MemoryBytes:    c.memoryBytes,
+		MaxMemoryBytes: c.maxMemoryBytes,
+		DiskEnabled:    c.dir != "",
+		DiskEntries:    len(c.diskIndex),
+		DiskBytes:      c.diskBytes,
+		MaxDiskBytes:   c.maxDiskBytes,
+		TTLHours:       c.ttl.Hours(),
+		Hits:           c.hits,
+		DiskHits:       c.diskHits,
+		Misses:         c.misses,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+func (c *TTSAudioCache) expired(created time.Time) bool {
+	return c.ttl > 0 && time.Since(created) > c.ttl
+}
+
+func (c *TTSAudioCache) putMemoryLocked(key string, entry *TTSAudioCacheEntry) {
+	size := int64(len(entry.PCM)) * 4
+	if c.maxMemoryBytes <= 0 || size > c.maxMemoryBytes {
+		return
+	}
+	if element, ok := c.items[key]; ok {
+		c.removeMemoryLocked(element)
+	}
+	c.items[key] = c.lru.PushFront(&ttsMemoryCacheItem{key: key, entry: entry, size: size})
+	c.memoryBytes += size
+	for c.memoryBytes > c.maxMemoryBytes {
+		c.removeMemoryLocked(c.lru.Back())
+	}
+}
+
+func (c *TTSAudioCache) removeMemoryLocked(element *list.Element) {
+	item := element.Value.(*ttsMemoryCacheItem)
+	c.lru.Remove(element)
+	delete(c.items, item.key)
+	c.memoryBytes -= item.size
+}
+
+// 超出磁盘上限时按最近使用时间从旧到新删除
+func (c *TTSAudioCache) evictDiskLocked() {
+	if c.diskBytes <= c.maxDiskBytes {
+		return
+	}
+	keys := make([]string, 0, len(c.diskIndex))
+	for key := range c.diskIndex {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.diskIndex[keys[i]].modTime.Before(c.diskIndex[keys[j]].modTime) })
+	for _, key := range keys {
+		if c.diskBytes <= c.maxDiskBytes {
+			break
+		}
+		os.Remove(c.diskPath(key))
+		c.diskBytes -= c.diskIndex[key].size
+		delete(c.diskIndex, key)
+	}
+}
+
+func (c *TTSAudioCache) removeDisk(key string) {
+	os.Remove(c.diskPath(key))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if item, ok := c.diskIndex[key]; ok {
+		c.diskBytes -= item.size
+		delete(c.diskIndex, key)
+	}
+}
+
+// 按 key 前两位分子目录，避免单个目录文件过多
+func (c *TTSAudioCache) diskPath(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".pcm")
+}
+
+// 磁盘格式：魔数、采样率 uint32、是否有seed uint8、seed int64、语言长度 uint8、语言、float32 小端PCM
+func (c *TTSAudioCache) writeDisk(key string, entry *TTSAudioCacheEntry) (int64, error) {
+	path := c.diskPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	header := []byte(ttsAudioCacheMagic)
+	header = binary.LittleEndian.AppendUint32(header, uint32(entry.SampleRate))
+	var seed int64
+	if entry.Seed != nil {
+		header = append(header, 1)
+		seed = *entry.Seed
+	} else {
+		header = append(header, 0)
+	}
+	header = binary.LittleEndian.AppendUint64(header, uint64(seed))
+	header = append(header, byte(len(entry.Language)))
+	header = append(header, entry.Language...)
+
+	data := make([]byte, 0, len(header)+len(entry.PCM)*4)
+	data = append(data, header...)
+	for _, sample := range entry.PCM {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(sample))
+	}
+
+	// 先写临时文件再改名，并发读取时不会读到写了一半的文件
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (c *TTSAudioCache) readDisk(key string) (*TTSAudioCacheEntry, error) {
+	data, err := os.ReadFile(c.diskPath(key))
+	if err != nil {
+		return nil, err
+	}
+	entry, offset, err := parseTTSAudioCacheHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	body := data[offset:]
+	if len(body)%4 != 0 {
+		return nil, fmt.Errorf("缓存文件长度有误: %s", key)
+	}
+	entry.PCM = make([]float32, len(body)/4)
+	for i := range entry.PCM {
+		entry.PCM[i] = math.Float32frombits(binary.LittleEndian.Uint32(body[i*4:]))
+	}
+	entry.created = time.Now()
+	return entry, nil
+}
+
+// 只读文件头取语言，按语言清除时使用
+func (c *TTSAudioCache) readDiskLanguage(key string) (Language, error) {
+	file, err := os.Open(c.diskPath(key))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	header := make([]byte, len(ttsAudioCacheMagic)+4+1+8+1+255)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	entry, _, err := parseTTSAudioCacheHeader(header[:n])
+	if err != nil {
+		return "", err
+	}
+	return entry.Language, nil
+}
+
+func parseTTSAudioCacheHeader(data []byte) (*TTSAudioCacheEntry, int, error) {
+	offset := len(ttsAudioCacheMagic)
+	if len(data) < offset+4+1+8+1 || string(data[:offset]) != ttsAudioCacheMagic {
+		return nil, 0, fmt.Errorf("不是音频缓存文件")
+	}
+	entry := &TTSAudioCacheEntry{SampleRate: int(binary.LittleEndian.Uint32(data[offset:]))}
+	offset += 4
+	hasSeed := data[offset] == 1
+	offset++
+	seed := int64(binary.LittleEndian.Uint64(data[offset:]))
+	offset += 8
+	if hasSeed {
+		entry.Seed = &seed
+	}
+	languageLen := int(data[offset])
+	offset++
+	if len(data) < offset+languageLen {
+		return nil, 0, fmt.Errorf("缓存文件头不完整")
+	}
+	entry.Language = Language(data[offset : offset+languageLen])
+	return entry, offset + languageLen, nil
+}
+
+// 查询缓存并设置 X-Cache、X-Cache-Key 响应头，key 为空表示本次请求不使用缓存
+// 命中时 X-TTS-Seed 改为缓存条目当初使用的 seed
+func lookupTTSAudioCache(c *gin.Context, key string) *TTSAudioCacheEntry {
+	if ttsAudioCache == nil || key == "" {
+		return nil
+	}
+	c.Header("X-Cache-Key", key)
+	entry, ok := ttsAudioCache.Get(key)
+	if !ok {
+		c.Header("X-Cache", "MISS")
+		return nil
+	}
+	c.Header("X-Cache", "HIT")
+	if entry.Seed != nil {
+		c.Header("X-TTS-Seed", strconv.FormatInt(*entry.Seed, 10))
+	} else {
+		c.Writer.Header().Del("X-TTS-Seed")
+		c.Writer.Header().Del("X-TTS-Seed-Mode")
+	}
+	return entry
+}
+
+func registerCacheRoutes(r *gin.Engine) {
+	r.GET("/cache", cacheStatsHandler)
+	r.DELETE("/cache", cachePurgeHandler)
+	r.DELETE("/cache/:key", cacheDeleteHandler)
+}
+
+func cacheStatsHandler(c *gin.Context) {
+	if ttsAudioCache == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"enabled": true,
+		"stats":   ttsAudioCache.Stats(),
+	})
+}
+
+func cachePurgeHandler(c *gin.Context) {
+	purged := 0
+	if ttsAudioCache != nil {
+		purged = ttsAudioCache.Purge(Language(c.Query("language")))
+	}
+	fmt.Printf("清除音频缓存: language=%q, 条数=%d\n", c.Query("language"), purged)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"purged":  purged,
+	})
+}
+
+func cacheDeleteHandler(c *gin.Context) {
+	key := c.Param("key")
+	if ttsAudioCache == nil || !reTTSCacheKey.MatchString(key) || !ttsAudioCache.Delete(key) {
+		ttsErrorResponse(c, newTTSError(ErrCodeNotFound, "缓存中没有: "+key, nil))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"purged":  1,
+	})
+}
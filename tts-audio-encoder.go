@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 音频编码层：引擎输出 [-1, 1] 的 float32 单声道PCM，按请求的 format 字段或 Accept 头选择编码器
+//
+//	wav      16位PCM WAV（默认）
+//	wav24    24位PCM WAV
+//	wav_f32  32位浮点WAV，供后期制作
+//	pcm      16位有符号小端裸PCM，无文件头
+//	mulaw    8位 G.711 μ-law 裸数据，供电话线路
+//	alaw     8位 G.711 A-law 裸数据
+//	flac     FLAC 无损压缩（16位），供归档
+//
+// format 优先；没有 format 时按 Accept 头协商，Accept 中没有认识的类型时返回默认的 wav
+
+const defaultAudioFormat = "wav"
+
+// 音频编码器
+type AudioEncoder interface {
+	Name() string        // format 名
+	ContentType() string // 响应的 Content-Type
+	Extension() string   // 文件扩展名
+	Encode(w io.Writer, pcmData []float32, sampleRate int) error
+}
+
+// 可流式输出的编码器：先写长度未知的文件头，之后逐块写入音频
+type StreamingAudioEncoder interface {
+	AudioEncoder
+	WriteStreamHeader(w io.Writer, sampleRate int) error
+	EncodeChunk(w io.Writer, pcmData []float32) error
+}
+
+// 已注册的编码器，key 为 format 名
+var audioEncoders = map[string]AudioEncoder{
+	"wav":     &wavEncoder{name: "wav", bitsPerSample: 16},
+	"wav24":   &wavEncoder{name: "wav24", bitsPerSample: 24},
+	"wav_f32": &wavEncoder{name: "wav_f32", bitsPerSample: 32, float: true},
+	"pcm":     &rawAudioEncoder{name: "pcm", contentType: "audio/pcm", extension: "pcm", sampleSize: 2, encode: appendPCM16},
+	"mulaw":   &rawAudioEncoder{name: "mulaw", contentType: "audio/basic", extension: "ulaw", sampleSize: 1, encode: appendMuLaw},
+	"alaw":    &rawAudioEncoder{name: "alaw", contentType: "audio/x-alaw-basic", extension: "alaw", sampleSize: 1, encode: appendALaw},
+	"flac":    &flacEncoder{},
+}
+
+// format 的别名
+var audioFormatAliases = map[string]string{
+	"wav16":     "wav",
+	"wav_s16":   "wav",
+	"wav_s24":   "wav24",
+	"wav32f":    "wav_f32",
+	"wav_float": "wav_f32",
+	"pcm_s16le": "pcm",
+	"s16le":     "pcm",
+	"ulaw":      "mulaw",
+	"pcmu":      "mulaw",
+	"pcma":      "alaw",
+}
+
+// Accept 头中可识别的 MIME 类型
+var audioAcceptTypes = map[string]string{
+	"audio/wav":          "wav",
+	"audio/wave":         "wav",
+	"audio/x-wav":        "wav",
+	"audio/vnd.wave":     "wav",
+	"audio/pcm":          "pcm",
+	"audio/basic":        "mulaw",
+	"audio/pcmu":         "mulaw",
+	"audio/pcma":         "alaw",
+	"audio/x-alaw-basic": "alaw",
+	"audio/flac":         "flac",
+	"audio/x-flac":       "flac",
+}
+
+// 按 format 名查找编码器，不区分大小写，空字符串返回默认编码器
+func lookupAudioEncoder(format string) (AudioEncoder, error) {
+	name := strings.ToLower(strings.TrimSpace(format))
+	if name == "" {
+		name = defaultAudioFormat
+	}
+	if alias, ok := audioFormatAliases[name]; ok {
+		name = alias
+	}
+	encoder, ok := audioEncoders[name]
+	if !ok {
+		return nil, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("不支持的音频格式: %s，可选 %s", format, strings.Join(audioFormatNames(), " / ")), nil)
+	}
+	return encoder, nil
+}
+
+// 按请求的 format 与 Accept 头选择编码器
+func negotiateAudioEncoder(format string, accept string) (AudioEncoder, error) {
+	if format != "" {
+		return lookupAudioEncoder(format)
+	}
+	if name := acceptedAudioFormat(accept); name != "" {
+		return audioEncoders[name], nil
+	}
+	return audioEncoders[defaultAudioFormat], nil
+}
+
+// 解析 Accept 头，按 q 值从高到低返回第一个可识别的格式，audio/* 与 */* 对应默认格式
+func acceptedAudioFormat(accept string) string {
+	type acceptType struct {
+		mime string
+		q    float64
+	}
+	var types []acceptType
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mime := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mime == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			types = append(types, acceptType{mime: mime, q: q})
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool { return types[i].q > types[j].q })
+	for _, t := range types {
+		if name, ok := audioAcceptTypes[t.mime]; ok {
+			return name
+		}
+		if t.mime == "audio/*" || t.mime == "*/*" {
+			return defaultAudioFormat
+		}
+	}
+	return ""
+}
+
+// 所有 format 名，按字母排序
+func audioFormatNames() []string {
+	names := make([]string, 0, len(audioEncoders))
+	for name := range audioEncoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 编码到内存
+func encodeAudio(encoder AudioEncoder, pcmData []float32, sampleRate int) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := encoder.Encode(buffer, pcmData, sampleRate); err != nil {
+		return nil, fmt.Errorf("%s 编码失败: %w", encoder.Name(), err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// 按文件扩展名选择编码器保存音频，无法识别的扩展名按WAV保存
+func saveAudioFile(pcmData []float32, filename string, sampleRate int) error {
+	fmt.Printf("音频时长: %.2f 秒\n", float64(len(pcmData))/float64(sampleRate))
+
+	encoder := audioEncoders[defaultAudioFormat]
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	for _, name := range audioFormatNames() {
+		if audioEncoders[name].Extension() == ext {
+			encoder = audioEncoders[name]
+			break
+		}
+	}
+	data, err := encodeAudio(encoder, pcmData, sampleRate)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	fmt.Printf("✓ 音频文件已保存: %s (%s)\n", filename, encoder.Name())
+	return nil
+}
+
+// 限制到 [-1, 1]
+func clampSample(sample float32) float32 {
+	if sample > 1.0 {
+		return 1.0
+	} else if sample < -1.0 {
+		return -1.0
+	}
+	return sample
+}
+
+// float32 转16位整数
+func sampleToInt16(sample float32) int16 {
+	return int16(clampSample(sample) * 32767)
+}
+
+// WAV 编码器，16/24位整数或32位浮点
+type wavEncoder struct {
+	name          string
+	bitsPerSample int
+	float         bool
+}
+
+func (e *wavEncoder) Name() string        { return e.name }
+func (e *wavEncoder) ContentType() string { return "audio/wav" }
+func (e *wavEncoder) Extension() string   { return "wav" }
+
+func (e *wavEncoder) Encode(w io.Writer, pcmData []float32, sampleRate int) error {
+	dataSize := uint64(len(pcmData)) * uint64(e.bitsPerSample/8)
+	if dataSize > math.MaxUint32-uint64(e.headerSize()) {
+		return fmt.Errorf("音频过长，超出WAV文件的4GB上限")
+	}
+	if err := e.writeHeader(w, uint32(dataSize), len(pcmData), sampleRate); err != nil {
+		return err
+	}
+	return e.EncodeChunk(w, pcmData)
+}
+
+// 流式输出时 data 长度未知，按惯例填 0xFFFFFFFF
+func (e *wavEncoder) WriteStreamHeader(w io.Writer, sampleRate int) error {
+	return e.writeHeader(w, math.MaxUint32, 0, sampleRate)
+}
+
+func (e *wavEncoder) EncodeChunk(w io.Writer, pcmData []float32) error {
+	data := make([]byte, 0, len(pcmData)*e.bitsPerSample/8)
+	switch {
+	case e.float:
+		for _, sample := range pcmData {
+			data = appendUint32(data, math.Float32bits(sample))
+		}
+	case e.bitsPerSample == 24:
+		for _, sample := range pcmData {
+			value := int32(float64(clampSample(sample)) * 8388607)
+			data = append(data, byte(value), byte(value>>8), byte(value>>16))
+		}
+	default:
+		data = appendPCM16(data, pcmData)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// 浮点WAV（格式码3）按规范需要扩展的 fmt chunk 与 fact chunk
+func (e *wavEncoder) headerSize() int {
+	if e.float {
+		return 58
+	}
+	return 44
+}
+
+// 写入单声道WAV头，dataSize为data chunk字节数
+func (e *wavEncoder) writeHeader(w io.Writer, dataSize uint32, numSamples int, sampleRate int) error {
+	const numChannels = 1
+	blockAlign := e.bitsPerSample / 8 * numChannels
+
+	fileSize := uint64(dataSize) + uint64(e.headerSize()) - 8 // 文件大小 = 数据大小 + 头大小 - "RIFF"标识(4) - 文件大小字段(4)
+	if fileSize > math.MaxUint32 {
+		fileSize = math.MaxUint32 // 流式输出时长度未知
+	}
+
+	// 写入RIFF头
+	header := make([]byte, 0, e.headerSize())
+	header = append(header, "RIFF"...)
+	header = appendUint32(header, uint32(fileSize))
+	header = append(header, "WAVE"...)
+
+	// 写入fmt chunk
+	header = append(header, "fmt "...)
+	formatTag := uint16(1) // PCM格式
+	if e.float {
+		formatTag = 3 // IEEE float
+		header = appendUint32(header, 18)
+	} else {
+		header = appendUint32(header, 16)
+	}
+	header = appendUint16(header, formatTag)
+	header = appendUint16(header, numChannels)
+	header = appendUint32(header, uint32(sampleRate))
+	header = appendUint32(header, uint32(sampleRate*blockAlign)) // 字节率
+	header = appendUint16(header, uint16(blockAlign))            // 块对齐
+	header = appendUint16(header, uint16(e.bitsPerSample))
+	if e.float {
+		header = appendUint16(header, 0) // 扩展字段长度
+
+		// 写入fact chunk，流式输出时样本数未知填0
+		header = append(header, "fact"...)
+		header = appendUint32(header, 4)
+		header = appendUint32(header, uint32(numSamples))
+	}
+
+	// 写入data chunk头
+	header = append(header, "data"...)
+	header = appendUint32(header, dataSize)
+
+	_, err := w.Write(header)
+	return err
+}
+
+// 无文件头的裸音频编码器
+type rawAudioEncoder struct {
+	name        string
+	contentType string
+	extension   string
+	sampleSize  int
+	encode      func(data []byte, pcmData []float32) []byte
+}
+
+func (e *rawAudioEncoder) Name() string        { return e.name }
+func (e *rawAudioEncoder) ContentType() string { return e.contentType }
+func (e *rawAudioEncoder) Extension() string   { return e.extension }
+
+func (e *rawAudioEncoder) Encode(w io.Writer, pcmData []float32, sampleRate int) error {
+	return e.EncodeChunk(w, pcmData)
+}
+
+func (e *rawAudioEncoder) WriteStreamHeader(w io.Writer, sampleRate int) error {
+	return nil
+}
+
+func (e *rawAudioEncoder) EncodeChunk(w io.Writer, pcmData []float32) error {
+	_, err := w.Write(e.encode(make([]byte, 0, len(pcmData)*e.sampleSize), pcmData))
+	return err
+}
+
+// 16位有符号小端PCM
+func appendPCM16(data []byte, pcmData []float32) []byte {
+	for _, sample := range pcmData {
+		data = appendUint16(data, uint16(sampleToInt16(sample)))
+	}
+	return data
+}
+
+// G.711 μ-law
+func appendMuLaw(data []byte, pcmData []float32) []byte {
+	for _, sample := range pcmData {
+		data = append(data, linearToMuLaw(sampleToInt16(sample)))
+	}
+	return data
+}
+
+// G.711 A-law
+func appendALaw(data []byte, pcmData []float32) []byte {
+	for _, sample := range pcmData {
+		data = append(data, linearToALaw(sampleToInt16(sample)))
+	}
+	return data
+}
+
+// 16位线性PCM转 μ-law，取高14位，分8段，每段16级
+func linearToMuLaw(pcmValue int16) byte {
+	const (
+		bias = 0x21 // 33，14位下的偏置
+		clip = 8159
+	)
+	value := int(pcmValue) >> 2
+	mask := byte(0xFF)
+	if value < 0 {
+		value = -value
+		mask = 0x7F
+	}
+	if value > clip {
+		value = clip
+	}
+	value += bias
+
+	segment := 0
+	for segment < 8 && value > 0x40<<segment-1 {
+		segment++
+	}
+	if segment >= 8 {
+		return 0x7F ^ mask
+	}
+	return byte(segment<<4|(value>>(segment+1))&0x0F) ^ mask
+}
+
+// 16位线性PCM转 A-law，取高13位，分8段，每段16级
+func linearToALaw(pcmValue int16) byte {
+	value := int(pcmValue) >> 3
+	mask := byte(0xD5)
+	if value < 0 {
+		value = -value - 1
+		mask = 0x55
+	}
+
+	segment := 0
+	for segment < 8 && value > 0x20<<segment-1 {
+		segment++
+	}
+	if segment >= 8 {
+		return 0x7F ^ mask
+	}
+	code := segment << 4
+	if segment < 2 {
+		code |= (value >> 1) & 0x0F
+	} else {
+		code |= (value >> segment) & 0x0F
+	}
+	return byte(code) ^ mask
+}
+
+// 追加32位整数（小端格式）
+func appendUint32(data []byte, value uint32) []byte {
+	return append(data, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+}
+
+// 追加16位整数（小端格式）
+func appendUint16(data []byte, value uint16) []byte {
+	return append(data, byte(value), byte(value>>8))
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// 动态微批：同一引擎池短时间内到达的多个短请求合并为一次 BERT 与 VITS 推理（batch>1），
+// x/tones/ja_bert 补齐到批内最长并给出各自的 x_lengths，推理后按条拆回音频
+//
+// 通过环境变量配置：
+//
+//	TTS_BATCH_MAX_SIZE     每批最多请求数，默认1即不合批
+//	TTS_BATCH_MAX_WAIT_MS  第一条请求到达后最多等待多少毫秒凑批，默认5
+//
+// length_scale、sdp_ratio 等标量输入整批共用，只有 speed 与推理超参数都相同的请求才会合进同一批
+// 模型需以动态 batch 维导出，并导出时长输出（w_ceil/attn）用于按条切分音频，否则不合批
+var (
+	ttsBatchMaxSize = 1
+	ttsBatchMaxWait = 5 * time.Millisecond
+)
+
+func init() {
+	if n, err := strconv.Atoi(os.Getenv("TTS_BATCH_MAX_SIZE")); err == nil && n > 0 {
+		ttsBatchMaxSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("TTS_BATCH_MAX_WAIT_MS")); err == nil && n >= 0 {
+		ttsBatchMaxWait = time.Duration(n) * time.Millisecond
+	}
+}
+
+// 一条待合批的合成请求
+type ttsBatchRequest struct {
+	ctx       context.Context
+	text      string
+	lexicon   *UserLexicon
+	speakerID int
+	speed     float32
+	params    TTSInferenceParams
+	result    chan ttsBatchResult // 容量1，调用方超时离开后写入也不会阻塞
+}
+
+type ttsBatchResult struct {
+	audio []float32
+	err   error
+}
+
+// 合批调度器，挂在引擎池前面
+type TTSBatcher struct {
+	pool     *TTSEnginePool
+	maxBatch int
+	maxWait  time.Duration
+	requests chan *ttsBatchRequest
+
+	mutex        sync.Mutex
+	batches      int64 // 合批推理次数（batch>1）
+	batchedItems int64
+	batchedTime  time.Duration
+	singles      int64 // 单条推理次数
+	singleTime   time.Duration
+	fallbacks    int64 // 合批推理失败后退回逐条推理的次数
+	largestBatch int
+}
+
+// 合批统计，用于和逐条推理对比，/health 返回
+type TTSBatchStats struct {
+	MaxBatch        int     `json:"max_batch"`
+	MaxWaitMs       float64 `json:"max_wait_ms"`
+	Queued          int     `json:"queued"`
+	Batches         int64   `json:"batches"`
+	BatchedRequests int64   `json:"batched_requests"`
+	AvgBatchSize    float64 `json:"avg_batch_size"`
+	LargestBatch    int     `json:"largest_batch"`
+	BatchedAvgMs    float64 `json:"batched_avg_ms_per_request"` // 合批推理平均每条耗时
+	SingleRequests  int64   `json:"single_requests"`
+	SingleAvgMs     float64 `json:"single_avg_ms_per_request"` // 逐条推理平均每条耗时
+	Fallbacks       int64   `json:"fallbacks"`
+}
+
+func NewTTSBatcher(pool *TTSEnginePool, maxBatch int, maxWait time.Duration, maxQueue int) *TTSBatcher {
+	b := &TTSBatcher{
+		pool:     pool,
+		maxBatch: maxBatch,
+		maxWait:  maxWait,
+		requests: make(chan *ttsBatchRequest, max(maxQueue, maxBatch)),
+	}
+	go b.loop()
+	return b
+}
+
+// Synthesize 提交一条请求并等待合成结果，队列满时返回 server_busy
+func (b *TTSBatcher) Synthesize(ctx context.Context, text string, lexicon *UserLexicon, speakerID int, speed float32, params TTSInferenceParams) ([]float32, error) {
+	req := &ttsBatchRequest{
+		ctx:       ctx,
+		text:      text,
+		lexicon:   lexicon,
+		speakerID: speakerID,
+		speed:     speed,
+		params:    params,
+		result:    make(chan ttsBatchResult, 1),
+	}
+	select {
+	case b.requests <- req:
+	default:
+		b.pool.mutex.Lock()
+		b.pool.rejected++
+		b.pool.mutex.Unlock()
+		return nil, newTTSError(ErrCodeServerBusy, fmt.Sprintf("服务繁忙，排队请求已达上限 %d", cap(b.requests)), nil)
+	}
+
+	select {
+	case result := <-req.result:
+		return result.audio, result.err
+	case <-ctx.Done():
+		return nil, newTTSError(ErrCodeQueueTimeout, "合成期间请求已取消", ctx.Err())
+	}
+}
+
+// 收集请求：拿到第一条后最多等 maxWait 或凑满 maxBatch，再按 speed 与超参数分组交给空闲引擎
+func (b *TTSBatcher) loop() {
+	for first := range b.requests {
+		batch := []*ttsBatchRequest{first}
+		timer := time.NewTimer(b.maxWait)
+	collect:
+		for len(batch) < b.maxBatch {
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		for _, group := range groupTTSBatchByScalars(liveTTSBatchRequests(batch)) {
+			// 等空闲引擎期间新到的请求继续在通道里排队，留给下一批
+			engine, err := b.pool.Acquire(context.Background())
+			if err != nil {
+				for _, req := range group {
+					req.result <- ttsBatchResult{err: err}
+				}
+				continue
+			}
+			// 等引擎期间调用方可能已经离开
+			if group = liveTTSBatchRequests(group); len(group) == 0 {
+				b.pool.Release(engine)
+				continue
+			}
+			go func(engine *XWX_TTS, group []*ttsBatchRequest) {
+				defer b.pool.Release(engine)
+				b.run(engine, group)
+			}(engine, group)
+		}
+	}
+}
+
+// 去掉调用方已取消或超时的请求，不再为其推理
+func liveTTSBatchRequests(batch []*ttsBatchRequest) []*ttsBatchRequest {
+	var live []*ttsBatchRequest
+	for _, req := range batch {
+		if err := req.ctx.Err(); err != nil {
+			req.result <- ttsBatchResult{err: newTTSError(ErrCodeQueueTimeout, "排队期间请求已取消", err)}
+			continue
+		}
+		live = append(live, req)
+	}
+	return live
+}
+
+// 标量输入相同的请求分为一组
+type ttsBatchKey struct {
+	speed  float32
+	params TTSInferenceParams
+}
+
+func groupTTSBatchByScalars(batch []*ttsBatchRequest) [][]*ttsBatchRequest {
+	var groups [][]*ttsBatchRequest
+	index := make(map[ttsBatchKey]int)
+	for _, req := range batch {
+		key := ttsBatchKey{speed: req.speed, params: req.params}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], req)
+	}
+	return groups
+}
+
+// 在一个引擎上完成一组请求，合批推理失败时退回逐条推理
+func (b *TTSBatcher) run(engine *XWX_TTS, group []*ttsBatchRequest) {
+	start := time.Now()
+	if len(group) > 1 && engine.supportsBatching() {
+		audios, errs, err := engine.Tts_pcm_batch(group)
+		if err == nil {
+			b.record(len(group), time.Since(start), false)
+			for i, req := range group {
+				req.result <- ttsBatchResult{audio: audios[i], err: errs[i]}
+			}
+			return
+		}
+		fmt.Printf("合批推理失败，退回逐条推理: %v\n", err)
+		b.record(0, 0, true)
+		start = time.Now()
+	}
+
+	group = liveTTSBatchRequests(group)
+	for _, req := range group {
+		audio, err := engine.Tts_pcm(req.text, req.lexicon, req.speakerID, req.speed, &req.params)
+		req.result <- ttsBatchResult{audio: audio, err: err}
+	}
+	b.record(len(group), time.Since(start), false)
+}
+
+// 引擎能否合批推理：需要时长输出才能按条切出音频，批量推理因形状报错后不再合批
+func (m *XWX_TTS) supportsBatching() bool {
+	return m.durationOutputName != "" && !m.batchUnsupported
+}
+
+// 模型没有动态 batch 维时 ONNX Runtime 报维度或形状错误，其余错误（如显存不足）可能是暂时的
+func isTTSBatchShapeError(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "dimension") || strings.Contains(message, "shape") || strings.Contains(message, "rank")
+}
+
+func (b *TTSBatcher) record(size int, elapsed time.Duration, fallback bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if fallback {
+		b.fallbacks++
+		return
+	}
+	if size > 1 && elapsed > 0 {
+		b.batches++
+		b.batchedItems += int64(size)
+		b.batchedTime += elapsed
+		b.largestBatch = max(b.largestBatch, size)
+		return
+	}
+	b.singles += int64(size)
+	b.singleTime += elapsed
+}
+
+func (b *TTSBatcher) Stats() TTSBatchStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	stats := TTSBatchStats{
+		MaxBatch:        b.maxBatch,
+		MaxWaitMs:       float64(b.maxWait.Microseconds()) / 1000,
+		Queued:          len(b.requests),
+		Batches:         b.batches,
+		BatchedRequests: b.batchedItems,
+		LargestBatch:    b.largestBatch,
+		SingleRequests:  b.singles,
+		Fallbacks:       b.fallbacks,
+	}
+	if b.batches > 0 {
+		stats.AvgBatchSize = float64(b.batchedItems) / float64(b.batches)
+		stats.BatchedAvgMs = float64(b.batchedTime.Microseconds()) / 1000 / float64(b.batchedItems)
+	}
+	if b.singles > 0 {
+		stats.SingleAvgMs = float64(b.singleTime.Microseconds()) / 1000 / float64(b.singles)
+	}
+	return stats
+}
+
+// 合批推理：逐条g2p后一次推理BERT与VITS，返回每条的音频与g2p错误
+// 返回的 err 非空表示整批推理失败（如模型导出时没有动态batch维），调用方应退回逐条推理
+func (m *XWX_TTS) Tts_pcm_batch(group []*ttsBatchRequest) ([][]float32, []error, error) {
+	audios := make([][]float32, len(group))
+	errs := make([]error, len(group))
+
+	type batchItem struct {
+		index   int
+		phones  []int64
+		tones   []int64
+		word2ph []int64
+		text    string
+		sid     int64
+	}
+	var items []batchItem
+	maxLen := 0
+	for i, req := range group {
+		if req.speakerID < 0 {
+			errs[i] = newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("speaker_id 不能为负数，当前为 %d", req.speakerID), nil)
+			continue
+		}
+		mix_phones, mix_tones, mix_word2ph, filteredText, err := m.G2p(req.text, req.lexicon)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		item := batchItem{
+			index:   i,
+			phones:  m.mapping_phones(mix_phones),
+			tones:   m.mapping_tones(mix_tones, m.toneOffset()),
+			word2ph: m.mapping_word2ph(mix_word2ph),
+			text:    filteredText,
+			sid:     int64(req.speakerID),
+		}
+		if len(item.phones) != len(item.tones) {
+			errs[i] = newTTSError(ErrCodeG2P, fmt.Sprintf("音素与声调数量不一致: %d != %d", len(item.phones), len(item.tones)), nil)
+			continue
+		}
+		items = append(items, item)
+		maxLen = max(maxLen, len(item.phones))
+	}
+	if len(items) == 0 {
+		return audios, errs, nil
+	}
+	speed, params := group[0].speed, group[0].params
+	if err := params.Validate(); err != nil {
+		for _, item := range items {
+			errs[item.index] = err
+		}
+		return audios, errs, nil
+	}
+	if speed <= 0 {
+		for _, item := range items {
+			errs[item.index] = newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("speed 必须大于0，当前为 %v", speed), nil)
+		}
+		return audios, errs, nil
+	}
+
+	batchSize := int64(len(items))
+	phoneLen := int64(maxLen)
+	x := make([]int64, batchSize*phoneLen)
+	tones := make([]int64, batchSize*phoneLen)
+	xLengths := make([]int64, batchSize)
+	sids := make([]int64, batchSize)
+	texts := make([]string, batchSize)
+	word2phs := make([][]int64, batchSize)
+	for i, item := range items {
+		copy(x[int64(i)*phoneLen:], item.phones)
+		copy(tones[int64(i)*phoneLen:], item.tones)
+		xLengths[i] = int64(len(item.phones))
+		sids[i] = item.sid
+		texts[i] = item.text
+		word2phs[i] = item.word2ph
+	}
+
+	startTime := time.Now()
+	var inputs []ort.Value
+	defer func() {
+		for _, input := range inputs {
+			input.Destroy()
+		}
+	}()
+	addInput := func(value ort.Value, err error) error {
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, value)
+		return nil
+	}
+
+	if err := addInput(ort.NewTensor(ort.NewShape(batchSize, phoneLen), x)); err != nil {
+		return nil, nil, fmt.Errorf("创建x张量失败: %w", err)
+	}
+	if err := addInput(ort.NewTensor(ort.NewShape(batchSize), xLengths)); err != nil {
+		return nil, nil, fmt.Errorf("创建x_lengths张量失败: %w", err)
+	}
+	if err := addInput(ort.NewTensor(ort.NewShape(batchSize, phoneLen), tones)); err != nil {
+		return nil, nil, fmt.Errorf("创建tones张量失败: %w", err)
+	}
+	if err := addInput(ort.NewTensor(ort.NewShape(batchSize), sids)); err != nil {
+		return nil, nil, fmt.Errorf("创建sid张量失败: %w", err)
+	}
+	//根据melotts逻辑，bert全0向量
+	if err := addInput(ort.NewEmptyTensor[float32](ort.NewShape(batchSize, 1024, phoneLen))); err != nil {
+		return nil, nil, fmt.Errorf("创建bert张量失败: %w", err)
+	}
+	if err := addInput(m.bertExtractor.ExtractFeaturesForTTSBatch(texts, word2phs, phoneLen)); err != nil {
+		return nil, nil, fmt.Errorf("批量提取JA-BERT特征失败: %w", err)
+	}
+	for _, scalar := range []float32{params.SdpRatio, params.NoiseScale, params.NoiseScaleW, float32(1.0 / speed)} { // sdp_ratio, noise_scale, noise_scale_w, length_scale
+		if err := addInput(ort.NewTensor(ort.NewShape(1), []float32{scalar})); err != nil {
+			return nil, nil, fmt.Errorf("创建标量张量失败: %w", err)
+		}
+	}
+
+	if m.SeedMode() == SeedModeNoiseInputs {
+		noiseTensors, err := m.newNoiseTensors(newTTSSeed(), batchSize, phoneLen, speed)
+		if err != nil {
+			return nil, nil, err
+		}
+		inputs = append(inputs, noiseTensors...)
+	}
+
+	outputs := []ort.Value{nil}
+	if m.durationOutputName != "" {
+		outputs = append(outputs, nil)
+	}
+	if err := m.session.Run(inputs, outputs); err != nil {
+		if isTTSBatchShapeError(err) {
+			m.batchUnsupported = true
+			return nil, nil, fmt.Errorf("TTS模型不支持批量推理，此引擎后续不再合批: %w", err)
+		}
+		return nil, nil, fmt.Errorf("TTS模型批量推理失败: %w", err)
+	}
+	for _, output := range outputs {
+		defer output.Destroy()
+	}
+	fmt.Printf("合批推理: batch=%d, 最长音素=%d, 耗时=%v\n", batchSize, phoneLen, time.Since(startTime))
+
+	floatTensor, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, nil, fmt.Errorf("无法转换为float32张量")
+	}
+	data := floatTensor.GetData()
+	if len(data)%int(batchSize) != 0 {
+		return nil, nil, fmt.Errorf("批量输出长度 %d 不能被 batch=%d 整除", len(data), batchSize)
+	}
+	samplesPerItem := len(data) / int(batchSize)
+	lengths, err := batchAudioLengths(outputs, int(batchSize), samplesPerItem)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, item := range items {
+		offset := i * samplesPerItem
+		audios[item.index] = append([]float32(nil), data[offset:offset+lengths[i]]...)
+	}
+	return audios, errs, nil
+}
+
+// 按时长输出计算批内每条音频的有效采样数：每条的总帧数换算为采样数（补齐位置时长为0），
+// 与逐条推理的输出长度一致
+func batchAudioLengths(outputs []ort.Value, batchSize int, samplesPerItem int) ([]int, error) {
+	if len(outputs) < 2 {
+		return nil, fmt.Errorf("模型没有时长输出，无法切分批量音频")
+	}
+	frames, ok := batchDurationFrames(outputs[1], batchSize)
+	if !ok {
+		return nil, fmt.Errorf("无法读取批量时长输出")
+	}
+	maxFrames := 0.0
+	for _, f := range frames {
+		maxFrames = math.Max(maxFrames, f)
+	}
+	if maxFrames <= 0 {
+		return nil, fmt.Errorf("批量时长输出全为0")
+	}
+	samplesPerFrame := float64(samplesPerItem) / maxFrames
+	lengths := make([]int, batchSize)
+	for i, f := range frames {
+		lengths[i] = min(samplesPerItem, int(math.Round(f*samplesPerFrame)))
+	}
+	return lengths, nil
+}
+
+// 每条的总帧数：w_ceil [B,1,T_x] 或 attn [B,1,T_y,T_x] 按条求和即得
+func batchDurationFrames(value ort.Value, batchSize int) ([]float64, bool) {
+	var data []float64
+	switch tensor := value.(type) {
+	case *ort.Tensor[float32]:
+		for _, v := range tensor.GetData() {
+			data = append(data, float64(v))
+		}
+	case *ort.Tensor[int64]:
+		for _, v := range tensor.GetData() {
+			data = append(data, float64(v))
+		}
+	default:
+		return nil, false
+	}
+	if len(data) == 0 || len(data)%batchSize != 0 {
+		return nil, false
+	}
+	per := len(data) / batchSize
+	frames := make([]float64, batchSize)
+	for i, v := range data {
+		frames[i/per] += v
+	}
+	return frames, true
+}
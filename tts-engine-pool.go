@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TTS引擎池：每个 language-device_type 组合最多 ttsEnginePoolSize 个引擎（各自独立的ONNX会话与BERT），
+// 同一时刻一个引擎只服务一个请求。引擎都忙时请求排队，队列满返回429，排队超时返回503
+//
+// 通过环境变量配置：
+//
+//	TTS_POOL_SIZE         每个组合的引擎数，默认1
+//	TTS_MAX_QUEUE         每个组合的最大排队请求数，默认32
+//	TTS_QUEUE_TIMEOUT_MS  排队超时毫秒数，默认30000
+var (
+	ttsEnginePoolSize     = 1
+	ttsEngineMaxQueue     = 32
+	ttsEngineQueueTimeout = 30 * time.Second
+)
+
+func init() {
+	if n, err := strconv.Atoi(os.Getenv("TTS_POOL_SIZE")); err == nil && n > 0 {
+		ttsEnginePoolSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("TTS_MAX_QUEUE")); err == nil && n >= 0 {
+		ttsEngineMaxQueue = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("TTS_QUEUE_TIMEOUT_MS")); err == nil && n > 0 {
+		ttsEngineQueueTimeout = time.Duration(n) * time.Millisecond
+	}
+}
+
+// 单个 language-device_type 组合的引擎池
+type TTSEnginePool struct {
+	language   Language
+	deviceType DeviceType
+	size       int
+	maxQueue   int
+	timeout    time.Duration
+
+	idle     chan *XWX_TTS // 空闲引擎，容量为 size
+	batcher  *TTSBatcher   // 开启合批时非空
+	seedMode string        // 同一池内引擎的模型相同，seed 实现方式也相同
+
+	mutex     sync.Mutex
+	created   int // 已创建（含创建中）的引擎数
+	inUse     int
+	waiting   int
+	served    int64
+	rejected  int64
+	timeouts  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// 引擎池状态，/health 返回
+type TTSEnginePoolStats struct {
+	Language   Language       `json:"language"`
+	DeviceType DeviceType     `json:"device_type"`
+	Size       int            `json:"size"`
+	Created    int            `json:"created"`
+	InUse      int            `json:"in_use"`
+	Idle       int            `json:"idle"`
+	Waiting    int            `json:"queue_depth"`
+	MaxQueue   int            `json:"max_queue"`
+	Served     int64          `json:"served"`
+	Rejected   int64          `json:"rejected"`
+	Timeouts   int64          `json:"timeouts"`
+	AvgWaitMs  float64        `json:"avg_wait_ms"`
+	MaxWaitMs  float64        `json:"max_wait_ms"`
+	Batch      *TTSBatchStats `json:"batch,omitempty"`
+}
+
+// 创建引擎池，先创建一个引擎以便尽早发现模型或语言配置错误，其余引擎按需创建
+func NewTTSEnginePool(language Language, deviceType DeviceType, size int, maxQueue int, timeout time.Duration) (*TTSEnginePool, error) {
+	engine, err := NewXWX_TTS(language, deviceType)
+	if err != nil {
+		return nil, err
+	}
+	p := &TTSEnginePool{
+		language:   language,
+		deviceType: deviceType,
+		size:       size,
+		maxQueue:   maxQueue,
+		timeout:    timeout,
+		idle:       make(chan *XWX_TTS, size),
+		created:    1,
+		seedMode:   engine.SeedMode(),
+	}
+	p.idle <- engine
+	if ttsBatchMaxSize > 1 {
+		p.batcher = NewTTSBatcher(p, ttsBatchMaxSize, ttsBatchMaxWait, maxQueue)
+	}
+	return p, nil
+}
+
+// Synthesize 合成一段文本：开启合批时交给合批调度器，否则取一个引擎直接推理
+// 可能超过分块上限的长文本不参与合批，分块后由池内空闲引擎并行推理
+func (p *TTSEnginePool) Synthesize(ctx context.Context, text string, lexicon *UserLexicon, speakerID int, speed float32, params *TTSInferenceParams) ([]float32, error) {
+	if ttsTextMayNeedChunking(text) {
+		engine, err := p.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer p.Release(engine)
+		chunks, err := engine.planChunks(text, lexicon)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 1 {
+			return engine.Tts_phones(chunks[0].phones, chunks[0].tones, chunks[0].word2ph, chunks[0].text, speakerID, speed, params)
+		}
+		return p.synthesizeChunks(ctx, engine, chunks, speakerID, speed, params)
+	}
+	// 指定了 seed 的请求需要独立的噪声，不参与合批
+	if p.batcher != nil && (params == nil || params.Seed == nil) {
+		// 合批按超参数分组，这里先补全默认值
+		if params == nil {
+			model, err := lookupTTSModel(p.language)
+			if err != nil {
+				return nil, err
+			}
+			params = &model.Inference
+		}
+		return p.batcher.Synthesize(ctx, text, lexicon, speakerID, speed, *params)
+	}
+	engine, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Release(engine)
+	return engine.Tts_pcm(text, lexicon, speakerID, speed, params)
+}
+
+// 池内引擎实现 seed 的方式
+func (p *TTSEnginePool) SeedMode() string {
+	return p.seedMode
+}
+
+// Acquire 取一个空闲引擎，用完必须调用 Release 归还
+func (p *TTSEnginePool) Acquire(ctx context.Context) (*XWX_TTS, error) {
+	start := time.Now()
+	select {
+	case engine := <-p.idle:
+		p.recordAcquire(start)
+		return engine, nil
+	default:
+	}
+
+	p.mutex.Lock()
+	// 还没创建满时直接新建，不排队
+	if p.created < p.size {
+		p.created++
+		p.mutex.Unlock()
+		fmt.Printf("引擎池 %s-%s 新建第 %d 个引擎\n", p.language, p.deviceType, p.created)
+		engine, err := NewXWX_TTS(p.language, p.deviceType)
+		if err != nil {
+			p.mutex.Lock()
+			p.created--
+			p.mutex.Unlock()
+			return nil, err
+		}
+		p.recordAcquire(start)
+		return engine, nil
+	}
+	if p.waiting >= p.maxQueue {
+		p.rejected++
+		p.mutex.Unlock()
+		return nil, newTTSError(ErrCodeServerBusy, fmt.Sprintf("服务繁忙，排队请求已达上限 %d", p.maxQueue), nil)
+	}
+	p.waiting++
+	p.mutex.Unlock()
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	select {
+	case engine := <-p.idle:
+		p.mutex.Lock()
+		p.waiting--
+		p.mutex.Unlock()
+		p.recordAcquire(start)
+		return engine, nil
+	case <-timer.C:
+		p.mutex.Lock()
+		p.waiting--
+		p.timeouts++
+		p.mutex.Unlock()
+		return nil, newTTSError(ErrCodeQueueTimeout, fmt.Sprintf("排队超时 %v", p.timeout), nil)
+	case <-ctx.Done():
+		p.mutex.Lock()
+		p.waiting--
+		p.mutex.Unlock()
+		return nil, newTTSError(ErrCodeQueueTimeout, "排队期间请求已取消", ctx.Err())
+	}
+}
+
+// 不排队也不新建，只取当前空闲的引擎，没有时返回 nil
+func (p *TTSEnginePool) tryAcquire() *XWX_TTS {
+	select {
+	case engine := <-p.idle:
+		p.recordAcquire(time.Now())
+		return engine
+	default:
+		return nil
+	}
+}
+
+// Release 归还引擎
+func (p *TTSEnginePool) Release(engine *XWX_TTS) {
+	p.mutex.Lock()
+	p.inUse--
+	p.mutex.Unlock()
+	p.idle <- engine
+}
+
+func (p *TTSEnginePool) recordAcquire(start time.Time) {
+	wait := time.Since(start)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.inUse++
+	p.served++
+	p.totalWait += wait
+	if wait > p.maxWait {
+		p.maxWait = wait
+	}
+}
+
+func (p *TTSEnginePool) Stats() TTSEnginePoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stats := TTSEnginePoolStats{
+		Language:   p.language,
+		DeviceType: p.deviceType,
+		Size:       p.size,
+		Created:    p.created,
+		InUse:      p.inUse,
+		Idle:       len(p.idle),
+		Waiting:    p.waiting,
+		MaxQueue:   p.maxQueue,
+		Served:     p.served,
+		Rejected:   p.rejected,
+		Timeouts:   p.timeouts,
+		MaxWaitMs:  float64(p.maxWait.Microseconds()) / 1000,
+	}
+	if p.served > 0 {
+		stats.AvgWaitMs = float64(p.totalWait.Microseconds()) / 1000 / float64(p.served)
+	}
+	if p.batcher != nil {
+		batchStats := p.batcher.Stats()
+		stats.Batch = &batchStats
+	}
+	return stats
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 合成流程错误码，HTTP接口原样返回给调用方，便于程序判断
+type TTSErrorCode string
+
+const (
+	ErrCodeInvalidRequest      TTSErrorCode = "invalid_request"       // 请求参数不合法
+	ErrCodeUnsupportedLanguage TTSErrorCode = "unsupported_language"  // 不支持的语言
+	ErrCodeModelNotFound       TTSErrorCode = "model_not_found"       // 模型或资源文件不存在
+	ErrCodeModelLoad           TTSErrorCode = "model_load_failed"     // 模型或资源加载失败
+	ErrCodeG2P                 TTSErrorCode = "g2p_failed"            // 文本转音素失败
+	ErrCodeCantoneseService    TTSErrorCode = "cantonese_unavailable" // 粤语拼音服务不可用
+	ErrCodeBERT                TTSErrorCode = "bert_failed"           // BERT特征提取失败
+	ErrCodeInference           TTSErrorCode = "inference_failed"      // TTS模型推理失败
+	ErrCodeNotFound            TTSErrorCode = "not_found"             // 查询的资源不存在，如词典条目
+	ErrCodeLexiconSave         TTSErrorCode = "lexicon_save_failed"   // 用户词典写回文件失败
+	ErrCodeServerBusy          TTSErrorCode = "server_busy"           // 排队请求已满
+	ErrCodeQueueTimeout        TTSErrorCode = "queue_timeout"         // 排队等待引擎超时
+)
+
+// 合成流程的类型化错误
+type TTSError struct {
+	Code    TTSErrorCode
+	Message string
+	Err     error // 底层错误，可为nil
+}
+
+func (e *TTSError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *TTSError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus 错误码对应的HTTP状态码
+func (e *TTSError) HTTPStatus() int {
+	switch e.Code {
+	case ErrCodeInvalidRequest, ErrCodeUnsupportedLanguage:
+		return http.StatusBadRequest
+	case ErrCodeNotFound:
+		return http.StatusNotFound
+	case ErrCodeG2P:
+		return http.StatusUnprocessableEntity
+	case ErrCodeServerBusy:
+		return http.StatusTooManyRequests
+	case ErrCodeCantoneseService, ErrCodeQueueTimeout:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newTTSError(code TTSErrorCode, message string, err error) *TTSError {
+	return &TTSError{Code: code, Message: message, Err: err}
+}
+
+// asTTSError 把任意错误转换为 TTSError，未分类的错误按推理失败处理
+func asTTSError(err error) *TTSError {
+	var ttsErr *TTSError
+	if errors.As(err, &ttsErr) {
+		return ttsErr
+	}
+	return newTTSError(ErrCodeInference, "合成失败", err)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// FLAC 编码器：16位单声道，固定块长，每帧在 CONSTANT、VERBATIM 与 0~4 阶 FIXED 预测中取最短的子帧，
+// 残差用分区 Rice 编码。不做 LPC，压缩率略低于 flac 命令行工具的默认档位，但解码结果逐样本一致
+
+const (
+	flacBlockSize        = 4096
+	flacBitsPerSample    = 16
+	flacMaxFixedOrder    = 4
+	flacMaxPartitionBits = 8
+	flacMaxRiceParam     = 14 // 4位 Rice 参数，15 保留为转义
+)
+
+// FLAC 帧头中可直接编码的采样率，其余采样率从 STREAMINFO 读取
+var flacSampleRateCodes = map[int]uint64{
+	88200: 1, 176400: 2, 192000: 3, 8000: 4, 16000: 5, 22050: 6,
+	24000: 7, 32000: 8, 44100: 9, 48000: 10, 96000: 11,
+}
+
+type flacEncoder struct{}
+
+func (e *flacEncoder) Name() string        { return "flac" }
+func (e *flacEncoder) ContentType() string { return "audio/flac" }
+func (e *flacEncoder) Extension() string   { return "flac" }
+
+func (e *flacEncoder) Encode(w io.Writer, pcmData []float32, sampleRate int) error {
+	if sampleRate <= 0 || sampleRate >= 1<<20 {
+		return fmt.Errorf("FLAC 不支持采样率 %d", sampleRate)
+	}
+
+	samples := make([]int32, len(pcmData))
+	digest := md5.New()
+	raw := make([]byte, 0, len(pcmData)*2)
+	for i, sample := range pcmData {
+		value := sampleToInt16(sample)
+		samples[i] = int32(value)
+		raw = appendUint16(raw, uint16(value))
+	}
+	digest.Write(raw)
+
+	// 逐帧编码，同时统计帧长的最小值与最大值写入 STREAMINFO
+	var frames []byte
+	minFrameSize, maxFrameSize := 0, 0
+	for frameNumber, offset := 0, 0; offset < len(samples); frameNumber, offset = frameNumber+1, offset+flacBlockSize {
+		end := offset + flacBlockSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		frame := encodeFLACFrame(samples[offset:end], uint64(frameNumber), sampleRate)
+		if minFrameSize == 0 || len(frame) < minFrameSize {
+			minFrameSize = len(frame)
+		}
+		if len(frame) > maxFrameSize {
+			maxFrameSize = len(frame)
+		}
+		frames = append(frames, frame...)
+	}
+
+	header := &flacBitWriter{}
+	header.data = append(header.data, "fLaC"...)
+	header.writeBits(1, 1)   // 最后一个元数据块
+	header.writeBits(0, 7)   // STREAMINFO
+	header.writeBits(34, 24) // 块长度
+	header.writeBits(flacBlockSize, 16)
+	header.writeBits(flacBlockSize, 16)
+	header.writeBits(uint64(minFrameSize), 24)
+	header.writeBits(uint64(maxFrameSize), 24)
+	header.writeBits(uint64(sampleRate), 20)
+	header.writeBits(0, 3) // 声道数-1
+	header.writeBits(flacBitsPerSample-1, 5)
+	header.writeBits(uint64(len(samples)), 36)
+	header.data = append(header.data, digest.Sum(nil)...)
+
+	if _, err := w.Write(header.data); err != nil {
+		return err
+	}
+	_, err := w.Write(frames)
+	return err
+}
+
+// 编码一帧：帧头、单个子帧、CRC-16
+func encodeFLACFrame(samples []int32, frameNumber uint64, sampleRate int) []byte {
+	frame := &flacBitWriter{}
+	frame.writeBits(0xFFF8, 16) // 同步码，固定块长
+	frame.writeBits(7, 4)       // 块长在帧头末尾以16位给出
+	frame.writeBits(flacSampleRateCodes[sampleRate], 4)
+	frame.writeBits(0, 4) // 单声道
+	frame.writeBits(4, 3) // 16位
+	frame.writeBits(0, 1)
+	frame.writeUTF8(frameNumber)
+	frame.writeBits(uint64(len(samples)-1), 16)
+	frame.writeBits(uint64(flacCRC8(frame.data)), 8)
+
+	writeFLACSubframe(frame, samples)
+	frame.align()
+	crc := flacCRC16(frame.data)
+	return append(frame.data, byte(crc>>8), byte(crc))
+}
+
+// 选择编码后最短的子帧类型写入
+func writeFLACSubframe(w *flacBitWriter, samples []int32) {
+	constant := true
+	for _, sample := range samples[1:] {
+		if sample != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		w.writeBits(0, 8) // 填充位 + CONSTANT + 无 wasted bits
+		w.writeBits(uint64(uint32(samples[0])), flacBitsPerSample)
+		return
+	}
+
+	bestOrder := -1
+	bestBits := len(samples) * flacBitsPerSample // VERBATIM
+	var bestResidual []int32
+	var bestPartition flacRicePartition
+	for order := 0; order <= flacMaxFixedOrder && order < len(samples); order++ {
+		residual := flacFixedResidual(samples, order)
+		partition := chooseFLACRicePartition(residual, len(samples), order)
+		if size := order*flacBitsPerSample + partition.bits; size < bestBits {
+			bestOrder, bestBits, bestResidual, bestPartition = order, size, residual, partition
+		}
+	}
+
+	if bestOrder < 0 {
+		w.writeBits(0x02, 8) // VERBATIM
+		for _, sample := range samples {
+			w.writeBits(uint64(uint32(sample)), flacBitsPerSample)
+		}
+		return
+	}
+
+	w.writeBits(uint64(0x08|bestOrder)<<1, 8) // FIXED，阶数在低3位
+	for _, sample := range samples[:bestOrder] {
+		w.writeBits(uint64(uint32(sample)), flacBitsPerSample)
+	}
+	w.writeBits(0, 2) // 4位 Rice 参数
+	w.writeBits(uint64(bestPartition.order), 4)
+	start := 0
+	for i, param := range bestPartition.params {
+		count := len(samples) >> bestPartition.order
+		if i == 0 {
+			count -= bestOrder
+		}
+		w.writeBits(uint64(param), 4)
+		for _, r := range bestResidual[start : start+count] {
+			u := flacZigZag(r)
+			w.writeUnary(u >> param)
+			w.writeBits(u, param)
+		}
+		start += count
+	}
+}
+
+// 0~4 阶固定多项式预测的残差，不含前 order 个预热样本
+func flacFixedResidual(samples []int32, order int) []int32 {
+	residual := make([]int32, len(samples)-order)
+	for i := order; i < len(samples); i++ {
+		var predicted int32
+		switch order {
+		case 1:
+			predicted = samples[i-1]
+		case 2:
+			predicted = 2*samples[i-1] - samples[i-2]
+		case 3:
+			predicted = 3*samples[i-1] - 3*samples[i-2] + samples[i-3]
+		case 4:
+			predicted = 4*samples[i-1] - 6*samples[i-2] + 4*samples[i-3] - samples[i-4]
+		}
+		residual[i-order] = samples[i] - predicted
+	}
+	return residual
+}
+
+// Rice 分区方案
+type flacRicePartition struct {
+	order  int
+	params []uint
+	bits   int // 残差部分的总位数，含编码方式与分区阶数字段
+}
+
+// 在允许的分区阶数中选出总位数最少的方案，每个分区的 Rice 参数按残差均值估计后在相邻值中取最优
+func chooseFLACRicePartition(residual []int32, blockSize int, predictorOrder int) flacRicePartition {
+	best := flacRicePartition{bits: -1}
+	for order := 0; order <= flacMaxPartitionBits; order++ {
+		if blockSize%(1<<order) != 0 || blockSize>>order <= predictorOrder {
+			break
+		}
+		partition := flacRicePartition{order: order, bits: 6}
+		start := 0
+		for i := 0; i < 1<<order; i++ {
+			count := blockSize >> order
+			if i == 0 {
+				count -= predictorOrder
+			}
+			param, size := bestFLACRiceParam(residual[start : start+count])
+			partition.params = append(partition.params, param)
+			partition.bits += 4 + size
+			start += count
+		}
+		if best.bits < 0 || partition.bits < best.bits {
+			best = partition
+		}
+	}
+	return best
+}
+
+// 单个分区的最优 Rice 参数及编码位数
+func bestFLACRiceParam(residual []int32) (uint, int) {
+	if len(residual) == 0 {
+		return 0, 0
+	}
+	var sum uint64
+	for _, r := range residual {
+		sum += flacZigZag(r)
+	}
+	estimate := 0
+	if mean := sum / uint64(len(residual)); mean > 0 {
+		estimate = bits.Len64(mean) - 1
+	}
+	if estimate > flacMaxRiceParam {
+		estimate = flacMaxRiceParam
+	}
+
+	bestParam, bestSize := uint(0), -1
+	for param := estimate - 1; param <= estimate+1; param++ {
+		if param < 0 || param > flacMaxRiceParam {
+			continue
+		}
+		size := len(residual) * (param + 1)
+		for _, r := range residual {
+			size += int(flacZigZag(r) >> uint(param))
+		}
+		if bestSize < 0 || size < bestSize {
+			bestParam, bestSize = uint(param), size
+		}
+	}
+	return bestParam, bestSize
+}
+
+// 有符号残差折叠为无符号：0,-1,1,-2 → 0,1,2,3
+func flacZigZag(r int32) uint64 {
+	return uint64(uint32((r << 1) ^ (r >> 31)))
+}
+
+// 按位写入，高位在前
+type flacBitWriter struct {
+	data  []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *flacBitWriter) writeBits(value uint64, n uint) {
+	for n > 32 {
+		n -= 32
+		w.writeBits(value>>n, 32)
+	}
+	w.acc = w.acc<<n | value&(1<<n-1)
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.data = append(w.data, byte(w.acc>>w.nbits))
+	}
+}
+
+// 一元码：q 个0后跟一个1
+func (w *flacBitWriter) writeUnary(q uint64) {
+	for ; q >= 32; q -= 32 {
+		w.writeBits(0, 32)
+	}
+	w.writeBits(1, uint(q)+1)
+}
+
+// 帧号按 UTF-8 的方式变长编码
+func (w *flacBitWriter) writeUTF8(value uint64) {
+	if value < 0x80 {
+		w.writeBits(value, 8)
+		return
+	}
+	n := 2
+	for value >= 1<<(5*n+1) {
+		n++
+	}
+	w.writeBits(uint64(0xFF00>>n)&0xFF|value>>(6*(n-1)), 8)
+	for i := n - 2; i >= 0; i-- {
+		w.writeBits(0x80|(value>>(6*i))&0x3F, 8)
+	}
+}
+
+// 补0到字节边界
+func (w *flacBitWriter) align() {
+	if w.nbits > 0 {
+		w.writeBits(0, 8-w.nbits)
+	}
+}
+
+// 帧头校验，多项式 x^8+x^2+x+1
+func flacCRC8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// 整帧校验，多项式 x^16+x^15+x^2+1
+func flacCRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
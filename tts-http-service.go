@@ -2,9 +2,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
 	// "encoding/json"
 	"fmt"
-	// "io"
+	"io"
 	"net/http"
 	"strconv"
 	"sync"
@@ -13,8 +14,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// TTS引擎缓存，key为 language-device_type 组合
-var ttsEngineCache = make(map[string]*XWX_TTS)
+// TTS引擎池缓存，key为 language-device_type 组合
+var ttsEngineCache = make(map[string]*TTSEnginePool)
 var ttsEngineMutex sync.RWMutex // 用于保护缓存map的并发访问
 
 // API请求结构体
@@ -24,6 +25,18 @@ type TTSRequest struct {
 	SpeakerID  *int       `json:"speaker_id,omitempty"`              // 发音人ID，默认为0
 	Speed      *float32   `json:"speed,omitempty"`                   // 速度，默认为1.0
 	DeviceType *DeviceType `json:"device_type,omitempty"`            // 设备类型，默认为GPU
+	Stream     *bool       `json:"stream,omitempty"`                 // 是否按句流式返回音频，默认为false
+	SSML       *bool       `json:"ssml,omitempty"`                   // text 是否为SSML文档，默认为false
+	Lexicon    []UserLexiconEntry `json:"lexicon,omitempty"`         // 本次请求临时覆盖的发音词典
+	Timestamps *bool       `json:"timestamps,omitempty"`             // 是否返回音素、字词时间戳，为true时以JSON返回base64音频
+	SdpRatio    *float32   `json:"sdp_ratio,omitempty"`              // 随机时长预测比例 0~1，默认取模型配置
+	NoiseScale  *float32   `json:"noise_scale,omitempty"`            // 音色语调随机性 0~2，默认取模型配置
+	NoiseScaleW *float32   `json:"noise_scale_w,omitempty"`          // 时长随机性 0~2，默认取模型配置
+	Seed        *int64     `json:"seed,omitempty"`                   // 随机种子，指定后合成可复现，使用的 seed 在响应头 X-TTS-Seed 中返回
+	Format      string     `json:"format,omitempty"`                 // 音频格式 wav/wav24/wav_f32/pcm/mulaw/alaw/flac，为空时按 Accept 头协商，默认 wav
+	SampleRate  *int       `json:"sample_rate,omitempty"`            // 输出采样率 8000~192000，默认为模型采样率
+	Loudness    *float64   `json:"loudness,omitempty"`               // 目标积分响度 LUFS（-70~-5），如 -16、-23，默认不调整
+	TruePeak    *float64   `json:"true_peak,omitempty"`              // 真峰值上限 dBTP（-20~0），指定 loudness 时默认为 -1
 }
 
 // API响应结构体
@@ -34,37 +47,129 @@ type TTSResponse struct {
 	Duration string `json:"duration,omitempty"`
 }
 
-// 获取或创建TTS引擎实例
-func getOrCreateTTSEngine(language Language, deviceType DeviceType) (*XWX_TTS, error) {
+// 获取或创建TTS引擎池
+func getOrCreateTTSEnginePool(language Language, deviceType DeviceType) (*TTSEnginePool, error) {
 	key := fmt.Sprintf("%s-%s", language, deviceType)
 	
 	// 尝试从缓存中获取
 	ttsEngineMutex.RLock()
-	if engine, exists := ttsEngineCache[key]; exists {
+	if pool, exists := ttsEngineCache[key]; exists {
 		ttsEngineMutex.RUnlock()
-		return engine, nil
+		return pool, nil
 	}
 	ttsEngineMutex.RUnlock()
 	
-	// 缓存中不存在，创建新的引擎实例
+	// 缓存中不存在，创建新的引擎池
 	ttsEngineMutex.Lock()
 	defer ttsEngineMutex.Unlock()
 	
 	// 双重检查，防止并发创建
-	if engine, exists := ttsEngineCache[key]; exists {
-		return engine, nil
+	if pool, exists := ttsEngineCache[key]; exists {
+		return pool, nil
 	}
 	
-	fmt.Printf("创建新的TTS引擎实例，语言: %s, 设备: %s\n", language, deviceType)
-	newEngine, err := NewXWX_TTS(language, deviceType)
+	fmt.Printf("创建新的TTS引擎池，语言: %s, 设备: %s, 引擎数: %d\n", language, deviceType, ttsEnginePoolSize)
+	newPool, err := NewTTSEnginePool(language, deviceType, ttsEnginePoolSize, ttsEngineMaxQueue, ttsEngineQueueTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("创建TTS引擎失败: %v", err)
+		return nil, fmt.Errorf("创建TTS引擎失败: %w", err)
 	}
 	
-	ttsEngineCache[key] = newEngine
-	fmt.Printf("TTS引擎实例创建成功并已缓存，当前缓存大小: %d\n", len(ttsEngineCache))
+	ttsEngineCache[key] = newPool
+	fmt.Printf("TTS引擎池创建成功并已缓存，当前缓存大小: %d\n", len(ttsEngineCache))
 	
-	return newEngine, nil
+	return newPool, nil
+}
+
+// 将合成流程错误转换为带错误码的JSON响应
+func ttsErrorResponse(c *gin.Context, err error) {
+	ttsErr := asTTSError(err)
+	fmt.Printf("TTS请求失败: code=%s, err=%v\n", ttsErr.Code, err)
+	if status := ttsErr.HTTPStatus(); status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", "1")
+	}
+	c.JSON(ttsErr.HTTPStatus(), gin.H{
+		"success": false,
+		"code":    ttsErr.Code,
+		"message": err.Error(),
+	})
+}
+
+// 解析请求：默认为JSON；Content-Type 为 application/ssml+xml 时请求体即SSML文档，
+// 其余参数从URL查询参数读取，如 /tts?language=zh_x&speaker_id=0&speed=1.0
+func bindTTSRequest(c *gin.Context, req *TTSRequest) error {
+	if c.ContentType() != "application/ssml+xml" {
+		return c.ShouldBindJSON(req)
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return fmt.Errorf("SSML文档为空")
+	}
+	isSSML := true
+	req.Text = string(body)
+	req.SSML = &isSSML
+
+	req.Language = Language(c.Query("language"))
+	if req.Language == "" {
+		return fmt.Errorf("缺少查询参数 language")
+	}
+	if value := c.Query("speaker_id"); value != "" {
+		speakerID, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("speaker_id 格式错误: %w", err)
+		}
+		req.SpeakerID = &speakerID
+	}
+	if value := c.Query("speed"); value != "" {
+		speed, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return fmt.Errorf("speed 格式错误: %w", err)
+		}
+		speed32 := float32(speed)
+		req.Speed = &speed32
+	}
+	if value := c.Query("device_type"); value != "" {
+		deviceType := DeviceType(value)
+		req.DeviceType = &deviceType
+	}
+	req.Format = c.Query("format")
+	if value := c.Query("sample_rate"); value != "" {
+		sampleRate, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("sample_rate 格式错误: %w", err)
+		}
+		req.SampleRate = &sampleRate
+	}
+	if value := c.Query("seed"); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("seed 格式错误: %w", err)
+		}
+		req.Seed = &seed
+	}
+	for name, field := range map[string]**float64{"loudness": &req.Loudness, "true_peak": &req.TruePeak} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s 格式错误: %w", name, err)
+			}
+			*field = &parsed
+		}
+	}
+	for name, field := range map[string]**float32{"sdp_ratio": &req.SdpRatio, "noise_scale": &req.NoiseScale, "noise_scale_w": &req.NoiseScaleW} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return fmt.Errorf("%s 格式错误: %w", name, err)
+			}
+			value32 := float32(parsed)
+			*field = &value32
+		}
+	}
+	return nil
 }
 
 // TTS API处理器
@@ -73,11 +178,29 @@ func ttsHandler(c *gin.Context) {
 
 	// 解析请求体
 	var req TTSRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "请求参数错误: " + err.Error(),
-		})
+	if err := bindTTSRequest(c, &req); err != nil {
+		ttsErrorResponse(c, newTTSError(ErrCodeInvalidRequest, "请求参数错误", err))
+		return
+	}
+	isSSML := req.SSML != nil && *req.SSML
+	withTimestamps := req.Timestamps != nil && *req.Timestamps
+
+	// 输出格式：format 字段优先，其次 Accept 头
+	encoder, err := negotiateAudioEncoder(req.Format, c.GetHeader("Accept"))
+	if err != nil {
+		ttsErrorResponse(c, err)
+		return
+	}
+	// 模型按自身采样率输出，需要其他采样率时在编码前转换
+	modelSampleRate := ttsModelSampleRate(req.Language)
+	sampleRate, err := resolveOutputSampleRate(req.SampleRate, modelSampleRate)
+	if err != nil {
+		ttsErrorResponse(c, err)
+		return
+	}
+	loudness := TTSLoudnessOptions{TargetLUFS: req.Loudness, TruePeak: req.TruePeak}
+	if err := loudness.Validate(); err != nil {
+		ttsErrorResponse(c, err)
 		return
 	}
 
@@ -97,142 +220,190 @@ func ttsHandler(c *gin.Context) {
 		deviceType = *req.DeviceType
 	}
 
-	
-
-	// 获取或创建TTS引擎实例
-	ttsEngine, err := getOrCreateTTSEngine(req.Language, deviceType)
+	// 推理超参数：模型默认值被请求覆盖，实际使用的值在响应头中回显
+	params, err := resolveTTSInferenceParams(req.Language, req.SdpRatio, req.NoiseScale, req.NoiseScaleW)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
+		ttsErrorResponse(c, err)
 		return
 	}
-
-	// 执行TTS转换
-	audioData := ttsEngine.Tts_pcm(req.Text, speakerID, speed)
-
-	// 计算音频时长
-	sampleRate := 24000
-	audioDuration := float64(len(audioData)) / float64(sampleRate)
-
-	// 将PCM数据转换为WAV格式
-	wavBuffer := &bytes.Buffer{}
-	err = writeWAVToBuffer(audioData, wavBuffer, sampleRate)
+	pool, err := getOrCreateTTSEnginePool(req.Language, deviceType)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "生成WAV音频失败: " + err.Error(),
-		})
+		ttsErrorResponse(c, err)
 		return
 	}
+	// 模型支持噪声输入时，没有指定 seed 也随机生成一个并返回，便于之后复现这一次的结果
+	params.Seed = req.Seed
+	if params.Seed == nil && pool.SeedMode() == SeedModeNoiseInputs {
+		seed := newTTSSeed()
+		params.Seed = &seed
+	}
+	setTTSInferenceHeaders(c, params, pool.SeedMode())
 
-	// 计算处理时间
-	duration := time.Since(startTime)
-
-	// 设置响应头
-	c.Header("Content-Type", "audio/wav")
-	c.Header("Content-Disposition", "attachment; filename=\"tts_output.wav\"")
-	c.Header("Content-Length", strconv.Itoa(wavBuffer.Len()))
-	
-	// 返回WAV音频流
-	c.Data(http.StatusOK, "audio/wav", wavBuffer.Bytes())
+	// 请求中的词条覆盖全局用户词典
+	lexicon, err := userLexicon.WithOverrides(req.Lexicon)
+	if err != nil {
+		ttsErrorResponse(c, err)
+		return
+	}
 
-	// 记录日志
-	fmt.Printf("TTS API调用成功: 文本长度=%d, 语言=%s, 发音人=%d, 速度=%.2f, 设备=%s, 音频大小=%d bytes, 音频时长=%.2f秒, 耗时=%v\n", 
-		len(req.Text), req.Language, speakerID, speed, deviceType, wavBuffer.Len(), audioDuration, duration)
-}
+	if withTimestamps && (isSSML || (req.Stream != nil && *req.Stream)) {
+		ttsErrorResponse(c, newTTSError(ErrCodeInvalidRequest, "timestamps 暂不支持与 ssml 或 stream 同时使用", nil))
+		return
+	}
 
-// 将PCM数据写入WAV格式的buffer
-func writeWAVToBuffer(pcmData []float32, buffer *bytes.Buffer, sampleRate int) error {
-	// WAV文件参数
-	const (
-		bitsPerSample = 16
-		numChannels   = 1
-	)
-
-	dataSize := uint32(len(pcmData) * bitsPerSample / 8 * numChannels)
-	fileSize := dataSize + 36 // 文件大小 = 数据大小 + 头大小(44) - "RIFF"标识(4) - 文件大小字段(4)
-
-	// 写入RIFF头
-	buffer.WriteString("RIFF")
-	writeUint32(buffer, fileSize)
-	buffer.WriteString("WAVE")
-
-	// 写入fmt chunk
-	buffer.WriteString("fmt ")
-	writeUint32(buffer, 16) // chunk size
-	writeUint16(buffer, 1)  // PCM格式
-	writeUint16(buffer, uint16(numChannels))
-	writeUint32(buffer, uint32(sampleRate))
-	writeUint32(buffer, uint32(sampleRate*bitsPerSample/8*numChannels)) // 字节率
-	writeUint16(buffer, uint16(bitsPerSample/8*numChannels))            // 块对齐
-	writeUint16(buffer, uint16(bitsPerSample))
-
-	// 写入data chunk头
-	buffer.WriteString("data")
-	writeUint32(buffer, dataSize)
-
-	// 将float32数据转换为16位PCM并写入
-	for _, sample := range pcmData {
-		// 确保数据范围在 [-1, 1] 之间
-		if sample > 1.0 {
-			sample = 1.0
-		} else if sample < -1.0 {
-			sample = -1.0
+	// 流式模式：逐句合成并分块返回
+	if req.Stream != nil && *req.Stream {
+		if isSSML {
+			ttsErrorResponse(c, newTTSError(ErrCodeInvalidRequest, "SSML 暂不支持流式返回", nil))
+			return
+		}
+		streamEncoder, ok := encoder.(StreamingAudioEncoder)
+		if !ok {
+			ttsErrorResponse(c, newTTSError(ErrCodeInvalidRequest, encoder.Name()+" 格式不支持流式返回", nil))
+			return
 		}
+		// 从引擎池获取TTS引擎实例，引擎都忙时排队
+		ttsEngine, err := pool.Acquire(c.Request.Context())
+		if err != nil {
+			ttsErrorResponse(c, err)
+			return
+		}
+		defer pool.Release(ttsEngine)
+		ttsStreamHandler(c, ttsEngine, streamEncoder, sampleRate, loudness, req.Text, lexicon, speakerID, speed, params)
+		return
+	}
 
-		// 转换为16位PCM
-		pcmValue := int16(sample * 32767)
+	// 非流式、非时间戳的结果经音频缓存，seed 只有请求显式指定时才参与 key
+	cacheKey := ""
+	if ttsAudioCache != nil && !withTimestamps {
+		cacheKey, err = ttsAudioCacheKey(req.Text, req.Language, isSSML, speakerID, speed, params, req.Seed, req.Lexicon)
+		if err != nil {
+			ttsErrorResponse(c, err)
+			return
+		}
+	}
 
-		// 写入小端格式
-		err := writeUint16(buffer, uint16(pcmValue))
+	// 执行TTS转换；普通文本经引擎池合成，开启合批时可与并发请求合并推理
+	var audioData []float32
+	var alignment *TTSAlignment
+	cached := lookupTTSAudioCache(c, cacheKey)
+	if cached != nil {
+		audioData = cached.PCM
+	} else if isSSML || withTimestamps {
+		ttsEngine, err := pool.Acquire(c.Request.Context())
+		if err != nil {
+			ttsErrorResponse(c, err)
+			return
+		}
+		defer pool.Release(ttsEngine)
+		if isSSML {
+			audioData, err = ttsEngine.Tts_ssml(req.Text, lexicon, speakerID, speed, params)
+		} else {
+			audioData, alignment, err = ttsEngine.Tts_pcm_timestamps(req.Text, lexicon, speakerID, speed, params)
+		}
+		if err != nil {
+			ttsErrorResponse(c, err)
+			return
+		}
+	} else {
+		audioData, err = pool.Synthesize(c.Request.Context(), req.Text, lexicon, speakerID, speed, params)
 		if err != nil {
-			return err
+			ttsErrorResponse(c, err)
+			return
 		}
 	}
 
-	return nil
-}
+	// 缓存模型采样率的原始PCM，不同 sample_rate 的请求共用一条缓存
+	if cacheKey != "" && cached == nil {
+		ttsAudioCache.Put(cacheKey, &TTSAudioCacheEntry{PCM: audioData, SampleRate: modelSampleRate, Language: req.Language, Seed: params.Seed})
+	}
+	audioData, err = resampleToOutputRate(audioData, modelSampleRate, sampleRate)
+	if err != nil {
+		ttsErrorResponse(c, err)
+		return
+	}
+	// 响度归一化与限幅，测量结果在响应头中返回
+	audioData, loudnessReport := processLoudness(audioData, sampleRate, loudness)
+	setTTSLoudnessHeaders(c, loudnessReport)
 
-// 辅助函数：写入32位整数（小端格式）
-func writeUint32(buffer *bytes.Buffer, value uint32) error {
-	return writeUint(buffer, value, 4)
-}
+	// 计算音频时长
+	audioDuration := float64(len(audioData)) / float64(sampleRate)
 
-// 辅助函数：写入16位整数（小端格式）
-func writeUint16(buffer *bytes.Buffer, value uint16) error {
-	return writeUint(buffer, uint32(value), 2)
-}
+	// 将PCM数据编码为请求的音频格式
+	encoded, err := encodeAudio(encoder, audioData, sampleRate)
+	if err != nil {
+		ttsErrorResponse(c, newTTSError(ErrCodeInference, "生成音频失败", err))
+		return
+	}
 
-// 辅助函数：写入指定字节数的整数（小端格式）
-func writeUint(buffer *bytes.Buffer, value uint32, size int) error {
-	for i := 0; i < size; i++ {
-		buffer.WriteByte(byte(value))
-		value >>= 8
+	// 计算处理时间
+	duration := time.Since(startTime)
+
+	// 时间戳模式：音频以base64与时间戳一起放在JSON中返回
+	if withTimestamps {
+		c.JSON(http.StatusOK, gin.H{
+			"success":      true,
+			"audio":        base64.StdEncoding.EncodeToString(encoded),
+			"audio_format": encoder.Name(),
+			"sample_rate":  sampleRate,
+			"duration":     audioDuration,
+			"alignment":    alignment,
+			"loudness":     loudnessReport,
+		})
+		fmt.Printf("TTS API调用成功(时间戳): 文本长度=%d, 语言=%s, 时间戳来源=%s, 音素数=%d, 音频时长=%.2f秒, 耗时=%v\n",
+			len(req.Text), req.Language, alignment.Source, len(alignment.Phones), audioDuration, duration)
+		return
 	}
-	return nil
+
+	// 设置响应头；裸PCM与G.711没有文件头，采样率放在 X-TTS-Sample-Rate 中
+	c.Header("Content-Disposition", "attachment; filename=\"tts_output."+encoder.Extension()+"\"")
+	c.Header("Content-Length", strconv.Itoa(len(encoded)))
+	c.Header("X-TTS-Sample-Rate", strconv.Itoa(sampleRate))
+	
+	// 返回音频流
+	c.Data(http.StatusOK, encoder.ContentType(), encoded)
+
+	// 记录日志
+	fmt.Printf("TTS API调用成功: 文本长度=%d, 语言=%s, 发音人=%d, 速度=%.2f, 设备=%s, 格式=%s, 音频大小=%d bytes, 音频时长=%.2f秒, 耗时=%v\n", 
+		len(req.Text), req.Language, speakerID, speed, deviceType, encoder.Name(), len(encoded), audioDuration, duration)
 }
 
-// 健康检查API
+// 健康检查API，返回各引擎池的占用、排队深度与等待时间
 func healthHandler(c *gin.Context) {
+	ttsEngineMutex.RLock()
+	pools := make([]TTSEnginePoolStats, 0, len(ttsEngineCache))
+	for _, pool := range ttsEngineCache {
+		pools = append(pools, pool.Stats())
+	}
+	ttsEngineMutex.RUnlock()
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "TTS服务运行正常",
 		"timestamp": time.Now().Unix(),
-		"engine_cache_size": len(ttsEngineCache),
+		"engine_cache_size": len(pools),
+		"engine_pools": pools,
 	})
 }
 
-// 获取支持的语言列表
+// 获取支持的语言列表：languages 只含模型文件齐全的语言，models 列出注册表中全部模型及发音人
 func languagesHandler(c *gin.Context) {
+	models, err := listTTSModels()
+	if err != nil {
+		ttsErrorResponse(c, err)
+		return
+	}
+	languages := []string{}
+	for _, model := range models {
+		if model.Installed {
+			languages = append(languages, string(model.Language))
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"languages": []string{
-			string(ZH_X),   // 中文+英语
-			string(YUE_EN), // 粤语+英语
-		},
-		"message": "支持的语言列表",
+		"success":   true,
+		"languages": languages,
+		"models":    models,
+		"message":   "支持的语言列表",
 	})
 }
 
@@ -242,14 +413,20 @@ func StartTTSHTTPService(port string) {
 	// 设置Gin为生产模式
 	gin.SetMode(gin.ReleaseMode)
 
+	// 加载用户发音词典
+	loadUserLexiconOnStartup()
+	// 初始化音频缓存
+	initTTSAudioCacheOnStartup()
+
 	// 创建Gin路由器
 	r := gin.Default()
 
 	// 添加CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+		c.Header("Access-Control-Allow-Methods", "POST, GET, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Expose-Headers", "X-TTS-SDP-Ratio, X-TTS-Noise-Scale, X-TTS-Noise-Scale-W, X-TTS-Seed, X-TTS-Seed-Mode, X-TTS-Sample-Rate, X-TTS-Loudness-Input, X-TTS-Loudness, X-TTS-True-Peak, X-TTS-Gain, X-TTS-Limited, X-Cache, X-Cache-Key")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -258,8 +435,12 @@ func StartTTSHTTPService(port string) {
 
 	// API路由
 	r.POST("/tts", ttsHandler)                    // TTS转换API
+	r.GET("/tts/ws", gin.WrapH(newTTSWebSocketHandler())) // WebSocket增量合成
+	r.POST("/v1/audio/speech", openAISpeechHandler) // OpenAI兼容接口
 	r.GET("/health", healthHandler)               // 健康检查
 	r.GET("/languages", languagesHandler)         // 支持的语言列表
+	registerLexiconRoutes(r)                      // 用户发音词典管理
+	registerCacheRoutes(r)                        // 音频缓存统计与清除
 	
 	fmt.Printf("TTS HTTP服务启动中，监听端口: %s\n", port)
 	
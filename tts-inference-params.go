@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VITS 推理超参数：
+// sdp_ratio 为随机时长预测器与确定性时长预测器的混合比例，越大节奏越多变；
+// noise_scale 控制音色与语调的随机性（表现力）；noise_scale_w 控制时长的随机性（停顿与语速起伏）
+// 有声书可适当调大，IVR 提示音调小更稳定
+// Seed 非空时合成可复现，见 tts-seed.go
+type TTSInferenceParams struct {
+	SdpRatio    float32 `json:"sdp_ratio"`
+	NoiseScale  float32 `json:"noise_scale"`
+	NoiseScaleW float32 `json:"noise_scale_w"`
+	Seed        *int64  `json:"seed,omitempty"`
+}
+
+// 未在模型配置中指定时的默认值
+var defaultTTSInferenceParams = TTSInferenceParams{
+	SdpRatio:    0.5,
+	NoiseScale:  0.6,
+	NoiseScaleW: 0.9,
+}
+
+// 取值范围
+const (
+	maxTTSNoiseScale  = 2.0
+	maxTTSNoiseScaleW = 2.0
+)
+
+// 校验取值范围：sdp_ratio 0~1，noise_scale 与 noise_scale_w 0~2
+func (p TTSInferenceParams) Validate() error {
+	if p.SdpRatio < 0 || p.SdpRatio > 1 {
+		return newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("sdp_ratio 取值范围为 0~1，当前为 %v", p.SdpRatio), nil)
+	}
+	if p.NoiseScale < 0 || p.NoiseScale > maxTTSNoiseScale {
+		return newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("noise_scale 取值范围为 0~%v，当前为 %v", maxTTSNoiseScale, p.NoiseScale), nil)
+	}
+	if p.NoiseScaleW < 0 || p.NoiseScaleW > maxTTSNoiseScaleW {
+		return newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("noise_scale_w 取值范围为 0~%v，当前为 %v", maxTTSNoiseScaleW, p.NoiseScaleW), nil)
+	}
+	return nil
+}
+
+// 以模型默认值为基础，用请求中给出的值覆盖并校验
+func resolveTTSInferenceParams(language Language, sdpRatio, noiseScale, noiseScaleW *float32) (*TTSInferenceParams, error) {
+	model, err := lookupTTSModel(language)
+	if err != nil {
+		return nil, err
+	}
+	params := model.Inference
+	if sdpRatio != nil {
+		params.SdpRatio = *sdpRatio
+	}
+	if noiseScale != nil {
+		params.NoiseScale = *noiseScale
+	}
+	if noiseScaleW != nil {
+		params.NoiseScaleW = *noiseScaleW
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
+
+// 在响应头中回显实际使用的超参数，使用了 seed 时一并返回 seed 与实现方式
+func setTTSInferenceHeaders(c *gin.Context, params *TTSInferenceParams, seedMode string) {
+	params = seededInferenceParams(params, seedMode)
+	c.Header("X-TTS-SDP-Ratio", strconv.FormatFloat(float64(params.SdpRatio), 'f', -1, 32))
+	c.Header("X-TTS-Noise-Scale", strconv.FormatFloat(float64(params.NoiseScale), 'f', -1, 32))
+	c.Header("X-TTS-Noise-Scale-W", strconv.FormatFloat(float64(params.NoiseScaleW), 'f', -1, 32))
+	if params.Seed != nil {
+		c.Header("X-TTS-Seed", strconv.FormatInt(*params.Seed, 10))
+		c.Header("X-TTS-Seed-Mode", seedMode)
+	}
+}
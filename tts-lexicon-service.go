@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 用户发音词典管理接口，修改后立即生效并写回词典文件
+//   GET    /lexicon                列出全部条目
+//   GET    /lexicon/:word          查询一个词的读音
+//   POST   /lexicon                新增或替换条目 {"word":"重庆","alphabet":"pinyin","ph":"chong2 qing4"}
+//   PUT    /lexicon/:word          替换该词的读音 {"alphabet":"pinyin","ph":"chong2 qing4"}
+//   DELETE /lexicon/:word          删除该词，?alphabet=pinyin 时只删除对应读音
+
+// 保证修改与写文件的顺序一致
+var userLexiconSaveMutex sync.Mutex
+
+// 启动时加载用户词典，失败时保留空词典继续运行
+func loadUserLexiconOnStartup() {
+	lexicon, err := LoadUserLexicon(userLexiconPaths)
+	if err != nil {
+		fmt.Printf("加载用户词典失败: %v\n", err)
+		return
+	}
+	userLexicon = lexicon
+}
+
+// 词典变化后旧读音的缓存条目不会再命中（缓存 key 含词典版本），全部清除以释放空间
+func purgeTTSAudioCacheForLexicon() {
+	if ttsAudioCache != nil {
+		purged := ttsAudioCache.Purge("")
+		fmt.Printf("用户词典已修改，清除音频缓存 %d 条\n", purged)
+	}
+}
+
+func registerLexiconRoutes(r *gin.Engine) {
+	r.GET("/lexicon", lexiconListHandler)
+	r.GET("/lexicon/:word", lexiconGetHandler)
+	r.POST("/lexicon", lexiconSetHandler)
+	r.PUT("/lexicon/:word", lexiconSetHandler)
+	r.DELETE("/lexicon/:word", lexiconDeleteHandler)
+}
+
+func lexiconListHandler(c *gin.Context) {
+	entries := userLexicon.List()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"count":   len(entries),
+		"entries": entries,
+	})
+}
+
+func lexiconGetHandler(c *gin.Context) {
+	word := c.Param("word")
+	entries := userLexicon.Get(word)
+	if len(entries) == 0 {
+		ttsErrorResponse(c, newTTSError(ErrCodeNotFound, "用户词典中没有: "+word, nil))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"entries": entries,
+	})
+}
+
+// POST 时词取自请求体，PUT 时取自路径
+func lexiconSetHandler(c *gin.Context) {
+	var entry UserLexiconEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		ttsErrorResponse(c, newTTSError(ErrCodeInvalidRequest, "请求参数错误", err))
+		return
+	}
+	if word := c.Param("word"); word != "" {
+		entry.Word = word
+	}
+
+	userLexiconSaveMutex.Lock()
+	defer userLexiconSaveMutex.Unlock()
+
+	entry, err := userLexicon.Set(entry)
+	if err != nil {
+		ttsErrorResponse(c, err)
+		return
+	}
+	if err := userLexicon.Save(); err != nil {
+		ttsErrorResponse(c, newTTSError(ErrCodeLexiconSave, "用户词典写回文件失败", err))
+		return
+	}
+	purgeTTSAudioCacheForLexicon()
+	fmt.Printf("用户词典更新: %s [%s] %s\n", entry.Word, entry.Alphabet, entry.Phonemes)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"entry":   entry,
+	})
+}
+
+func lexiconDeleteHandler(c *gin.Context) {
+	word := c.Param("word")
+	alphabet := c.Query("alphabet")
+
+	userLexiconSaveMutex.Lock()
+	defer userLexiconSaveMutex.Unlock()
+
+	deleted := userLexicon.Delete(word, alphabet)
+	if deleted == 0 {
+		ttsErrorResponse(c, newTTSError(ErrCodeNotFound, "用户词典中没有: "+word, nil))
+		return
+	}
+	if err := userLexicon.Save(); err != nil {
+		ttsErrorResponse(c, newTTSError(ErrCodeLexiconSave, "用户词典写回文件失败", err))
+		return
+	}
+	purgeTTSAudioCacheForLexicon()
+	fmt.Printf("用户词典删除: %s, 条数=%d\n", word, deleted)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"deleted": deleted,
+	})
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 响度归一化与真峰值限幅（EBU R128 / ITU-R BS.1770-4）：
+// 积分响度：K 计权（高架滤波 + 高通滤波）后按 400ms 块、75% 重叠求均方，经 -70 LUFS 绝对门限与
+// 相对门限（-10 LU）后取平均；不足 400ms 的音频按一整块计算
+// 真峰值：4 倍过采样后的最大绝对值
+// 限幅：按过采样峰值计算每个样本所需的增益，前视窗口内提前压低、释放时平滑恢复，替代直接削波
+
+const (
+	minTargetLoudness = -70.0
+	maxTargetLoudness = -5.0
+	minTruePeakLimit  = -20.0
+	maxTruePeakLimit  = 0.0
+
+	defaultTruePeakLimit = -1.0 // 指定目标响度而未指定 true_peak 时的真峰值上限，EBU R128 推荐值
+	clipTruePeakLimit    = -0.1 // 未要求响度处理、但样本超出 [-1, 1] 时代替削波的上限
+
+	loudnessBlockSeconds    = 0.4
+	loudnessStepSeconds     = 0.1
+	loudnessAbsoluteGate    = -70.0
+	loudnessRelativeGate    = -10.0
+	truePeakOversample      = 4
+	limiterLookaheadSeconds = 0.002
+	limiterReleaseSeconds   = 0.05
+)
+
+// 请求中的响度选项，均为 nil 时只在样本超出满幅时限幅
+type TTSLoudnessOptions struct {
+	TargetLUFS *float64 // 目标积分响度
+	TruePeak   *float64 // 真峰值上限 dBTP
+}
+
+// 响度处理结果，随响应头或时间戳JSON返回；静音时响度为 null
+type TTSLoudnessReport struct {
+	InputLUFS  *float64 `json:"input_lufs"`     // 处理前的积分响度
+	OutputLUFS *float64 `json:"output_lufs"`    // 处理后的积分响度
+	TruePeak   *float64 `json:"true_peak_dbtp"` // 处理后的真峰值，未计算时为 null
+	GainDB     float64  `json:"gain_db"`        // 施加的增益
+	Limited    bool     `json:"limited"`        // 是否经过限幅
+}
+
+// 校验取值范围
+func (o TTSLoudnessOptions) Validate() error {
+	if o.TargetLUFS != nil && (*o.TargetLUFS < minTargetLoudness || *o.TargetLUFS > maxTargetLoudness) {
+		return newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("loudness 取值范围为 %v~%v LUFS，当前为 %v", minTargetLoudness, maxTargetLoudness, *o.TargetLUFS), nil)
+	}
+	if o.TruePeak != nil && (*o.TruePeak < minTruePeakLimit || *o.TruePeak > maxTruePeakLimit) {
+		return newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("true_peak 取值范围为 %v~%v dBTP，当前为 %v", minTruePeakLimit, maxTruePeakLimit, *o.TruePeak), nil)
+	}
+	return nil
+}
+
+// 测量响度、调整增益并限幅，返回新的PCM，不修改输入
+func processLoudness(pcmData []float32, sampleRate int, options TTSLoudnessOptions) ([]float32, *TTSLoudnessReport) {
+	report := &TTSLoudnessReport{InputLUFS: integratedLoudness(pcmData, sampleRate)}
+	output := pcmData
+
+	if options.TargetLUFS != nil && report.InputLUFS != nil {
+		report.GainDB = *options.TargetLUFS - *report.InputLUFS
+		gain := float32(dbToLinear(report.GainDB))
+		output = make([]float32, len(pcmData))
+		for i, sample := range pcmData {
+			output[i] = sample * gain
+		}
+	}
+
+	ceiling := options.TruePeak
+	if ceiling == nil && options.TargetLUFS != nil {
+		limit := defaultTruePeakLimit
+		ceiling = &limit
+	}
+	if ceiling == nil {
+		// 未要求响度处理时只在会削波的情况下限幅
+		for _, sample := range output {
+			if sample > 1 || sample < -1 {
+				limit := clipTruePeakLimit
+				ceiling = &limit
+				break
+			}
+		}
+	}
+	if ceiling == nil {
+		report.OutputLUFS = report.InputLUFS
+		return output, report
+	}
+
+	oversampled := oversampleTruePeak(output, sampleRate)
+	if linearToDB(maxAbs(oversampled)) > *ceiling {
+		output = limitTruePeak(output, oversampled, sampleRate, dbToLinear(*ceiling))
+		report.Limited = true
+		oversampled = oversampleTruePeak(output, sampleRate)
+	}
+	peak := linearToDB(maxAbs(oversampled))
+	report.TruePeak = &peak
+	report.OutputLUFS = integratedLoudness(output, sampleRate)
+	return output, report
+}
+
+// BS.1770 积分响度（LUFS），静音或全部被门限滤除时返回 nil
+func integratedLoudness(pcmData []float32, sampleRate int) *float64 {
+	if len(pcmData) == 0 {
+		return nil
+	}
+	weighted := kWeight(pcmData, sampleRate)
+
+	blockSize := int(loudnessBlockSeconds * float64(sampleRate))
+	step := int(loudnessStepSeconds * float64(sampleRate))
+	if blockSize > len(weighted) {
+		blockSize = len(weighted)
+	}
+	var powers []float64
+	for start := 0; start+blockSize <= len(weighted); start += step {
+		sum := 0.0
+		for _, value := range weighted[start : start+blockSize] {
+			sum += value * value
+		}
+		powers = append(powers, sum/float64(blockSize))
+	}
+
+	gatedMean := func(threshold float64) (float64, int) {
+		sum, count := 0.0, 0
+		for _, power := range powers {
+			if powerToLUFS(power) > threshold {
+				sum += power
+				count++
+			}
+		}
+		if count == 0 {
+			return 0, 0
+		}
+		return sum / float64(count), count
+	}
+	mean, count := gatedMean(loudnessAbsoluteGate)
+	if count == 0 {
+		return nil
+	}
+	mean, count = gatedMean(math.Max(loudnessAbsoluteGate, powerToLUFS(mean)+loudnessRelativeGate))
+	if count == 0 {
+		return nil
+	}
+	loudness := powerToLUFS(mean)
+	return &loudness
+}
+
+// K 计权：模拟头部声学效应的高架滤波器，再接 RLB 高通滤波器；系数按采样率由模拟原型双线性变换得到
+func kWeight(pcmData []float32, sampleRate int) []float64 {
+	fs := float64(sampleRate)
+
+	// 高架滤波器
+	k := math.Tan(math.Pi * 1681.974450955533 / fs)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// 高通滤波器
+	k = math.Tan(math.Pi * 38.13547087602444 / fs)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highpass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	output := make([]float64, len(pcmData))
+	for i, sample := range pcmData {
+		output[i] = highpass.process(shelf.process(float64(sample)))
+	}
+	return output
+}
+
+// 二阶IIR滤波器，直接II型转置
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// 4 倍过采样，第 i 个输入样本对应输出的 [4i, 4i+4)
+func oversampleTruePeak(pcmData []float32, sampleRate int) []float32 {
+	oversampled, err := ResampleAudio(pcmData, sampleRate, sampleRate*truePeakOversample)
+	if err != nil {
+		return pcmData
+	}
+	return oversampled
+}
+
+// 前视限幅：每个样本所需增益取其过采样峰值对应的值，在前后各一个前视窗口内取最小值，
+// 按释放时间平滑恢复，再做同宽的滑动平均，保证峰值处的增益不大于所需增益
+func limitTruePeak(pcmData []float32, oversampled []float32, sampleRate int, ceiling float64) []float32 {
+	n := len(pcmData)
+	lookahead := int(limiterLookaheadSeconds*float64(sampleRate)) + 1
+	required := make([]float64, n)
+	for i := range required {
+		peak := math.Abs(float64(pcmData[i]))
+		for j := i * truePeakOversample; j < (i+1)*truePeakOversample && j < len(oversampled); j++ {
+			peak = math.Max(peak, math.Abs(float64(oversampled[j])))
+		}
+		required[i] = 1
+		if peak > ceiling {
+			required[i] = ceiling / peak
+		}
+	}
+
+	// 窗口最小值，单调队列
+	minimum := make([]float64, n)
+	var queue []int
+	for i, next := 0, 0; i < n; i++ {
+		for ; next < n && next <= i+lookahead; next++ {
+			for len(queue) > 0 && required[queue[len(queue)-1]] >= required[next] {
+				queue = queue[:len(queue)-1]
+			}
+			queue = append(queue, next)
+		}
+		for queue[0] < i-lookahead {
+			queue = queue[1:]
+		}
+		minimum[i] = required[queue[0]]
+	}
+
+	// 增益立即下降，按释放时间指数恢复
+	release := 1 - math.Exp(-1/(limiterReleaseSeconds*float64(sampleRate)))
+	envelope := make([]float64, n)
+	gain := 1.0
+	for i, value := range minimum {
+		gain += (1 - gain) * release
+		if value < gain {
+			gain = value
+		}
+		envelope[i] = gain
+	}
+
+	// 滑动平均去掉增益突变
+	prefix := make([]float64, n+1)
+	for i, value := range envelope {
+		prefix[i+1] = prefix[i] + value
+	}
+	output := make([]float32, n)
+	for i, sample := range pcmData {
+		lo, hi := i-lookahead, i+lookahead+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > n {
+			hi = n
+		}
+		smoothed := (prefix[hi] - prefix[lo]) / float64(hi-lo)
+		output[i] = float32(float64(sample) * math.Min(smoothed, envelope[i]))
+	}
+	return output
+}
+
+// 在响应头中返回响度测量结果
+func setTTSLoudnessHeaders(c *gin.Context, report *TTSLoudnessReport) {
+	formatDB := func(value *float64) string {
+		if value == nil {
+			return "-inf"
+		}
+		return strconv.FormatFloat(*value, 'f', 2, 64)
+	}
+	c.Header("X-TTS-Loudness-Input", formatDB(report.InputLUFS))
+	c.Header("X-TTS-Loudness", formatDB(report.OutputLUFS))
+	if report.TruePeak != nil {
+		c.Header("X-TTS-True-Peak", formatDB(report.TruePeak))
+	}
+	c.Header("X-TTS-Gain", strconv.FormatFloat(report.GainDB, 'f', 2, 64))
+	c.Header("X-TTS-Limited", strconv.FormatBool(report.Limited))
+}
+
+func powerToLUFS(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+func dbToLinear(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func linearToDB(value float64) float64 {
+	return 20 * math.Log10(value)
+}
+
+func maxAbs(pcmData []float32) float64 {
+	peak := 0.0
+	for _, sample := range pcmData {
+		peak = math.Max(peak, math.Abs(float64(sample)))
+	}
+	return peak
+}
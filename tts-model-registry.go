@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+)
+
+// 模型注册表：每个语言（音色）对应的 ONNX 模型、BERT、分词器、音素表、声调偏移、g2p前端、采样率与发音人
+// 按以下顺序查找配置，找到第一个即停止：
+//
+//	环境变量 TTS_MODEL_CONFIG 指定的文件或目录
+//	./models.yaml、./models.yml、./models.json
+//	./models/ 目录，每个子目录一个模型，目录内放 model.yaml 或 model.json，语言默认取目录名
+//
+// 内置的 zh_x / yue_en 配置（与原先工作目录下的文件名一致）始终存在，配置中的同名语言覆盖内置配置
+// 配置中的相对路径相对于配置文件所在目录
+
+// g2p 前端沿用内置语言名
+const (
+	FrontendMandarin  = ZH_X   // 普通话+英语
+	FrontendCantonese = YUE_EN // 粤语+英语
+)
+
+// 单个模型的配置
+type TTSModelConfig struct {
+	Language      Language `yaml:"language" json:"language"`             // 请求中使用的语言名，如 zh_x、zh_x_female
+	Name          string   `yaml:"name" json:"name,omitempty"`           // 展示名
+	Model         string   `yaml:"model" json:"model"`                   // TTS ONNX 模型
+	BertModel     string   `yaml:"bert_model" json:"bert_model"`         // BERT ONNX 模型
+	BertTokenizer string   `yaml:"bert_tokenizer" json:"bert_tokenizer"` // BERT 分词器 json
+	Symbols       string   `yaml:"symbols" json:"symbols"`               // 音素ID表 json
+	ToneOffset    *int     `yaml:"tone_offset" json:"tone_offset,omitempty"`
+	Frontend      Language `yaml:"frontend" json:"frontend"`             // g2p前端：zh_x 或 yue_en
+	SampleRate    int      `yaml:"sample_rate" json:"sample_rate"`       // 默认24000
+	Speakers      []string `yaml:"speakers" json:"speakers"`             // 发音人名，下标即 speaker_id
+	SdpRatio      *float32 `yaml:"sdp_ratio" json:"sdp_ratio,omitempty"` // 推理超参数默认值，见 TTSInferenceParams
+	NoiseScale    *float32 `yaml:"noise_scale" json:"noise_scale,omitempty"`
+	NoiseScaleW   *float32 `yaml:"noise_scale_w" json:"noise_scale_w,omitempty"`
+
+	Inference TTSInferenceParams `yaml:"-" json:"-"` // 补全默认值后的推理超参数
+}
+
+// /languages 返回的模型信息
+type TTSModelInfo struct {
+	Language   Language           `json:"language"`
+	Name       string             `json:"name,omitempty"`
+	Frontend   Language           `json:"frontend"`
+	SampleRate int                `json:"sample_rate"`
+	Speakers   []string           `json:"speakers"`
+	Inference  TTSInferenceParams `json:"inference"` // 推理超参数默认值
+	Installed  bool               `json:"installed"`
+	Missing    []string           `json:"missing,omitempty"` // 未安装时缺少的文件
+}
+
+type ttsModelRegistryFile struct {
+	Models []TTSModelConfig `yaml:"models" json:"models"`
+}
+
+var (
+	ttsModelRegistry     map[Language]*TTSModelConfig
+	ttsModelRegistryErr  error
+	ttsModelRegistryOnce sync.Once
+)
+
+// 内置配置
+func defaultTTSModelConfigs() []TTSModelConfig {
+	return []TTSModelConfig{
+		{
+			Language:      ZH_X,
+			Name:          "普通话+英语",
+			Model:         "./zh_x_tts-model.onnx",
+			BertModel:     "./bert-base-multilingual-uncased.onnx",
+			BertTokenizer: "./bert-base-multilingual-uncased.json",
+			Symbols:       "./zh_x_symbolid.json",
+			Frontend:      FrontendMandarin,
+		},
+		{
+			Language:      YUE_EN,
+			Name:          "粤语+英语",
+			Model:         "./yue_en_tts-model.onnx",
+			BertModel:     "./bert-base-multilingual-cased.onnx",
+			BertTokenizer: "./bert-base-multilingual-cased.json",
+			Symbols:       "./yue_en_symbolid.json",
+			Frontend:      FrontendCantonese,
+		},
+	}
+}
+
+// 加载模型注册表，只加载一次；配置文件有误时返回错误，此时所有语言都不可用
+func getTTSModelRegistry() (map[Language]*TTSModelConfig, error) {
+	ttsModelRegistryOnce.Do(func() {
+		configs, source, err := loadTTSModelConfigs()
+		if err != nil {
+			ttsModelRegistryErr = newTTSError(ErrCodeModelLoad, "加载模型配置失败", err)
+			return
+		}
+		registry := make(map[Language]*TTSModelConfig)
+		for _, config := range defaultTTSModelConfigs() {
+			config.normalize()
+			registry[config.Language] = &config
+		}
+		seen := make(map[Language]bool)
+		for i := range configs {
+			config := &configs[i]
+			if err := config.normalize(); err != nil {
+				ttsModelRegistryErr = newTTSError(ErrCodeModelLoad, "模型配置有误: "+source, err)
+				return
+			}
+			if seen[config.Language] {
+				ttsModelRegistryErr = newTTSError(ErrCodeModelLoad, fmt.Sprintf("模型配置中语言 %s 重复: %s", config.Language, source), nil)
+				return
+			}
+			seen[config.Language] = true
+			registry[config.Language] = config
+		}
+		ttsModelRegistry = registry
+		fmt.Printf("已加载模型配置: %s, 共 %d 个模型\n", source, len(registry))
+	})
+	return ttsModelRegistry, ttsModelRegistryErr
+}
+
+// 按语言查找模型配置
+func lookupTTSModel(language Language) (*TTSModelConfig, error) {
+	registry, err := getTTSModelRegistry()
+	if err != nil {
+		return nil, err
+	}
+	config, ok := registry[language]
+	if !ok {
+		return nil, newTTSError(ErrCodeUnsupportedLanguage, "不支持的语言: "+string(language), nil)
+	}
+	return config, nil
+}
+
+// 语言对应模型的输出采样率，查不到时返回默认的24000
+func ttsModelSampleRate(language Language) int {
+	if config, err := lookupTTSModel(language); err == nil {
+		return config.SampleRate
+	}
+	return 24000
+}
+
+// 列出注册表中的所有模型及是否已安装，按语言名排序
+func listTTSModels() ([]TTSModelInfo, error) {
+	registry, err := getTTSModelRegistry()
+	if err != nil {
+		return nil, err
+	}
+	models := make([]TTSModelInfo, 0, len(registry))
+	for _, config := range registry {
+		missing := config.missingFiles()
+		models = append(models, TTSModelInfo{
+			Language:   config.Language,
+			Name:       config.Name,
+			Frontend:   config.Frontend,
+			SampleRate: config.SampleRate,
+			Speakers:   config.Speakers,
+			Inference:  config.Inference,
+			Installed:  len(missing) == 0,
+			Missing:    missing,
+		})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Language < models[j].Language })
+	return models, nil
+}
+
+func loadTTSModelConfigs() ([]TTSModelConfig, string, error) {
+	candidates := []string{"./models.yaml", "./models.yml", "./models.json", "./models"}
+	if path := os.Getenv("TTS_MODEL_CONFIG"); path != "" {
+		candidates = []string{path}
+	}
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.Getenv("TTS_MODEL_CONFIG") != "" {
+				return nil, path, err
+			}
+			continue
+		}
+		if info.IsDir() {
+			configs, err := loadTTSModelDir(path)
+			return configs, path, err
+		}
+		configs, err := loadTTSModelFile(path)
+		return configs, path, err
+	}
+	return nil, "内置配置", nil
+}
+
+// 读取注册表文件，YAML 与 JSON 均可（JSON 是 YAML 的子集）
+func loadTTSModelFile(path string) ([]TTSModelConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file ttsModelRegistryFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for i := range file.Models {
+		file.Models[i].resolvePaths(dir)
+	}
+	return file.Models, nil
+}
+
+// 读取模型目录，每个子目录一个模型
+func loadTTSModelDir(dir string) ([]TTSModelConfig, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var configs []TTSModelConfig
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		modelDir := filepath.Join(dir, entry.Name())
+		for _, name := range []string{"model.yaml", "model.yml", "model.json"} {
+			path := filepath.Join(modelDir, name)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			var config TTSModelConfig
+			if err := yaml.Unmarshal(data, &config); err != nil {
+				return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+			}
+			if config.Language == "" {
+				config.Language = Language(entry.Name())
+			}
+			config.resolvePaths(modelDir)
+			configs = append(configs, config)
+			break
+		}
+	}
+	return configs, nil
+}
+
+func (c *TTSModelConfig) resolvePaths(dir string) {
+	for _, path := range []*string{&c.Model, &c.BertModel, &c.BertTokenizer, &c.Symbols} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
+
+// 校验必填项并补全默认值
+func (c *TTSModelConfig) normalize() error {
+	if c.Language == "" {
+		return fmt.Errorf("缺少 language")
+	}
+	for field, path := range map[string]string{"model": c.Model, "bert_model": c.BertModel, "bert_tokenizer": c.BertTokenizer, "symbols": c.Symbols} {
+		if path == "" {
+			return fmt.Errorf("%s 缺少 %s", c.Language, field)
+		}
+	}
+	if c.Frontend == "" {
+		c.Frontend = c.Language
+	}
+	if c.Frontend != FrontendMandarin && c.Frontend != FrontendCantonese {
+		return fmt.Errorf("%s 的 frontend 只能为 %s 或 %s，当前为 %q", c.Language, FrontendMandarin, FrontendCantonese, c.Frontend)
+	}
+	// melotts 所有语言的声调共用一张表，普通话从14开始，粤语从20开始
+	if c.ToneOffset == nil {
+		offset := 20
+		if c.Frontend == FrontendMandarin {
+			offset = 14
+		}
+		c.ToneOffset = &offset
+	}
+	if c.SampleRate <= 0 {
+		c.SampleRate = 24000
+	}
+	if len(c.Speakers) == 0 {
+		c.Speakers = []string{"default"}
+	}
+	for i, speaker := range c.Speakers {
+		c.Speakers[i] = strings.TrimSpace(speaker)
+	}
+	c.Inference = defaultTTSInferenceParams
+	if c.SdpRatio != nil {
+		c.Inference.SdpRatio = *c.SdpRatio
+	}
+	if c.NoiseScale != nil {
+		c.Inference.NoiseScale = *c.NoiseScale
+	}
+	if c.NoiseScaleW != nil {
+		c.Inference.NoiseScaleW = *c.NoiseScaleW
+	}
+	if err := c.Inference.Validate(); err != nil {
+		return fmt.Errorf("%s 的推理超参数有误: %w", c.Language, err)
+	}
+	return nil
+}
+
+// 不存在的模型文件
+func (c *TTSModelConfig) missingFiles() []string {
+	var missing []string
+	for _, path := range []string{c.Model, c.BertModel, c.BertTokenizer, c.Symbols} {
+		if _, err := os.Stat(path); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// 发音人名转为 speaker_id，不区分大小写
+func (c *TTSModelConfig) SpeakerID(name string) (int, bool) {
+	for i, speaker := range c.Speakers {
+		if strings.EqualFold(speaker, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
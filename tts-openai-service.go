@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAI 兼容的语音合成接口 POST /v1/audio/speech
+// 字段与 OpenAI speech API 一致，现有SDK把 base_url 指过来即可使用
+
+// OpenAI speech 请求结构体
+type OpenAISpeechRequest struct {
+	Model          string   `json:"model" binding:"required"`  // zh_x / yue_en，或 tts-1 等别名
+	Input          string   `json:"input" binding:"required"`  // 要转换的文本
+	Voice          string   `json:"voice" binding:"required"`  // 发音人ID（数字字符串）或命名音色
+	ResponseFormat string   `json:"response_format,omitempty"` // wav / pcm（24kHz）/ flac 及 /tts 支持的其他格式，默认 wav
+	Speed          *float32 `json:"speed,omitempty"`           // 0.25~4.0，默认1.0
+}
+
+// OpenAI 模型名到语言的映射，模型注册表中的语言名（如 zh_x / yue_en）也可直接作为 model 传入
+var openAIModelMap = map[string]Language{
+	"tts-1":    ZH_X,
+	"tts-1-hd": ZH_X,
+	"zh_x":     ZH_X,
+	"yue_en":   YUE_EN,
+}
+
+// OpenAI 命名音色到发音人ID的映射，目前模型为单发音人，统一映射到0
+var openAIVoiceMap = map[string]int{
+	"alloy":   0,
+	"ash":     0,
+	"coral":   0,
+	"echo":    0,
+	"fable":   0,
+	"onyx":    0,
+	"nova":    0,
+	"sage":    0,
+	"shimmer": 0,
+}
+
+// OpenAI 的 pcm 格式：24kHz 16位有符号小端，无文件头
+const openAIPCMSampleRate = 24000
+
+// OpenAI 定义、但本服务没有编码器的格式
+var openAIUnsupportedFormats = map[string]bool{
+	"mp3":  true,
+	"opus": true,
+	"aac":  true,
+}
+
+// 按 response_format 查找编码器，OpenAI 定义而未实现的格式单独说明
+func lookupOpenAIAudioEncoder(format string) (AudioEncoder, error) {
+	name := strings.ToLower(strings.TrimSpace(format))
+	if openAIUnsupportedFormats[name] {
+		return nil, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("暂不支持 %s 格式，可选 %s", name, strings.Join(audioFormatNames(), " / ")), nil)
+	}
+	return lookupAudioEncoder(format)
+}
+
+// 返回 OpenAI 格式的错误
+func openAIError(c *gin.Context, status int, errType string, param string, message string) {
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    errType,
+			"param":   param,
+			"code":    nil,
+		},
+	})
+}
+
+// 合成流程错误按 OpenAI 格式返回，code 为 TTSErrorCode
+func openAITTSError(c *gin.Context, err error) {
+	ttsErr := asTTSError(err)
+	errType := "server_error"
+	if ttsErr.HTTPStatus() < http.StatusInternalServerError {
+		errType = "invalid_request_error"
+	}
+	c.JSON(ttsErr.HTTPStatus(), gin.H{
+		"error": gin.H{
+			"message": err.Error(),
+			"type":    errType,
+			"param":   nil,
+			"code":    ttsErr.Code,
+		},
+	})
+}
+
+// 解析 voice：数字字符串直接作为发音人ID，否则查命名音色表
+func parseOpenAIVoice(voice string) (int, bool) {
+	if id, err := strconv.Atoi(voice); err == nil && id >= 0 {
+		return id, true
+	}
+	id, ok := openAIVoiceMap[strings.ToLower(voice)]
+	return id, ok
+}
+
+// OpenAI speech API处理器
+func openAISpeechHandler(c *gin.Context) {
+	startTime := time.Now()
+
+	var req OpenAISpeechRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "", "请求参数错误: "+err.Error())
+		return
+	}
+
+	// 先查固定映射，再把 model 当作模型注册表中的语言名
+	language, ok := openAIModelMap[strings.ToLower(req.Model)]
+	if !ok {
+		language = Language(req.Model)
+	}
+	model, err := lookupTTSModel(language)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "model", "不支持的模型: "+req.Model)
+		return
+	}
+
+	// voice 可以是模型配置中的发音人名
+	speakerID, ok := model.SpeakerID(req.Voice)
+	if !ok {
+		speakerID, ok = parseOpenAIVoice(req.Voice)
+	}
+	if !ok {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "voice", "不支持的音色: "+req.Voice)
+		return
+	}
+
+	speed := float32(1.0)
+	if req.Speed != nil {
+		speed = *req.Speed
+	}
+	if speed < 0.25 || speed > 4.0 {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "speed", "speed 取值范围为 0.25~4.0")
+		return
+	}
+
+	encoder, err := lookupOpenAIAudioEncoder(req.ResponseFormat)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "response_format", err.Error())
+		return
+	}
+
+	pool, err := getOrCreateTTSEnginePool(language, CPU)
+	if err != nil {
+		openAITTSError(c, err)
+		return
+	}
+	audioData, err := pool.Synthesize(c.Request.Context(), req.Input, userLexicon, speakerID, speed, nil)
+	if err != nil {
+		openAITTSError(c, err)
+		return
+	}
+	sampleRate := model.SampleRate
+	// OpenAI 的 pcm 格式固定为 24kHz，裸数据不带采样率，按模型采样率输出时客户端会以错误的速度播放
+	if encoder.Name() == "pcm" {
+		audioData, err = resampleToOutputRate(audioData, sampleRate, openAIPCMSampleRate)
+		if err != nil {
+			openAITTSError(c, err)
+			return
+		}
+		sampleRate = openAIPCMSampleRate
+	}
+	// 超出满幅时以真峰值限幅代替削波
+	audioData, _ = processLoudness(audioData, sampleRate, TTSLoudnessOptions{})
+
+	encoded, err := encodeAudio(encoder, audioData, sampleRate)
+	if err != nil {
+		openAIError(c, http.StatusInternalServerError, "server_error", "", "生成音频失败: "+err.Error())
+		return
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(encoded)))
+	c.Data(http.StatusOK, encoder.ContentType(), encoded)
+
+	fmt.Printf("OpenAI speech调用成功: 模型=%s, 音色=%s, 格式=%s, 文本长度=%d, 音频时长=%.2f秒, 耗时=%v\n",
+		req.Model, req.Voice, encoder.Name(), len(req.Input), float64(len(audioData))/float64(sampleRate), time.Since(startTime))
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// 采样率转换：Kaiser 窗 sinc 低通的多相滤波器，支持任意整数采样率之间的转换
+// 降采样时截止频率随目标采样率降低，先滤除目标奈奎斯特频率以上的成分再抽取，避免混叠；
+// 升采样时截止频率为原采样率的奈奎斯特频率。滤波器以输出时刻为中心对称，不引入延迟
+//
+// 转换比为 L/M（约分后），L 不超过 resamplerMaxPhases 时每个输出样本恰好落在一个预计算的相位上；
+// 更大的 L（如 24000→44101）在相邻相位之间线性插值
+
+const (
+	minOutputSampleRate = 8000
+	maxOutputSampleRate = 192000
+
+	resamplerZeroCrossings = 32   // 滤波器单侧的过零点数（按较低采样率计）
+	resamplerRolloff       = 0.92 // 截止频率占较低采样率奈奎斯特频率的比例，留出过渡带
+	resamplerKaiserBeta    = 8.6  // 阻带衰减约 90dB
+	resamplerMaxPhases     = 2048
+)
+
+// 按 源采样率-目标采样率 缓存的转换器，滤波器系数只计算一次
+var resamplerCache sync.Map
+
+// 采样率转换器，创建后可重复使用，并发安全
+type Resampler struct {
+	fromRate int
+	toRate   int
+	up       int         // L
+	down     int         // M
+	phases   int         // 预计算的相位数
+	halfTaps int         // 每个相位单侧的抽头数
+	filters  [][]float32 // phases+1 组系数，第 p 组对应小数位置 p/phases
+}
+
+// 创建采样率转换器
+func NewResampler(fromRate int, toRate int) (*Resampler, error) {
+	if fromRate <= 0 || toRate <= 0 {
+		return nil, fmt.Errorf("采样率必须为正数: %d → %d", fromRate, toRate)
+	}
+	g := gcd(fromRate, toRate)
+	r := &Resampler{
+		fromRate: fromRate,
+		toRate:   toRate,
+		up:       toRate / g,
+		down:     fromRate / g,
+	}
+	r.phases = r.up
+	if r.phases > resamplerMaxPhases {
+		r.phases = resamplerMaxPhases
+	}
+
+	// 以输入采样点为时间单位，scale 为截止频率相对输入奈奎斯特频率的缩放
+	scale := math.Min(1, float64(toRate)/float64(fromRate))
+	cutoff := scale * resamplerRolloff
+	halfWidth := resamplerZeroCrossings / scale
+	r.halfTaps = int(math.Ceil(halfWidth))
+
+	besselBeta := besselI0(resamplerKaiserBeta)
+	r.filters = make([][]float32, r.phases+1)
+	for p := range r.filters {
+		frac := float64(p) / float64(r.phases)
+		taps := make([]float64, 2*r.halfTaps)
+		sum := 0.0
+		for k := range taps {
+			t := frac + float64(r.halfTaps-1-k) // 输出时刻与第 k 个输入样本的距离
+			if math.Abs(t) >= halfWidth {
+				continue
+			}
+			ratio := t / halfWidth
+			window := besselI0(resamplerKaiserBeta*math.Sqrt(1-ratio*ratio)) / besselBeta
+			taps[k] = cutoff * sinc(cutoff*t) * window
+			sum += taps[k]
+		}
+		// 每个相位的直流增益归一到1，消除相位间的增益起伏
+		filter := make([]float32, len(taps))
+		for k, tap := range taps {
+			filter[k] = float32(tap / sum)
+		}
+		r.filters[p] = filter
+	}
+	return r, nil
+}
+
+// 转换一段完整的音频，两端按静音处理
+func (r *Resampler) Resample(pcmData []float32) []float32 {
+	if r.up == r.down {
+		return append([]float32(nil), pcmData...)
+	}
+	outLen := int((int64(len(pcmData))*int64(r.up) + int64(r.down) - 1) / int64(r.down))
+	output := make([]float32, outLen)
+	for n := range output {
+		position := int64(n) * int64(r.down)
+		base := int(position / int64(r.up))
+		phase := float64(position%int64(r.up)) * float64(r.phases) / float64(r.up)
+		p := int(phase)
+		weight := float32(phase - float64(p))
+
+		start := base - r.halfTaps + 1
+		var sum, next float32
+		lower, upper := r.filters[p], r.filters[p+1]
+		for k := range lower {
+			j := start + k
+			if j < 0 || j >= len(pcmData) {
+				continue
+			}
+			sum += pcmData[j] * lower[k]
+			if weight != 0 {
+				next += pcmData[j] * upper[k]
+			}
+		}
+		if weight != 0 {
+			sum += (next - sum) * weight
+		}
+		output[n] = sum
+	}
+	return output
+}
+
+// ResampleAudio 将 fromRate 的单声道 float32 PCM 转换为 toRate，采样率相同时返回副本
+// 供直接调用引擎的程序使用，如 ResampleAudio(pcm, engine.SampleRate(), 8000)
+func ResampleAudio(pcmData []float32, fromRate int, toRate int) ([]float32, error) {
+	key := [2]int{fromRate, toRate}
+	if cached, ok := resamplerCache.Load(key); ok {
+		return cached.(*Resampler).Resample(pcmData), nil
+	}
+	resampler, err := NewResampler(fromRate, toRate)
+	if err != nil {
+		return nil, err
+	}
+	resamplerCache.Store(key, resampler)
+	return resampler.Resample(pcmData), nil
+}
+
+// 校验请求的输出采样率，未指定时返回模型采样率
+func resolveOutputSampleRate(requested *int, modelRate int) (int, error) {
+	if requested == nil || *requested == 0 {
+		return modelRate, nil
+	}
+	if *requested < minOutputSampleRate || *requested > maxOutputSampleRate {
+		return 0, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("sample_rate 取值范围为 %d~%d，当前为 %d", minOutputSampleRate, maxOutputSampleRate, *requested), nil)
+	}
+	return *requested, nil
+}
+
+// 按需转换到输出采样率
+func resampleToOutputRate(pcmData []float32, modelRate int, outputRate int) ([]float32, error) {
+	if outputRate == modelRate {
+		return pcmData, nil
+	}
+	resampled, err := ResampleAudio(pcmData, modelRate, outputRate)
+	if err != nil {
+		return nil, newTTSError(ErrCodeInference, "采样率转换失败", err)
+	}
+	return resampled, nil
+}
+
+// 归一化 sinc：sin(πx)/(πx)
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// 第一类零阶修正贝塞尔函数，级数展开
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 64; k++ {
+		term *= (x / 2) / float64(k)
+		sum += term * term
+		if term*term < sum*1e-16 {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
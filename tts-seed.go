@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// 可复现合成：VITS 的两处随机噪声（随机时长预测器的噪声、先验分布采样的噪声）默认由模型内的
+// RandomNormalLike 生成，ONNX Runtime 的C接口无法为其设定随机种子。
+// 导出ONNX时把这两处噪声改为模型输入即可由服务端按 seed 生成：
+//
+//	noise_w  [batch, 2, T_x]      替换随机时长预测器中的 torch.randn
+//	noise_z  [batch, C, T_max]    替换 torch.randn_like(m_p)，模型内取 noise_z[:, :, :T_y]
+//
+// 引擎检测到这两个输入后，用 seed 生成噪声喂入，CPU 上逐位可复现；没有 seed 时随机生成一个并回显。
+// 模型没有噪声输入时，带 seed 的请求退化为零噪声推理（noise_scale 与 noise_scale_w 按0处理），
+// 结果同样可复现，但与 seed 的取值无关；响应头中回显的 noise_scale 与 noise_scale_w 也为0
+
+const (
+	ttsNoiseWInput = "noise_w"
+	ttsNoiseZInput = "noise_z"
+
+	// noise_z 按每个输入位置最多多少帧预留长度（speed=1 时），超出的部分由模型截掉
+	ttsNoiseFramesPerPhone = 32
+)
+
+// seed 的实现方式，在响应头 X-TTS-Seed-Mode 中返回
+const (
+	SeedModeNoiseInputs = "noise_inputs" // 按 seed 生成噪声输入
+	SeedModeZeroNoise   = "zero_noise"   // 模型没有噪声输入，零噪声推理
+)
+
+// 按 seed 的实现方式得到实际用于推理的超参数：零噪声推理时 noise_scale 与 noise_scale_w 为0
+func seededInferenceParams(params *TTSInferenceParams, seedMode string) *TTSInferenceParams {
+	if params.Seed == nil || seedMode != SeedModeZeroNoise {
+		return params
+	}
+	zeroNoise := *params
+	zeroNoise.NoiseScale, zeroNoise.NoiseScaleW = 0, 0
+	return &zeroNoise
+}
+
+// 随机生成一个 seed
+func newTTSSeed() int64 {
+	return rand.Int63()
+}
+
+// 引擎实现 seed 的方式
+func (m *XWX_TTS) SeedMode() string {
+	if m.noiseZChannels > 0 {
+		return SeedModeNoiseInputs
+	}
+	return SeedModeZeroNoise
+}
+
+// 记录模型的噪声输入，两个输入都存在时才启用
+func (m *XWX_TTS) detectNoiseInputs(inputInfos []ort.InputOutputInfo) []string {
+	channels := map[string]int{}
+	for _, info := range inputInfos {
+		if info.Name != ttsNoiseWInput && info.Name != ttsNoiseZInput {
+			continue
+		}
+		if len(info.Dimensions) != 3 || info.Dimensions[1] <= 0 {
+			fmt.Printf("噪声输入 %s 的形状 %v 不是 [batch, C, T]，忽略\n", info.Name, info.Dimensions)
+			continue
+		}
+		channels[info.Name] = int(info.Dimensions[1])
+	}
+	if len(channels) != 2 {
+		return nil
+	}
+	m.noiseWChannels = channels[ttsNoiseWInput]
+	m.noiseZChannels = channels[ttsNoiseZInput]
+	fmt.Printf("TTS模型导出了噪声输入，支持按 seed 复现: noise_w 通道 %d, noise_z 通道 %d\n", m.noiseWChannels, m.noiseZChannels)
+	return []string{ttsNoiseWInput, ttsNoiseZInput}
+}
+
+// 按 seed 生成噪声输入张量 noise_w 与 noise_z，调用者负责销毁
+// 批量推理时每条使用同一 seed 的连续随机序列
+func (m *XWX_TTS) newNoiseTensors(seed int64, batchSize int64, phoneLen int64, speed float32) ([]ort.Value, error) {
+	rng := rand.New(rand.NewSource(seed))
+	frames := int64(float32(phoneLen*ttsNoiseFramesPerPhone)/speed) + 1
+
+	noiseW, err := ort.NewTensor(ort.NewShape(batchSize, int64(m.noiseWChannels), phoneLen), gaussianNoise(rng, batchSize*int64(m.noiseWChannels)*phoneLen))
+	if err != nil {
+		return nil, fmt.Errorf("创建noise_w张量失败: %w", err)
+	}
+	noiseZ, err := ort.NewTensor(ort.NewShape(batchSize, int64(m.noiseZChannels), frames), gaussianNoise(rng, batchSize*int64(m.noiseZChannels)*frames))
+	if err != nil {
+		noiseW.Destroy()
+		return nil, fmt.Errorf("创建noise_z张量失败: %w", err)
+	}
+	return []ort.Value{noiseW, noiseZ}, nil
+}
+
+// 标准正态分布噪声
+func gaussianNoise(rng *rand.Rand, n int64) []float32 {
+	noise := make([]float32, n)
+	for i := range noise {
+		noise[i] = float32(rng.NormFloat64())
+	}
+	return noise
+}
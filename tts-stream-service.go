@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 流式TTS：按句切分文本，逐句推理并以 chunked 方式下发PCM
+// 先写入长度未知的文件头（WAV）或不写文件头（裸PCM、G.711），客户端收到第一句音频即可开始播放
+// sampleRate 为输出采样率，与模型采样率不同时逐句转换；响度按句归一化
+func ttsStreamHandler(c *gin.Context, ttsEngine *XWX_TTS, encoder StreamingAudioEncoder, sampleRate int, loudness TTSLoudnessOptions, text string, lexicon *UserLexicon, speakerID int, speed float32, params *TTSInferenceParams) {
+	startTime := time.Now()
+	modelSampleRate := ttsEngine.SampleRate()
+
+	sentences := SplitSentences(text)
+	if len(sentences) == 0 {
+		ttsErrorResponse(c, newTTSError(ErrCodeInvalidRequest, "文本为空", nil))
+		return
+	}
+
+	// 首句在写响应头之前合成，失败时仍可返回JSON错误
+	firstAudio, err := ttsEngine.Tts_pcm(sentences[0], lexicon, speakerID, speed, params)
+	if err != nil {
+		ttsErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Content-Type", encoder.ContentType())
+	c.Header("Content-Disposition", "attachment; filename=\"tts_output."+encoder.Extension()+"\"")
+	c.Header("X-TTS-Sample-Rate", strconv.Itoa(sampleRate))
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	headerBuffer := &bytes.Buffer{}
+	if err := encoder.WriteStreamHeader(headerBuffer, sampleRate); err != nil {
+		fmt.Printf("流式TTS生成文件头失败: %v\n", err)
+		return
+	}
+	if _, err := c.Writer.Write(headerBuffer.Bytes()); err != nil {
+		fmt.Printf("流式TTS写入文件头失败: %v\n", err)
+		return
+	}
+	c.Writer.Flush()
+
+	totalSamples := 0
+	for index, sentence := range sentences {
+		// 客户端断开后不再继续推理
+		if c.Request.Context().Err() != nil {
+			fmt.Printf("流式TTS客户端已断开，停止于第 %d/%d 句\n", index, len(sentences))
+			return
+		}
+
+		audioData := firstAudio
+		if index > 0 {
+			audioData, err = ttsEngine.Tts_pcm(sentence, lexicon, speakerID, speed, params)
+			if err != nil {
+				// 响应头已发出，只能中断输出
+				fmt.Printf("流式TTS第 %d 句合成失败，中断输出: %v\n", index+1, err)
+				return
+			}
+		}
+		audioData, err = resampleToOutputRate(audioData, modelSampleRate, sampleRate)
+		if err != nil {
+			fmt.Printf("流式TTS第 %d 句采样率转换失败，中断输出: %v\n", index+1, err)
+			return
+		}
+		audioData, _ = processLoudness(audioData, sampleRate, loudness)
+		totalSamples += len(audioData)
+
+		chunkBuffer := &bytes.Buffer{}
+		if err := encoder.EncodeChunk(chunkBuffer, audioData); err != nil {
+			fmt.Printf("流式TTS编码音频失败: %v\n", err)
+			return
+		}
+		if _, err := c.Writer.Write(chunkBuffer.Bytes()); err != nil {
+			fmt.Printf("流式TTS写入音频失败: %v\n", err)
+			return
+		}
+		c.Writer.Flush()
+
+		if index == 0 {
+			fmt.Printf("流式TTS首句音频耗时: %v\n", time.Since(startTime))
+		}
+	}
+
+	fmt.Printf("流式TTS完成: 句数=%d, 音频时长=%.2f秒, 耗时=%v\n",
+		len(sentences), float64(totalSamples)/float64(sampleRate), time.Since(startTime))
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 长文本分块合成：音素数超过 ttsChunkMaxPhones 时，先按句、再按分句标点切分，相邻的句子在不超过上限的前提下
+// 合为一块，每块单独推理，去掉块首尾的静音后按块末标点插入停顿，以短交叉淡化拼接
+// 不超过上限的文本与原先一样整段推理
+//
+// 通过环境变量配置：
+//
+//	TTS_CHUNK_MAX_PHONES    每块最多的音素数，默认250，0 不分块
+//	TTS_CHUNK_CROSSFADE_MS  块之间交叉淡化的毫秒数，默认10
+//	TTS_CHUNK_PAUSE_MS      各类标点后的停顿毫秒数，如 "sentence=300,clause=150"，未给出的类型取默认值：
+//	                        sentence（。！？.!?）300、clause（，、,）150、semicolon（；：;:）200、
+//	                        ellipsis（…—）350、paragraph（换行）500
+var (
+	ttsChunkMaxPhones   = 250
+	ttsChunkCrossfadeMs = 10
+	ttsChunkPauseMs     = map[string]int{
+		"sentence":  300,
+		"clause":    150,
+		"semicolon": 200,
+		"ellipsis":  350,
+		"paragraph": 500,
+	}
+)
+
+const (
+	ttsChunkTrimMarginMs   = 20   // 去静音时在语音前后保留的毫秒数，避免切掉轻声的起止
+	ttsChunkSilenceRatio   = 0.01 // 低于块内峰值 -40dB 视为静音
+	ttsChunkPhonesPerRune  = 4    // 估算每个字最多的音素数，字数乘以该值不超过上限时不做分块规划
+	ttsChunkClausePunct    = "，,、；;：:—"
+	ttsChunkMinSilenceGate = 1e-4
+)
+
+func init() {
+	if n, err := strconv.Atoi(os.Getenv("TTS_CHUNK_MAX_PHONES")); err == nil && n >= 0 {
+		ttsChunkMaxPhones = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("TTS_CHUNK_CROSSFADE_MS")); err == nil && n >= 0 {
+		ttsChunkCrossfadeMs = n
+	}
+	for _, item := range strings.Split(os.Getenv("TTS_CHUNK_PAUSE_MS"), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if _, known := ttsChunkPauseMs[name]; !known || err != nil || n < 0 {
+			fmt.Printf("忽略无效的 TTS_CHUNK_PAUSE_MS 项: %q\n", item)
+			continue
+		}
+		ttsChunkPauseMs[name] = n
+	}
+}
+
+// 一个推理块，音素首尾带 "_"
+type ttsChunk struct {
+	phones  []string
+	tones   []int
+	word2ph []int
+	text    string
+	pauseMs int // 本块之后插入的停顿
+}
+
+// 文本是否可能超过分块上限，用于跳过短文本的分块规划
+func ttsTextMayNeedChunking(text string) bool {
+	return ttsChunkMaxPhones > 0 && utf8.RuneCountInString(text)*ttsChunkPhonesPerRune > ttsChunkMaxPhones
+}
+
+// 把文本切分为推理块；不超过上限时返回整段g2p结果的一块
+func (m *XWX_TTS) planChunks(text string, lexicon *UserLexicon) ([]ttsChunk, error) {
+	phones, tones, word2ph, filteredText, err := m.G2p(text, lexicon)
+	if err != nil {
+		return nil, err
+	}
+	return m.splitChunks(phones, tones, word2ph, filteredText), nil
+}
+
+// 把整段g2p结果切分为推理块，phones 首尾带 "_"，word2ph 与 filteredText 的BERT token对齐
+// 在 filteredText 的句末、分句标点处切开，按各段的token数从 word2ph 中切出对应的音素，不再对各段重复g2p
+func (m *XWX_TTS) splitChunks(phones []string, tones []int, word2ph []int, filteredText string) []ttsChunk {
+	whole := []ttsChunk{{phones: phones, tones: tones, word2ph: word2ph, text: filteredText}}
+	if ttsChunkMaxPhones <= 0 || len(phones)-2 <= ttsChunkMaxPhones {
+		return whole
+	}
+
+	slicer := &ttsChunkSlicer{
+		tokenize: m.bertExtractor.Tokenize,
+		phones:   phones[1 : len(phones)-1],
+		tones:    tones[1 : len(tones)-1],
+		word2ph:  word2ph[1 : len(word2ph)-1],
+	}
+	sentences := splitChunkSentences(filteredText)
+	counts, total := slicer.tokenCounts(sentences)
+	phoneCount := 0
+	for _, n := range slicer.word2ph {
+		phoneCount += n
+	}
+	if total != len(slicer.word2ph) || phoneCount != len(slicer.phones) {
+		fmt.Printf("分块跳过: token数 %d、音素数 %d 与 word2ph %d、%d 不一致\n", total, len(slicer.phones), len(slicer.word2ph), phoneCount)
+		return whole
+	}
+	var units []ttsChunk
+	for i, sentence := range sentences {
+		units = append(units, slicer.units(sentence, counts[i], splitChunkClauses)...)
+	}
+	if len(units) == 0 {
+		return whole
+	}
+
+	// 相邻单元合并到不超过上限
+	var chunks []ttsChunk
+	var current *ttsChunk
+	var texts []string
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.phones = append(current.phones, "_")
+		current.tones = append(current.tones, 0)
+		current.word2ph = append(current.word2ph, 1)
+		current.text = strings.Join(texts, " ")
+		chunks = append(chunks, *current)
+		current, texts = nil, nil
+	}
+	for _, unit := range units {
+		if current != nil && len(current.phones)-1+len(unit.phones) > ttsChunkMaxPhones {
+			flush()
+		}
+		if current == nil {
+			current = &ttsChunk{phones: []string{"_"}, tones: []int{0}, word2ph: []int{1}}
+		}
+		current.phones = append(current.phones, unit.phones...)
+		current.tones = append(current.tones, unit.tones...)
+		current.word2ph = append(current.word2ph, unit.word2ph...)
+		current.pauseMs = unit.pauseMs
+		texts = append(texts, unit.text)
+	}
+	flush()
+	fmt.Printf("长文本分块: 音素数=%d, 块数=%d\n", len(phones)-2, len(chunks))
+	return chunks
+}
+
+// 按文本片段依次从整段g2p结果中切出音素，音素不带首尾 "_"
+// 标点在BERT中总是单独成token，在标点或空白处切开时各段的token数之和与整段一致
+type ttsChunkSlicer struct {
+	tokenize func(string) []string
+	phones   []string
+	tones    []int
+	word2ph  []int
+	token    int // 已切出的token数
+	phone    int // 已切出的音素数
+}
+
+// 各片段的token数及其和
+func (s *ttsChunkSlicer) tokenCounts(parts []string) ([]int, int) {
+	counts := make([]int, len(parts))
+	total := 0
+	for i, part := range parts {
+		counts[i] = len(s.tokenize(part))
+		total += counts[i]
+	}
+	return counts, total
+}
+
+// 切出一段文本（含 tokenCount 个token）的音素，超过上限时用 split 切开后递归处理，最后退化为从中间对半切
+// 切开后token数对不上（如切在英文单词中间）时不再细分
+func (s *ttsChunkSlicer) units(text string, tokenCount int, split func(string) []string) []ttsChunk {
+	end := s.token + tokenCount
+	phoneCount := 0
+	for _, n := range s.word2ph[s.token:end] {
+		phoneCount += n
+	}
+	if phoneCount > ttsChunkMaxPhones {
+		parts := split(text)
+		if len(parts) <= 1 {
+			parts = splitChunkHalves(text)
+		}
+		if counts, total := s.tokenCounts(parts); len(parts) > 1 && total == tokenCount {
+			var units []ttsChunk
+			for i, part := range parts {
+				units = append(units, s.units(part, counts[i], splitChunkHalves)...)
+			}
+			return units
+		}
+	}
+	if tokenCount == 0 {
+		return nil // 只有空白
+	}
+	unit := ttsChunk{
+		phones:  s.phones[s.phone : s.phone+phoneCount],
+		tones:   s.tones[s.phone : s.phone+phoneCount],
+		word2ph: s.word2ph[s.token:end],
+		text:    text,
+		pauseMs: chunkPauseMs(text),
+	}
+	s.token, s.phone = end, s.phone+phoneCount
+	return []ttsChunk{unit}
+}
+
+// 按句末标点切句，保留换行以便识别段落停顿
+func splitChunkSentences(text string) []string {
+	runes := []rune(text)
+	var sentences []string
+	start := 0
+	for _, end := range sentenceEnds(runes) {
+		sentences = append(sentences, string(runes[start:end]))
+		start = end
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+// 在逗号、顿号、分号、冒号、破折号后切分，后面是数字时不切，避免切开 1,000 与 10:30
+func splitChunkClauses(text string) []string {
+	runes := []rune(text)
+	var clauses []string
+	start := 0
+	for i, r := range runes {
+		if !strings.ContainsRune(ttsChunkClausePunct, r) {
+			continue
+		}
+		if i+1 < len(runes) && unicode.IsDigit(runes[i+1]) {
+			continue
+		}
+		clauses = append(clauses, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		clauses = append(clauses, string(runes[start:]))
+	}
+	return clauses
+}
+
+// 没有标点可切时从中间切开，优先切在离中点最近的空白处，避免切开英文单词
+func splitChunkHalves(text string) []string {
+	runes := []rune(text)
+	if len(runes) < 2 {
+		return []string{text}
+	}
+	middle := len(runes) / 2
+	for offset := 0; offset < middle; offset++ {
+		for _, i := range []int{middle - offset, middle + offset} {
+			if i > 0 && i < len(runes) && unicode.IsSpace(runes[i]) {
+				return []string{string(runes[:i]), string(runes[i:])}
+			}
+		}
+	}
+	return []string{string(runes[:middle]), string(runes[middle:])}
+}
+
+// 按文本末尾的标点确定其后的停顿，对半切开处没有标点，不加停顿
+func chunkPauseMs(text string) int {
+	runes := []rune(text)
+	for i := len(runes) - 1; i >= 0; i-- {
+		r := runes[i]
+		if r == '\n' {
+			return ttsChunkPauseMs["paragraph"]
+		}
+		if _, ok := closingPunctMap[r]; ok || unicode.IsSpace(r) {
+			continue
+		}
+		switch {
+		case strings.ContainsRune("。！？.!?", r):
+			return ttsChunkPauseMs["sentence"]
+		case strings.ContainsRune("，、,", r):
+			return ttsChunkPauseMs["clause"]
+		case strings.ContainsRune("；：;:", r):
+			return ttsChunkPauseMs["semicolon"]
+		case strings.ContainsRune("…—", r):
+			return ttsChunkPauseMs["ellipsis"]
+		}
+		return 0
+	}
+	return 0
+}
+
+// 推理一块并去掉首尾静音
+func (m *XWX_TTS) synthesizeChunk(chunk ttsChunk, speakerid int, speed float32, params *TTSInferenceParams) ([]float32, error) {
+	audio, err := m.Tts_phones(chunk.phones, chunk.tones, chunk.word2ph, chunk.text, speakerid, speed, params)
+	if err != nil {
+		return nil, err
+	}
+	return trimChunkSilence(audio, m.SampleRate()), nil
+}
+
+// 依次推理各块并拼接
+func (m *XWX_TTS) synthesizeChunks(chunks []ttsChunk, speakerid int, speed float32, params *TTSInferenceParams) ([]float32, error) {
+	audios := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		audio, err := m.synthesizeChunk(chunk, speakerid, speed, params)
+		if err != nil {
+			return nil, err
+		}
+		audios[i] = audio
+	}
+	return joinTTSChunks(audios, chunks, m.SampleRate()), nil
+}
+
+// 用池内空闲的引擎并行推理各块，当前引擎始终参与；没有空闲引擎时等同于依次推理
+func (p *TTSEnginePool) synthesizeChunks(ctx context.Context, engine *XWX_TTS, chunks []ttsChunk, speakerID int, speed float32, params *TTSInferenceParams) ([]float32, error) {
+	audios := make([][]float32, len(chunks))
+	var next int64
+	var firstErr error
+	var errMutex sync.Mutex
+	worker := func(e *XWX_TTS) {
+		for {
+			i := int(atomic.AddInt64(&next, 1) - 1)
+			if i >= len(chunks) {
+				return
+			}
+			audio, err := e.synthesizeChunk(chunks[i], speakerID, speed, params)
+			if err == nil {
+				err = ctx.Err()
+			}
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+				atomic.StoreInt64(&next, int64(len(chunks)))
+				return
+			}
+			audios[i] = audio
+		}
+	}
+
+	var wg sync.WaitGroup
+	for helpers := 1; helpers < len(chunks); helpers++ {
+		helper := p.tryAcquire()
+		if helper == nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.Release(helper)
+			worker(helper)
+		}()
+	}
+	worker(engine)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return joinTTSChunks(audios, chunks, engine.SampleRate()), nil
+}
+
+// 去掉首尾低于峰值 -40dB 的部分，两端各保留 ttsChunkTrimMarginMs
+func trimChunkSilence(audio []float32, sampleRate int) []float32 {
+	start, end := chunkSpeechBounds(audio, sampleRate)
+	return audio[start:end]
+}
+
+// 去静音后保留的区间 [start, end)，没有高于阈值的采样时为整段
+func chunkSpeechBounds(audio []float32, sampleRate int) (int, int) {
+	threshold := float32(math.Max(maxAbs(audio)*ttsChunkSilenceRatio, ttsChunkMinSilenceGate))
+	start, end := 0, len(audio)
+	for start < end && audio[start] < threshold && audio[start] > -threshold {
+		start++
+	}
+	for end > start && audio[end-1] < threshold && audio[end-1] > -threshold {
+		end--
+	}
+	if start >= end {
+		return 0, len(audio)
+	}
+	margin := ttsChunkTrimMarginMs * sampleRate / 1000
+	return max(start-margin, 0), min(end+margin, len(audio))
+}
+
+// 按块末停顿插入静音，块与块（含静音）之间做等功率交叉淡化
+func joinTTSChunks(audios [][]float32, chunks []ttsChunk, sampleRate int) []float32 {
+	output, _ := joinTTSChunksWithOffsets(audios, chunks, sampleRate)
+	return output
+}
+
+// 同 joinTTSChunks，另外返回每块音频在拼接结果中的起始采样位置
+func joinTTSChunksWithOffsets(audios [][]float32, chunks []ttsChunk, sampleRate int) ([]float32, []int) {
+	fade := ttsChunkCrossfadeMs * sampleRate / 1000
+	total := 0
+	for i, audio := range audios {
+		total += len(audio) + chunks[i].pauseMs*sampleRate/1000
+	}
+	output := make([]float32, 0, total)
+	offsets := make([]int, len(audios))
+	for i, audio := range audios {
+		if i == 0 {
+			output = append(output, audio...)
+			continue
+		}
+		pause := chunks[i-1].pauseMs * sampleRate / 1000
+		next := make([]float32, pause, pause+len(audio))
+		next = append(next, audio...)
+		n := min(fade, len(output), len(next))
+		offsets[i] = len(output) - n + pause
+		tail := output[len(output)-n:]
+		for k := 0; k < n; k++ {
+			t := (float64(k) + 0.5) / float64(n) * math.Pi / 2
+			tail[k] = tail[k]*float32(math.Cos(t)) + next[k]*float32(math.Sin(t))
+		}
+		output = append(output, next[n:]...)
+	}
+	return output, offsets
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocket增量合成：适配LLM逐token输出的场景
+//
+// 客户端消息（文本帧JSON）：
+//   {"type":"start","language":"zh_x","speaker_id":0,"speed":1.0,"device_type":"cpu","lexicon":[...]}  开场，设置本连接参数
+//   {"type":"text","text":"..."}   追加文本片段，凑满一句即合成
+//   {"type":"flush"}               把缓冲区剩余文本全部合成
+//   {"type":"close"}               合成剩余文本后关闭连接
+//
+// 服务端消息：
+//   {"type":"started",...}                         开场确认，附带采样率与音频格式
+//   {"type":"audio","index":n,"text":"...",...}    音频元数据，紧随其后一个二进制帧为 16位小端PCM
+//   {"type":"flushed"} / {"type":"done"}          flush / close 完成
+//   {"type":"error","message":"..."}              出错
+
+// 缓冲区超过该字数仍未遇到句末标点时提前切分：优先在最后一个标点处，其次在空白处，都没有时按字数硬切，
+// 缓冲区因此不会超过该字数
+const wsMaxPendingRunes = 80
+
+// 单条客户端消息（一个帧）的最大字节数，超过时断开连接
+const wsMaxMessageBytes = 64 << 10
+
+// 允许的浏览器来源（Origin 头），由 TTS_WS_ALLOWED_ORIGINS 配置，逗号分隔，如
+// "https://app.example.com,http://localhost:3000"，"*" 允许任意来源；
+// 没有 Origin 头的非浏览器客户端总是允许，未配置时拒绝所有浏览器来源，防止任意网页借访问者的浏览器连接
+var wsAllowedOrigins []string
+
+func init() {
+	for _, origin := range strings.Split(os.Getenv("TTS_WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			wsAllowedOrigins = append(wsAllowedOrigins, origin)
+		}
+	}
+}
+
+// 客户端消息
+type TTSWebSocketMessage struct {
+	Type       string             `json:"type"`                  // start / text / flush / close
+	Text       string             `json:"text,omitempty"`        // type=text 时的文本片段
+	Language   Language           `json:"language,omitempty"`    // 语言类型，仅 start 有效
+	SpeakerID  *int               `json:"speaker_id,omitempty"`  // 发音人ID，默认为0
+	Speed      *float32           `json:"speed,omitempty"`       // 速度，默认为1.0
+	DeviceType *DeviceType        `json:"device_type,omitempty"` // 设备类型，默认为CPU
+	Lexicon    []UserLexiconEntry `json:"lexicon,omitempty"`     // 本连接临时覆盖的发音词典，仅 start 有效
+	SampleRate *int               `json:"sample_rate,omitempty"` // 输出采样率，默认为模型采样率，仅 start 有效
+}
+
+// 服务端元数据消息
+type TTSWebSocketEvent struct {
+	Type       string       `json:"type"`
+	Code       TTSErrorCode `json:"code,omitempty"`
+	Message    string       `json:"message,omitempty"`
+	Index      int          `json:"index,omitempty"`
+	Text       string       `json:"text,omitempty"`
+	Samples    int          `json:"samples,omitempty"`
+	Duration   float64      `json:"duration,omitempty"`
+	SampleRate int          `json:"sample_rate,omitempty"`
+	Format     string       `json:"format,omitempty"`
+}
+
+// 单个WebSocket连接的合成状态
+type ttsWebSocketSession struct {
+	conn       *websocket.Conn
+	pool       *TTSEnginePool
+	lexicon    *UserLexicon
+	speakerID  int
+	speed      float32
+	sampleRate int // 输出采样率
+	modelRate  int // 模型采样率
+	pending    string // 尚未凑成完整句子的文本
+	index      int    // 已下发的音频段数
+}
+
+// 创建WebSocket处理器，按 wsAllowedOrigins 检查来源，不允许时返回 403
+func newTTSWebSocketHandler() http.Handler {
+	return websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			if origin := req.Header.Get("Origin"); !isWebSocketOriginAllowed(origin) {
+				fmt.Printf("拒绝WebSocket连接, 来源不在 TTS_WS_ALLOWED_ORIGINS 中: %s\n", origin)
+				return fmt.Errorf("origin not allowed: %s", origin)
+			}
+			return nil
+		},
+		Handler: ttsWebSocketHandler,
+	}
+}
+
+func isWebSocketOriginAllowed(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	origin = strings.TrimRight(origin, "/")
+	for _, allowed := range wsAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func ttsWebSocketHandler(conn *websocket.Conn) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = wsMaxMessageBytes
+	startTime := time.Now()
+
+	// 第一条消息必须是 start
+	var startMsg TTSWebSocketMessage
+	if err := websocket.JSON.Receive(conn, &startMsg); err != nil {
+		fmt.Printf("WebSocket读取开场消息失败: %v\n", err)
+		return
+	}
+	if startMsg.Type != "start" {
+		sendWebSocketError(conn, "第一条消息必须为 start")
+		return
+	}
+	if startMsg.Language == "" {
+		sendWebSocketError(conn, "start 消息缺少 language")
+		return
+	}
+
+	session := &ttsWebSocketSession{
+		conn:       conn,
+		speakerID:  0,
+		speed:      1.0,
+		modelRate:  ttsModelSampleRate(startMsg.Language),
+	}
+	sampleRate, err := resolveOutputSampleRate(startMsg.SampleRate, session.modelRate)
+	if err != nil {
+		sendWebSocketTTSError(conn, err)
+		return
+	}
+	session.sampleRate = sampleRate
+	if startMsg.SpeakerID != nil {
+		session.speakerID = *startMsg.SpeakerID
+	}
+	if startMsg.Speed != nil {
+		session.speed = *startMsg.Speed
+	}
+	deviceType := CPU
+	if startMsg.DeviceType != nil {
+		deviceType = *startMsg.DeviceType
+	}
+	lexicon, err := userLexicon.WithOverrides(startMsg.Lexicon)
+	if err != nil {
+		sendWebSocketTTSError(conn, err)
+		return
+	}
+	session.lexicon = lexicon
+
+	// 引擎按句从池中获取，连接空闲等待文本时不占用引擎
+	pool, err := getOrCreateTTSEnginePool(startMsg.Language, deviceType)
+	if err != nil {
+		sendWebSocketTTSError(conn, err)
+		return
+	}
+	session.pool = pool
+
+	if err := websocket.JSON.Send(conn, TTSWebSocketEvent{
+		Type:       "started",
+		SampleRate: session.sampleRate,
+		Format:     "pcm_s16le",
+	}); err != nil {
+		return
+	}
+
+	for {
+		var msg TTSWebSocketMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			fmt.Printf("WebSocket连接结束: %v\n", err)
+			return
+		}
+
+		switch msg.Type {
+		case "text":
+			session.pending += msg.Text
+			sentences, rest := SplitCompleteSentences(session.pending, wsMaxPendingRunes)
+			session.pending = rest
+			if err := session.synthesize(sentences); err != nil {
+				return
+			}
+		case "flush", "close":
+			sentences := SplitSentences(session.pending)
+			session.pending = ""
+			if err := session.synthesize(sentences); err != nil {
+				return
+			}
+			if msg.Type == "flush" {
+				if err := websocket.JSON.Send(conn, TTSWebSocketEvent{Type: "flushed"}); err != nil {
+					return
+				}
+				continue
+			}
+			websocket.JSON.Send(conn, TTSWebSocketEvent{Type: "done"})
+			fmt.Printf("WebSocket TTS完成: 音频段数=%d, 耗时=%v\n", session.index, time.Since(startTime))
+			return
+		default:
+			sendWebSocketError(conn, "未知消息类型: "+msg.Type)
+		}
+	}
+}
+
+// 经引擎池合成一句，开启合批时可与其它连接的句子合并推理
+func (s *ttsWebSocketSession) synthesizeSentence(sentence string) ([]float32, error) {
+	audioData, err := s.pool.Synthesize(s.conn.Request().Context(), sentence, s.lexicon, s.speakerID, s.speed, nil)
+	if err != nil {
+		return nil, err
+	}
+	audioData, err = resampleToOutputRate(audioData, s.modelRate, s.sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	// 超出满幅时以真峰值限幅代替削波
+	audioData, _ = processLoudness(audioData, s.sampleRate, TTSLoudnessOptions{})
+	return audioData, nil
+}
+
+// 逐句合成并下发：先发JSON元数据，再发二进制PCM帧
+func (s *ttsWebSocketSession) synthesize(sentences []string) error {
+	for _, sentence := range sentences {
+		audioData, err := s.synthesizeSentence(sentence)
+		if err != nil {
+			// 单句失败不断开连接，通知客户端后继续处理后续文本
+			sendWebSocketTTSError(s.conn, err)
+			continue
+		}
+
+		pcmData := appendPCM16(nil, audioData)
+
+		s.index++
+		if err := websocket.JSON.Send(s.conn, TTSWebSocketEvent{
+			Type:     "audio",
+			Index:    s.index,
+			Text:     sentence,
+			Samples:  len(audioData),
+			Duration: float64(len(audioData)) / float64(s.sampleRate),
+		}); err != nil {
+			return err
+		}
+		if err := websocket.Message.Send(s.conn, pcmData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendWebSocketError(conn *websocket.Conn, message string) {
+	websocket.JSON.Send(conn, TTSWebSocketEvent{
+		Type:    "error",
+		Code:    ErrCodeInvalidRequest,
+		Message: message,
+	})
+}
+
+func sendWebSocketTTSError(conn *websocket.Conn, err error) {
+	websocket.JSON.Send(conn, TTSWebSocketEvent{
+		Type:    "error",
+		Code:    asTTSError(err).Code,
+		Message: err.Error(),
+	})
+}
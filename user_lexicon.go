@@ -0,0 +1,447 @@
+package main
+
+// 用户发音词典：把词映射为拼音、粤拼或 ARPAbet，修正品牌名、人名和多音字（重庆、银行、长大）
+// 启动时从 JSON 或 TSV 文件加载，可通过 /lexicon 接口增删改并写回文件，也可在单次请求中临时覆盖
+//   JSON：[{"word": "重庆", "alphabet": "pinyin", "ph": "chong2 qing4"}, ...]
+//   TSV ：每行 "词<TAB>alphabet<TAB>读音"，# 开头为注释
+// 中文词按字数与音节数一一对应，g2p 时在文本上做最长匹配；英文词按单词整体匹配，不区分大小写
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 用户词典候选文件，加载第一个存在的，修改后写回同一个文件；都不存在时写入第一个
+var userLexiconPaths = []string{
+	"./user_lexicon.json",
+	"./user_lexicon.tsv",
+}
+
+const (
+	LexiconPinyin   = "pinyin"
+	LexiconJyutping = "jyutping"
+	LexiconArpabet  = "arpabet"
+)
+
+// 用户词典条目
+type UserLexiconEntry struct {
+	Word     string `json:"word"`
+	Alphabet string `json:"alphabet"` // pinyin / jyutping / arpabet
+	Phonemes string `json:"ph"`       // 以空格分隔的音节或音素，如 "chong2 qing4"、"gei1 jin6"、"N AY1 K IY0"
+}
+
+// 用户发音词典，parent 不为空时为单次请求的覆盖层，查不到再查 parent
+type UserLexicon struct {
+	mutex      sync.RWMutex
+	entries    map[string]map[string]UserLexiconEntry // alphabet -> 词 -> 条目
+	maxWordLen int
+	path       string
+	parent     *UserLexicon
+
+	generation        uint64 // 每次增删改加1
+	version           string // 条目内容的摘要，generation 变化后重新计算
+	versionGeneration uint64
+}
+
+// 最长匹配结果，Start/End 为字（rune）下标
+type userLexiconMatch struct {
+	Start int
+	End   int
+	Entry UserLexiconEntry
+}
+
+// 全局用户词典，启动时加载
+var userLexicon = NewUserLexicon("")
+
+func NewUserLexicon(path string) *UserLexicon {
+	return &UserLexicon{
+		entries: make(map[string]map[string]UserLexiconEntry),
+		path:    path,
+	}
+}
+
+// 从候选文件加载用户词典，没有文件时返回空词典
+func LoadUserLexicon(paths []string) (*UserLexicon, error) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		start := time.Now()
+		lexicon := NewUserLexicon(path)
+		entries, err := readUserLexiconFile(path)
+		if err != nil {
+			return nil, newTTSError(ErrCodeModelLoad, "加载用户词典失败: "+path, err)
+		}
+		for _, entry := range entries {
+			if _, err := lexicon.Set(entry); err != nil {
+				fmt.Printf("用户词典条目无效，已跳过: %v\n", err)
+			}
+		}
+		fmt.Printf("加载用户词典 %s 完成条数: %d (耗时: %v)\n", path, len(lexicon.List()), time.Since(start))
+		return lexicon, nil
+	}
+	path := ""
+	if len(paths) > 0 {
+		path = paths[0]
+	}
+	return NewUserLexicon(path), nil
+}
+
+func readUserLexiconFile(path string) ([]UserLexiconEntry, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		entries := []UserLexiconEntry{}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+		return entries, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []UserLexiconEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		entries = append(entries, UserLexiconEntry{
+			Word:     strings.TrimSpace(fields[0]),
+			Alphabet: strings.TrimSpace(fields[1]),
+			Phonemes: strings.TrimSpace(fields[2]),
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// Save 写回加载时的文件，先写临时文件再替换，避免写一半时进程退出
+func (l *UserLexicon) Save() error {
+	if l.path == "" {
+		return nil
+	}
+	entries := l.List()
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(l.path), ".json") {
+		var err error
+		data, err = json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+	} else {
+		var builder strings.Builder
+		for _, entry := range entries {
+			builder.WriteString(entry.Word + "\t" + entry.Alphabet + "\t" + entry.Phonemes + "\n")
+		}
+		data = []byte(builder.String())
+	}
+
+	tmpPath := l.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, l.path)
+}
+
+// WithOverrides 生成单次请求的覆盖层，请求中的条目优先于全局词典
+func (l *UserLexicon) WithOverrides(entries []UserLexiconEntry) (*UserLexicon, error) {
+	if len(entries) == 0 {
+		return l, nil
+	}
+	overlay := NewUserLexicon("")
+	overlay.parent = l
+	for _, entry := range entries {
+		if _, err := overlay.Set(entry); err != nil {
+			return nil, err
+		}
+	}
+	return overlay, nil
+}
+
+// Set 校验后新增或替换条目，返回规范化后的条目
+func (l *UserLexicon) Set(entry UserLexiconEntry) (UserLexiconEntry, error) {
+	entry, err := normalizeUserLexiconEntry(entry)
+	if err != nil {
+		return entry, err
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.entries[entry.Alphabet] == nil {
+		l.entries[entry.Alphabet] = make(map[string]UserLexiconEntry)
+	}
+	l.entries[entry.Alphabet][userLexiconKey(entry.Alphabet, entry.Word)] = entry
+	if n := utf8.RuneCountInString(entry.Word); n > l.maxWordLen {
+		l.maxWordLen = n
+	}
+	l.generation++
+	return entry, nil
+}
+
+// Delete 删除词条，alphabet 为空时删除该词的所有读音，返回删除的条数
+func (l *UserLexicon) Delete(word string, alphabet string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	deleted := 0
+	for name, words := range l.entries {
+		if alphabet != "" && name != alphabet {
+			continue
+		}
+		key := userLexiconKey(name, word)
+		if _, ok := words[key]; ok {
+			delete(words, key)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		l.generation++
+	}
+	return deleted
+}
+
+// Get 查询一个词的所有读音
+func (l *UserLexicon) Get(word string) []UserLexiconEntry {
+	result := []UserLexiconEntry{}
+	for _, alphabet := range []string{LexiconPinyin, LexiconJyutping, LexiconArpabet} {
+		if entry, ok := l.Lookup(alphabet, word); ok {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// List 返回本层全部条目，按 alphabet、词排序
+func (l *UserLexicon) List() []UserLexiconEntry {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.listLocked()
+}
+
+// Version 返回本层条目内容的摘要，用于音频缓存 key：词典修改后 key 随之改变，
+// 修改前开始合成、修改后才写入缓存的音频不会被新请求命中；按内容计算，重启后不变
+func (l *UserLexicon) Version() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.version == "" || l.versionGeneration != l.generation {
+		hash := sha256.New()
+		for _, entry := range l.listLocked() {
+			fmt.Fprintf(hash, "%s\t%s\t%s\n", entry.Alphabet, entry.Word, entry.Phonemes)
+		}
+		l.version = hex.EncodeToString(hash.Sum(nil))[:16]
+		l.versionGeneration = l.generation
+	}
+	return l.version
+}
+
+func (l *UserLexicon) listLocked() []UserLexiconEntry {
+	result := []UserLexiconEntry{}
+	for _, words := range l.entries {
+		for _, entry := range words {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Alphabet != result[j].Alphabet {
+			return result[i].Alphabet < result[j].Alphabet
+		}
+		return result[i].Word < result[j].Word
+	})
+	return result
+}
+
+// Lookup 查词，本层没有时查 parent
+func (l *UserLexicon) Lookup(alphabet string, word string) (UserLexiconEntry, bool) {
+	for layer := l; layer != nil; layer = layer.parent {
+		layer.mutex.RLock()
+		entry, ok := layer.entries[alphabet][userLexiconKey(alphabet, word)]
+		layer.mutex.RUnlock()
+		if ok {
+			return entry, true
+		}
+	}
+	return UserLexiconEntry{}, false
+}
+
+// Match 在文本上做正向最长匹配，返回所有命中的词
+func (l *UserLexicon) Match(alphabet string, text string) []userLexiconMatch {
+	if l == nil {
+		return nil
+	}
+	maxWordLen := 0
+	for layer := l; layer != nil; layer = layer.parent {
+		layer.mutex.RLock()
+		if layer.maxWordLen > maxWordLen {
+			maxWordLen = layer.maxWordLen
+		}
+		layer.mutex.RUnlock()
+	}
+
+	runes := []rune(text)
+	matches := []userLexiconMatch{}
+	for i := 0; i < len(runes); {
+		matched := false
+		for n := min(maxWordLen, len(runes)-i); n >= 1; n-- {
+			if entry, ok := l.Lookup(alphabet, string(runes[i:i+n])); ok {
+				matches = append(matches, userLexiconMatch{Start: i, End: i + n, Entry: entry})
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			i++
+		}
+	}
+	return matches
+}
+
+// 英文词不区分大小写
+func userLexiconKey(alphabet string, word string) string {
+	if alphabet == LexiconArpabet {
+		return strings.ToUpper(word)
+	}
+	return word
+}
+
+// 校验条目：读音必须能解析，中文词的音节数必须与字数一致
+func normalizeUserLexiconEntry(entry UserLexiconEntry) (UserLexiconEntry, error) {
+	entry.Word = strings.TrimSpace(entry.Word)
+	entry.Alphabet = strings.ToLower(strings.TrimSpace(entry.Alphabet))
+	entry.Phonemes = strings.Join(strings.Fields(entry.Phonemes), " ")
+	if entry.Word == "" {
+		return entry, newTTSError(ErrCodeInvalidRequest, "词典条目缺少 word", nil)
+	}
+
+	_, _, counts, err := Phoneme_g2p(entry.Alphabet, entry.Phonemes)
+	if err != nil {
+		return entry, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("词典条目 %q 读音无效", entry.Word), err)
+	}
+	switch entry.Alphabet {
+	case LexiconPinyin, LexiconJyutping:
+		if len(counts) != utf8.RuneCountInString(entry.Word) {
+			return entry, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("词典条目 %q 的音节数(%d)与字数不一致", entry.Word, len(counts)), nil)
+		}
+	case LexiconArpabet:
+		if strings.ContainsAny(entry.Word, " \t") {
+			return entry, newTTSError(ErrCodeInvalidRequest, fmt.Sprintf("arpabet 词典条目 %q 只能是单个英文单词", entry.Word), nil)
+		}
+	}
+	return entry, nil
+}
+
+// 用户词典覆盖普通话拼音，syllables 与 text 逐字对应时才生效
+func (l *UserLexicon) ApplyMandaren(text string, syllables []MandarenSyllable) []MandarenSyllable {
+	if l == nil || len(syllables) != utf8.RuneCountInString(text) {
+		return syllables
+	}
+	for _, match := range l.Match(LexiconPinyin, text) {
+		for i, py := range strings.Fields(match.Entry.Phonemes) {
+			// 条目已校验，这里不会出错
+			if syllable, err := ParsePinyinSyllable(py); err == nil {
+				syllables[match.Start+i] = syllable
+			}
+		}
+	}
+	return syllables
+}
+
+// 用户词典覆盖粤拼，jyupinyinList 为 pycantonese 格式的分词结果
+// 能与字一一对应的词逐字替换；音节数与字数不一致的词整体保留，不参与替换；没有读音的字可被词典补上
+func (l *UserLexicon) ApplyCantonese(texts []string, jyupinyinList []interface{}) []interface{} {
+	if l == nil {
+		return jyupinyinList
+	}
+
+	// 按字展开音节
+	slots := [][]interface{}{}
+	locked := []bool{}
+	for _, item := range jyupinyinList {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return jyupinyinList
+		}
+		word, _ := itemMap["char"].(string)
+		initialList, _ := itemMap["initial_list"].([]interface{})
+		n := utf8.RuneCountInString(word)
+		if n == 0 {
+			return jyupinyinList
+		}
+		aligned := len(initialList) == n
+		for i := 0; i < n; i++ {
+			switch {
+			case aligned:
+				slots = append(slots, []interface{}{initialList[i]})
+			case i == 0:
+				slots = append(slots, initialList)
+			default:
+				slots = append(slots, nil)
+			}
+			locked = append(locked, !aligned && len(initialList) > 0)
+		}
+	}
+
+	changed := false
+	for _, text := range texts {
+		if utf8.RuneCountInString(text) != len(slots) {
+			continue
+		}
+		for _, match := range l.Match(LexiconJyutping, text) {
+			blocked := false
+			for i := match.Start; i < match.End; i++ {
+				blocked = blocked || locked[i]
+			}
+			if blocked {
+				continue
+			}
+			syllables, err := ParseJyutpingSyllables(match.Entry.Phonemes)
+			if err != nil {
+				continue
+			}
+			for i, syllable := range syllables {
+				slots[match.Start+i] = []interface{}{map[string]interface{}{
+					"initial": syllable.Initial,
+					"nucleus": syllable.Nucleus,
+					"coda":    syllable.Coda,
+					"tone":    syllable.Tone,
+				}}
+				// 已替换的字不再被后面的文本匹配覆盖
+				locked[match.Start+i] = true
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return jyupinyinList
+	}
+
+	initialList := []interface{}{}
+	for _, slot := range slots {
+		initialList = append(initialList, slot...)
+	}
+	return []interface{}{map[string]interface{}{
+		"char":         texts[0],
+		"pinyin":       "",
+		"initial_list": initialList,
+	}}
+}